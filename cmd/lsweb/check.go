@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hemzaz/lsweb/pkg/common"
+	"github.com/hemzaz/lsweb/pkg/downloader"
+	"github.com/hemzaz/lsweb/pkg/parser"
+	"github.com/hemzaz/lsweb/pkg/robots"
+)
+
+// runCheck implements "lsweb check": it lists the links of its sources as lsweb
+// does, requests each HTTP(S) link and prints a report. Returns the exit status,
+// which is 1 when a link is broken or a source could not be read.
+func runCheck(args []string) int {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: lsweb check [flags]\n\nCheck the links of pages and files for broken links.\n\nFlags:")
+		flags.PrintDefaults()
+	}
+
+	var urlFlags, fileFlags stringList
+	flags.Var(&urlFlags, "u", "URL to check the links of (can be specified multiple times; - reads a document from stdin)")
+	flags.Var(&fileFlags, "f", "File to check the links of (can be specified multiple times; - reads stdin)")
+	seedFlag := flags.String("i", "", "File of seed URLs to check the links of, one per line (- reads stdin)")
+	baseFlag := flags.String("base", "", "Base URL to resolve relative links in files against")
+	tagsFlag := flags.String("tags", "a", "Comma-separated HTML elements to check links from (e.g. a,img,script,srcset,style or all)")
+	filterFlag := flags.String("filter", "", "Regex selecting the links to check")
+	filterTextFlag := flags.String("filter-text", "", "Regex selecting the links to check by anchor text or title")
+	outputFlag := flags.String("o", "table", "Output format (table, json, junit)")
+	ignoreCertFlag := flags.Bool("ic", false, "Ignore certificate errors")
+	maxConcurrentFlag := flags.Int("max-concurrent", 5, "Maximum number of concurrent checks")
+	timeoutFlag := flags.Int("timeout", 60, "Timeout in seconds for HTTP requests")
+	ignoreRobotsFlag := flags.Bool("ignore-robots", false, "Check links that robots.txt disallows instead of skipping them")
+	delayFlag := flags.Duration("delay", robots.DefaultHostDelay, "Minimum time between requests to the same host, e.g. 1s")
+	flags.Parse(args)
+
+	format := strings.ToLower(*outputFlag)
+	switch format {
+	case "table", "json", "junit":
+	default:
+		log.Fatalf("Invalid output format: %s (valid formats: table, json, junit)", *outputFlag)
+	}
+
+	sources, err := collectSources(urlFlags, fileFlags, *seedFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(sources) == 0 {
+		log.Fatal("Please provide a URL (-u), file (-f) or seed file (-i) to check the links of")
+	}
+
+	downloader.SetTimeout(time.Duration(*timeoutFlag) * time.Second)
+	downloader.SetMaxConcurrent(*maxConcurrentFlag)
+	robots.SetIgnoreRobots(*ignoreRobotsFlag)
+	robots.SetHostDelay(*delayFlag)
+	if err := parser.SetTags(strings.Split(*tagsFlag, ",")); err != nil {
+		log.Fatal(err)
+	}
+	if err := parser.SetBaseURL(*baseFlag); err != nil {
+		log.Fatal(err)
+	}
+
+	links, failedSources, err := fetchAllLinks(sources, fetchMode{ignoreCert: *ignoreCertFlag})
+	if err != nil {
+		log.Fatal(err)
+	}
+	links, err = linkFilters{filter: *filterFlag, filterText: *filterTextFlag}.apply(links)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	results := downloader.CheckLinks(httpLinks(links), *ignoreCertFlag)
+	if err := downloader.PrintCheckResults(results, format); err != nil {
+		log.Fatal(err)
+	}
+	if format != "table" {
+		// Keep stdout parseable; the summary goes to stderr
+		fmt.Fprintln(os.Stderr, downloader.CheckSummary(results))
+	}
+
+	for _, result := range results {
+		if result.Broken() {
+			return 1
+		}
+	}
+	if failedSources > 0 {
+		return 1
+	}
+	return 0
+}
+
+// httpLinks returns the links with an http or https URL, the ones that can be checked
+func httpLinks(links []common.Link) []common.Link {
+	var checkable []common.Link
+	for _, link := range links {
+		if u, err := url.Parse(link.URL); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+			checkable = append(checkable, link)
+		}
+	}
+	return checkable
+}
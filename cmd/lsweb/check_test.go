@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckReportWithWarnings(t *testing.T) {
+	// The malformed link makes the parser print a warning
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<a href="http://[::1">Bad</a> <a href="/ok">OK</a>`)
+		case "/ok":
+			fmt.Fprint(w, "ok")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	jsonPath := filepath.Join(dir, "report.json")
+	runLsweb(t, jsonPath, "check", "-u", server.URL+"/", "-o", "json")
+	data, err := os.ReadFile(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results []map[string]any
+	if err := json.Unmarshal(data, &results); err != nil || len(results) != 1 || results[0]["url"] != server.URL+"/ok" {
+		t.Errorf("Expected a JSON report of %s/ok, got %q (error: %v)", server.URL, data, err)
+	}
+
+	junitPath := filepath.Join(dir, "report.xml")
+	runLsweb(t, junitPath, "check", "-u", server.URL+"/", "-o", "junit")
+	data, err = os.ReadFile(junitPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report struct {
+		Suites []struct {
+			Tests int `xml:"tests,attr"`
+		} `xml:"testsuite"`
+	}
+	if err := xml.Unmarshal(data, &report); err != nil || len(report.Suites) != 1 || report.Suites[0].Tests != 1 {
+		t.Errorf("Expected a JUnit report of one test, got %q (error: %v)", data, err)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hemzaz/lsweb/pkg/common"
+	"github.com/hemzaz/lsweb/pkg/crawler"
+	"github.com/hemzaz/lsweb/pkg/downloader"
+	"github.com/hemzaz/lsweb/pkg/parser"
+	"github.com/hemzaz/lsweb/pkg/robots"
+	"github.com/hemzaz/lsweb/pkg/snapshot"
+)
+
+// Exit statuses of "lsweb diff", as for diff(1)
+const (
+	diffSame    = 0
+	diffChanged = 1
+	diffTrouble = 2
+)
+
+// runDiff implements "lsweb diff": it compares two sets of links, each read from a
+// file of lsweb's JSON output or a snapshot, or listed live from sources, and prints
+// the links added, removed and changed. Returns 0 when the sets are the same, 1 when
+// they differ and 2 on errors.
+func runDiff(args []string) int {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: lsweb diff [flags] old.json new.json\n       lsweb diff [flags] -against old.json -u URL\n\nCompare two link listings.\n\nFlags:")
+		flags.PrintDefaults()
+	}
+
+	var urlFlags, fileFlags stringList
+	flags.Var(&urlFlags, "u", "URL to list the new links from (can be specified multiple times)")
+	flags.Var(&fileFlags, "f", "File to list the new links from (can be specified multiple times)")
+	seedFlag := flags.String("i", "", "File of seed URLs to list the new links from, one per line")
+	againstFlag := flags.String("against", "", "JSON listing or snapshot holding the old links")
+	outputFlag := flags.String("o", "txt", "Output format (txt, json, unified)")
+	tagsFlag := flags.String("tags", "a", "Comma-separated HTML elements to extract links from (e.g. a,img,script,srcset,style or all)")
+	filterFlag := flags.String("filter", "", "Regex selecting the links to compare")
+	filterTextFlag := flags.String("filter-text", "", "Regex selecting the links to compare by anchor text or title")
+	ghFlag := flags.Bool("gh", false, "List GitHub release assets")
+	s3Flag := flags.Bool("s3", false, "Treat the URLs as S3-compatible bucket endpoints")
+	indexFlag := flags.Bool("index", false, "Treat the URLs as web server directory listings and read entry sizes and dates")
+	sitemapFlag := flags.Bool("sitemap", false, "Treat the URLs as sitemaps")
+	crawlFlag := flags.Bool("crawl", false, "Crawl the URLs and compare the files found")
+	depthFlag := flags.Int("depth", crawler.DefaultMaxDepth, "Maximum number of links to follow from the start page (with -crawl)")
+	ignoreCertFlag := flags.Bool("ic", false, "Ignore certificate errors")
+	timeoutFlag := flags.Int("timeout", 60, "Timeout in seconds for HTTP requests")
+	ignoreRobotsFlag := flags.Bool("ignore-robots", false, "Ignore robots.txt when crawling")
+	delayFlag := flags.Duration("delay", robots.DefaultHostDelay, "Minimum time between requests to the same host, e.g. 1s")
+	flags.Parse(args)
+
+	// stdout carries the diff alone
+	statusOutput = os.Stderr
+
+	format := strings.ToLower(*outputFlag)
+	switch format {
+	case "txt", "json", "unified":
+	default:
+		log.Printf("Invalid output format: %s (valid formats: txt, json, unified)", *outputFlag)
+		return diffTrouble
+	}
+
+	// The old links come from -against or the first file; the new links from the
+	// sources or the remaining file
+	files := flags.Args()
+	oldName := *againstFlag
+	if oldName == "" && len(files) > 0 {
+		oldName, files = files[0], files[1:]
+	}
+	live := len(urlFlags) > 0 || len(fileFlags) > 0 || *seedFlag != ""
+	if oldName == "" || (live && len(files) != 0) || (!live && len(files) != 1) {
+		flags.Usage()
+		return diffTrouble
+	}
+
+	oldSnapshot, err := snapshot.Load(oldName)
+	if err != nil {
+		log.Print(err)
+		return diffTrouble
+	}
+
+	var newName string
+	var newLinks []common.Link
+	if live {
+		sources, err := collectSources(urlFlags, fileFlags, *seedFlag)
+		if err != nil {
+			log.Print(err)
+			return diffTrouble
+		}
+
+		downloader.SetTimeout(time.Duration(*timeoutFlag) * time.Second)
+		robots.SetIgnoreRobots(*ignoreRobotsFlag)
+		robots.SetHostDelay(*delayFlag)
+		if err := parser.SetTags(strings.Split(*tagsFlag, ",")); err != nil {
+			log.Print(err)
+			return diffTrouble
+		}
+
+		mode := fetchMode{
+			github:       *ghFlag,
+			s3:           *s3Flag,
+			index:        *indexFlag,
+			sitemap:      *sitemapFlag,
+			crawl:        *crawlFlag,
+			crawlOptions: crawler.Options{MaxDepth: *depthFlag, IgnoreCert: *ignoreCertFlag},
+			ignoreCert:   *ignoreCertFlag,
+		}
+		links, failedSources, err := fetchAllLinks(sources, mode)
+		if err == nil && failedSources > 0 {
+			// Links of a source that failed would show up as removed
+			err = fmt.Errorf("%d of %d sources could not be read", failedSources, len(sources))
+		}
+		if err != nil {
+			log.Print(err)
+			return diffTrouble
+		}
+
+		names := make([]string, 0, len(sources))
+		for _, src := range sources {
+			names = append(names, src.name())
+		}
+		newName, newLinks = strings.Join(names, ", "), links
+	} else {
+		newSnapshot, err := snapshot.Load(files[0])
+		if err != nil {
+			log.Print(err)
+			return diffTrouble
+		}
+		newName, newLinks = files[0], newSnapshot.Links
+	}
+
+	// The filters apply to both sides, so that only the selected links are compared
+	filters := linkFilters{filter: *filterFlag, filterText: *filterTextFlag}
+	oldLinks, err := filters.apply(oldSnapshot.Links)
+	if err == nil {
+		newLinks, err = filters.apply(newLinks)
+	}
+	if err != nil {
+		log.Print(err)
+		return diffTrouble
+	}
+
+	diff := snapshot.Compare(oldLinks, newLinks)
+	if err := snapshot.PrintDiff(diff, format, oldName, newName); err != nil {
+		log.Print(err)
+		return diffTrouble
+	}
+	if diff.Empty() {
+		return diffSame
+	}
+	return diffChanged
+}
@@ -1,32 +1,88 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 
 	"github.com/hemzaz/lsweb/pkg/common"
+	"github.com/hemzaz/lsweb/pkg/crawler"
 	"github.com/hemzaz/lsweb/pkg/downloader"
+	"github.com/hemzaz/lsweb/pkg/mirror"
 	"github.com/hemzaz/lsweb/pkg/parser"
+	"github.com/hemzaz/lsweb/pkg/robots"
 )
 
 func main() {
+	// Configure logging
+	log.SetPrefix("lsweb: ")
+	log.SetFlags(0) // Don't show date/time in errors
+
+	// Subcommands have flags of their own
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "check":
+			os.Exit(runCheck(os.Args[2:]))
+		case "watch":
+			os.Exit(runWatch(os.Args[2:]))
+		case "diff":
+			os.Exit(runDiff(os.Args[2:]))
+		}
+	}
+
 	// Setup flags
-	urlFlag := flag.String("u", "", "URL to fetch links from")
-	fileFlag := flag.String("f", "", "File to fetch links from")
-	outputFlag := flag.String("o", "txt", "Output format (json, txt, num, html)")
+	var urlFlags, fileFlags stringList
+	flag.Var(&urlFlags, "u", "URL to fetch links from (can be specified multiple times; - reads a document from stdin)")
+	flag.Var(&fileFlags, "f", "File to fetch links from (can be specified multiple times; - reads stdin)")
+	seedFlag := flag.String("i", "", "File of seed URLs to fetch links from, one per line (- reads stdin)")
+	baseFlag := flag.String("base", "", "Base URL to resolve relative links in files against, e.g. where a Markdown page is published")
+	selectFlag := flag.String("select", "", "CSS selector limiting HTML link extraction to matching elements, e.g. 'table#files a'")
+	xpathFlag := flag.String("xpath", "", "XPath limiting HTML link extraction to matching nodes, e.g. \"//table[@id='files']\"")
+	jsonPathFlag := flag.String("jsonpath", "", "JSONPath selecting the link fields of JSON sources, e.g. '$.items[*].download_url'")
+	outputFlag := flag.String("o", "txt", "Output format (json, txt, num, html, long)")
 	filterFlag := flag.String("filter", "", "Regex to filter links (can be specified multiple times)")
+	filterTextFlag := flag.String("filter-text", "", "Regex to filter links by anchor text or title")
+	tagsFlag := flag.String("tags", "a", "Comma-separated HTML elements to extract links from (e.g. a,img,script,srcset,style or all)")
 	limitFlag := flag.Int("limit", 0, "Limit the number of links to fetch")
 	ignoreCertFlag := flag.Bool("ic", false, "Ignore certificate errors")
 	ghFlag := flag.Bool("gh", false, "Fetch GitHub releases")
+	sitemapFlag := flag.Bool("sitemap", false, "Treat the URL as a sitemap, or discover sitemaps from robots.txt for a site root")
+	maxSitemapsFlag := flag.Int("max-sitemaps", 50, "Maximum number of sitemap documents to fetch when expanding a sitemap index")
+	s3Flag := flag.Bool("s3", false, "Treat the URL as an S3-compatible bucket endpoint and list it with ListObjectsV2")
+	indexFlag := flag.Bool("index", false, "Treat the URL as a web server directory listing and read entry sizes and dates")
+	crawlFlag := flag.Bool("crawl", false, "Follow links to pages breadth-first and list the files they link to")
+	depthFlag := flag.Int("depth", crawler.DefaultMaxDepth, "Maximum number of links to follow from the start page (with -crawl)")
+	maxPagesFlag := flag.Int("max-pages", crawler.DefaultMaxPages, "Maximum number of pages to fetch (with -crawl)")
+	scopeFlag := flag.String("scope", crawler.ScopeHost, "Pages to follow: host (same host) or prefix (same host, under the start URL's directory) (with -crawl)")
+	allowDomainsFlag := flag.String("allow-domains", "", "Comma-separated further domains whose pages are followed (with -crawl)")
+	crawlPagesFlag := flag.Bool("crawl-pages", false, "List the pages crawled instead of the files found (with -crawl)")
+	mirrorFlag := flag.Bool("mirror", false, "Crawl each URL and save the pages and the files they link to under host/path directories, rewriting links for offline browsing")
+	sortFlag := flag.String("sort", "", "Sort links by name, size or date")
+	reverseFlag := flag.Bool("reverse", false, "Reverse the sort order (with -sort)")
+	minSizeFlag := flag.String("min-size", "", "Only include links with a listed size of at least this much, e.g. 10M")
+	maxSizeFlag := flag.String("max-size", "", "Only include links with a listed size of at most this much, e.g. 1G")
+	newerFlag := flag.String("newer", "", "Only include links modified on or after this date, YYYY-MM-DD")
+	olderFlag := flag.String("older", "", "Only include links modified on or before this date, YYYY-MM-DD")
+	followRefreshFlag := flag.Bool("follow-refresh", false, "Follow <meta http-equiv=\"refresh\"> redirects when fetching a URL")
+	maxDecompressedFlag := flag.String("max-decompressed", "10M", "Maximum decompressed size of gzip, brotli or zstd content, e.g. 50M")
+	streamFlag := flag.Bool("stream", false, "Stream HTML links as they are parsed, without a page size limit (txt, num, json or long output)")
 	downloadFlag := flag.Bool("download", false, "Download the files")
 	simFlag := flag.Bool("sim", false, "Download files simultaneously")
 	listFlag := flag.Bool("list", true, "List the links")
 	maxConcurrentFlag := flag.Int("max-concurrent", 5, "Maximum number of concurrent downloads (with -sim)")
 	overwriteFlag := flag.Bool("overwrite", false, "Overwrite existing files when downloading")
+	dirFlag := flag.String("dir", "", "Directory to save downloaded and mirrored files under (default the current directory)")
+	layoutFlag := flag.String("layout", downloader.DefaultLayout, "Path of each downloaded file under -dir, from {host}, {dir}, {path}, {name}, {tag} and {date}, e.g. {tag}/{name}")
+	ignoreRobotsFlag := flag.Bool("ignore-robots", false, "Ignore robots.txt when crawling and downloading")
+	delayFlag := flag.Duration("delay", robots.DefaultHostDelay, "Minimum time between requests to the same host when crawling and downloading, e.g. 1s")
 	timeoutFlag := flag.Int("timeout", 60, "Timeout in seconds for HTTP requests")
 	versionFlag := flag.Bool("version", false, "Show version information")
 	flag.Parse()
@@ -37,47 +93,165 @@ func main() {
 		os.Exit(0)
 	}
 
-	// Configure logging
-	log.SetPrefix("lsweb: ")
-	log.SetFlags(0) // Don't show date/time in errors
-
-	var links []string
-	var err error
+	// Require at least one URL, file or seed file
+	sources, err := collectSources(urlFlags, fileFlags, *seedFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(sources) == 0 {
+		log.Fatal("Please provide a URL (-u), file (-f) or seed file (-i) to fetch links from")
+	}
 
-	// Require either URL or file input
-	if *urlFlag == "" && *fileFlag == "" {
-		log.Fatal("Please provide a URL (-u) or file (-f) to fetch links from")
+	// Keep JSON output parseable by sending progress and summary lines to stderr
+	if strings.ToLower(*outputFlag) == "json" {
+		statusOutput = os.Stderr
+		downloader.SetOutput(os.Stderr)
 	}
 
 	// Set the timeout value for HTTP requests
 	downloader.SetTimeout(time.Duration(*timeoutFlag) * time.Second)
 	downloader.SetMaxConcurrent(*maxConcurrentFlag)
 	downloader.SetOverwriteFiles(*overwriteFlag)
+	downloader.SetOutputDir(*dirFlag)
+	if err := downloader.SetLayout(*layoutFlag); err != nil {
+		log.Fatal(err)
+	}
 
-	// Fetch links from source
-	if *urlFlag != "" {
-		if *ghFlag {
-			links, err = downloader.FetchGitHubReleases(*urlFlag, *ignoreCertFlag)
-			if err != nil {
-				log.Fatal(err)
-			}
-		} else {
-			links, err = parser.ExtractLinksFromURL(*urlFlag, *ignoreCertFlag)
-			if err != nil {
-				log.Fatal(err)
+	// Configure robots.txt compliance and request spacing
+	robots.SetIgnoreRobots(*ignoreRobotsFlag)
+	robots.SetHostDelay(*delayFlag)
+
+	// Configure which HTML elements links are extracted from. A mirror needs the
+	// images, stylesheets and scripts of its pages unless -tags says otherwise.
+	tags := *tagsFlag
+	if *mirrorFlag && !isFlagSet("tags") {
+		tags = "all"
+	}
+	if err := parser.SetTags(strings.Split(tags, ",")); err != nil {
+		log.Fatal(err)
+	}
+	parser.SetFollowRefresh(*followRefreshFlag)
+	if err := parser.SetBaseURL(*baseFlag); err != nil {
+		log.Fatal(err)
+	}
+	if err := parser.SetJSONPath(*jsonPathFlag); err != nil {
+		log.Fatal(err)
+	}
+	if err := parser.SetSelector(*selectFlag); err != nil {
+		log.Fatal(err)
+	}
+	if err := parser.SetXPath(*xpathFlag); err != nil {
+		log.Fatal(err)
+	}
+	parser.SetMaxSitemaps(*maxSitemapsFlag)
+	maxDecompressed, err := parser.ParseSize(*maxDecompressedFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	parser.SetMaxDecompressedSize(maxDecompressed)
+
+	// Stream links as they are parsed and handle each one as it arrives
+	if *streamFlag {
+		if *ghFlag || *s3Flag || *indexFlag || *sitemapFlag || *crawlFlag || *mirrorFlag {
+			log.Fatal("-stream cannot be combined with -gh, -s3, -index, -sitemap, -crawl or -mirror")
+		}
+		if *sortFlag != "" {
+			log.Fatal("-stream cannot be combined with -sort")
+		}
+		if *simFlag {
+			log.Fatal("-stream downloads links as they arrive and cannot be combined with -sim")
+		}
+
+		options := streamOptions{
+			filter:     *filterFlag,
+			filterText: *filterTextFlag,
+			minSize:    *minSizeFlag,
+			maxSize:    *maxSizeFlag,
+			newer:      *newerFlag,
+			older:      *olderFlag,
+			limit:      *limitFlag,
+			output:     strings.ToLower(*outputFlag),
+			list:       *listFlag,
+			download:   *downloadFlag,
+			ignoreCert: *ignoreCertFlag,
+			base:       *baseFlag,
+		}
+		count, err := streamLinks(sources, options)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(statusOutput, "Found %d links\n", count)
+		return
+	}
+
+	// Fetch links from every source, merged without duplicates
+	if (*crawlFlag || *mirrorFlag) && (*ghFlag || *s3Flag || *indexFlag || *sitemapFlag) {
+		log.Fatal("-crawl and -mirror cannot be combined with -gh, -s3, -index or -sitemap")
+	}
+	mode := fetchMode{
+		github:     *ghFlag,
+		s3:         *s3Flag,
+		index:      *indexFlag,
+		sitemap:    *sitemapFlag,
+		crawl:      *crawlFlag,
+		crawlPages: *crawlPagesFlag,
+		crawlOptions: crawler.Options{
+			MaxDepth:   *depthFlag,
+			MaxPages:   *maxPagesFlag,
+			Scope:      *scopeFlag,
+			IgnoreCert: *ignoreCertFlag,
+		},
+		ignoreCert: *ignoreCertFlag,
+	}
+	if *allowDomainsFlag != "" {
+		mode.crawlOptions.AllowedDomains = strings.Split(*allowDomainsFlag, ",")
+	}
+	filters := linkFilters{
+		filter:     *filterFlag,
+		filterText: *filterTextFlag,
+		minSize:    *minSizeFlag,
+		maxSize:    *maxSizeFlag,
+		newer:      *newerFlag,
+		older:      *olderFlag,
+	}
+
+	// Mirror the crawled pages and the files that pass the filters
+	if *mirrorFlag {
+		for _, src := range sources {
+			if src.isFile {
+				log.Fatalf("-mirror crawls URLs and cannot read %s", src.name())
 			}
 		}
-	} else if *fileFlag != "" {
-		links, err = parser.ExtractLinksFromFile(*fileFlag)
+		if isFlagSet("layout") {
+			log.Fatal("-layout cannot be used with -mirror, which saves files under host/path")
+		}
+
+		result, failedSources, err := mirrorSites(sources, mode, filters, mirror.Options{Dir: *dirFlag, IgnoreCert: *ignoreCertFlag})
 		if err != nil {
 			log.Fatal(err)
 		}
+		failed := result.Failed()
+		fmt.Fprintf(statusOutput, "Mirror complete: %d/%d files\n", len(result.Files)-failed, len(result.Files))
+		if failed > 0 || failedSources > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	links, failedSources, err := fetchAllLinks(sources, mode)
+	if err != nil {
+		log.Fatal(err)
 	}
 
 	// Filter links if requested
-	if *filterFlag != "" {
-		links, err = parser.FilterLinksByRegex(links, *filterFlag)
-		if err != nil {
+	links, err = filters.apply(links)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Sort links if requested
+	if *sortFlag != "" {
+		if err := parser.SortLinks(links, *sortFlag, *reverseFlag); err != nil {
 			log.Fatal(err)
 		}
 	}
@@ -88,19 +262,19 @@ func main() {
 	}
 
 	// Show link count
-	fmt.Printf("Found %d links\n", len(links))
+	fmt.Fprintf(statusOutput, "Found %d links\n", len(links))
 
 	// Download files if requested
 	if *downloadFlag {
 		if len(links) == 0 {
 			log.Println("No links to download")
 		} else if *simFlag {
-			err = downloader.DownloadFilesSimultaneously(links, *ignoreCertFlag, true)
+			err = downloader.DownloadLinksSimultaneously(links, *ignoreCertFlag, true)
 			if err != nil {
 				log.Fatal(err)
 			}
 		} else {
-			err = downloader.DownloadFiles(links, *ignoreCertFlag, true)
+			err = downloader.DownloadLinks(links, *ignoreCertFlag, true)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -111,15 +285,287 @@ func main() {
 	if *listFlag && len(links) > 0 {
 		switch strings.ToLower(*outputFlag) {
 		case "json":
-			parser.PrintLinksAsJSON(links)
+			parser.PrintLinkDetailsAsJSON(links)
 		case "num":
-			parser.PrintLinksAsNumbered(links)
+			parser.PrintLinksAsNumbered(common.URLs(links))
 		case "html":
-			parser.PrintLinksAsHTML(links)
+			parser.PrintLinksAsHTML(common.URLs(links))
 		case "txt":
-			parser.PrintLinksAsText(links)
+			parser.PrintLinksAsText(common.URLs(links))
+		case "long":
+			parser.PrintLinksAsLong(links)
 		default:
-			log.Fatalf("Invalid output format: %s (valid formats: json, txt, num, html)", *outputFlag)
+			log.Fatalf("Invalid output format: %s (valid formats: json, txt, num, html, long)", *outputFlag)
+		}
+	}
+
+	// Report sources that could not be read through the exit status
+	if failedSources > 0 {
+		os.Exit(1)
+	}
+}
+
+// fetchSitemapEntries expands the sitemap at siteURL. If siteURL is a site root,
+// the sitemaps listed in its robots.txt are expanded instead.
+func fetchSitemapEntries(siteURL string, ignoreCert bool) ([]parser.SitemapEntry, error) {
+	parsedURL, err := url.Parse(siteURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+
+	sitemaps := []string{siteURL}
+	if parsedURL.Path == "" || parsedURL.Path == "/" {
+		sitemaps, err = parser.DiscoverSitemaps(siteURL, ignoreCert)
+		if err != nil {
+			return nil, err
+		}
+		if len(sitemaps) == 0 {
+			return nil, fmt.Errorf("no sitemaps listed in robots.txt for %s", parsedURL.Host)
+		}
+	}
+
+	var entries []parser.SitemapEntry
+	for _, sitemap := range sitemaps {
+		sitemapEntries, err := parser.ExtractSitemapEntries(sitemap, ignoreCert)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, sitemapEntries...)
+	}
+	return entries, nil
+}
+
+// linkFilters holds the flags that select which links are kept
+type linkFilters struct {
+	filter, filterText             string
+	minSize, maxSize, newer, older string
+}
+
+// apply keeps the links that match -filter and -filter-text and fall within the
+// size and date bounds
+func (f linkFilters) apply(links []common.Link) ([]common.Link, error) {
+	var err error
+	if f.filter != "" {
+		if links, err = parser.FilterLinks(links, f.filter); err != nil {
+			return nil, err
+		}
+	}
+	if f.filterText != "" {
+		if links, err = parser.FilterLinksByText(links, f.filterText); err != nil {
+			return nil, err
+		}
+	}
+	return filterLinksByMetadata(links, f.minSize, f.maxSize, f.newer, f.older)
+}
+
+// isFlagSet reports whether a flag was given on the command line
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// filterLinksByMetadata keeps the links within the given size and date bounds.
+// Sizes use units such as 10M; dates use YYYY-MM-DD and the older bound includes the whole day.
+// Empty bounds are ignored.
+func filterLinksByMetadata(links []common.Link, minSize, maxSize, newer, older string) ([]common.Link, error) {
+	var err error
+	var minBytes, maxBytes int64
+	if minSize != "" {
+		if minBytes, err = parser.ParseSize(minSize); err != nil {
+			return nil, err
+		}
+	}
+	if maxSize != "" {
+		if maxBytes, err = parser.ParseSize(maxSize); err != nil {
+			return nil, err
+		}
+	}
+
+	var since, until time.Time
+	if newer != "" {
+		if since, err = time.Parse("2006-01-02", newer); err != nil {
+			return nil, fmt.Errorf("invalid -newer date: %w", err)
+		}
+	}
+	if older != "" {
+		if until, err = time.Parse("2006-01-02", older); err != nil {
+			return nil, fmt.Errorf("invalid -older date: %w", err)
+		}
+		until = until.Add(24*time.Hour - time.Nanosecond)
+	}
+
+	links = parser.FilterLinksBySize(links, minBytes, maxBytes)
+	return parser.FilterLinksByDate(links, since, until), nil
+}
+
+// streamOptions holds the flags that apply to each link in -stream mode
+type streamOptions struct {
+	filter, filterText             string
+	minSize, maxSize, newer, older string
+	limit                          int
+	output                         string
+	list, download, ignoreCert     bool
+	base                           string
+}
+
+// streamLinks streams the links of each source in turn, filtering, listing and
+// downloading each one as soon as it is parsed. Links already seen in an earlier
+// source are skipped. It stops reading once limit links have been handled and
+// returns their count.
+func streamLinks(sources []source, options streamOptions) (int, error) {
+	switch options.output {
+	case "txt", "num", "json", "long":
+	default:
+		return 0, fmt.Errorf("invalid output format for -stream: %s (valid formats: json, txt, num, long)", options.output)
+	}
+
+	var filterRe, filterTextRe *regexp.Regexp
+	var err error
+	if options.filter != "" {
+		if filterRe, err = regexp.Compile(options.filter); err != nil {
+			return 0, err
+		}
+	}
+	if options.filterText != "" {
+		if filterTextRe, err = regexp.Compile(options.filterText); err != nil {
+			return 0, err
+		}
+	}
+
+	// Validate the metadata bounds once before any link arrives
+	if _, err := filterLinksByMetadata(nil, options.minSize, options.maxSize, options.newer, options.older); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	var downloadErrors int
+	seen := make(map[string]bool)
+
+	// handle processes one link and reports whether more links are wanted
+	handle := func(link common.Link) bool {
+		if seen[link.URL] {
+			return true
+		}
+		seen[link.URL] = true
+
+		if filterRe != nil && !filterRe.MatchString(link.URL) {
+			return true
+		}
+		if filterTextRe != nil && !filterTextRe.MatchString(link.Text) && !filterTextRe.MatchString(link.Title) {
+			return true
+		}
+		kept, _ := filterLinksByMetadata([]common.Link{link}, options.minSize, options.maxSize, options.newer, options.older)
+		if len(kept) == 0 {
+			return true
+		}
+
+		count++
+		if options.list {
+			switch options.output {
+			case "txt":
+				fmt.Println(link.URL)
+			case "num":
+				fmt.Printf("%d. %s\n", count, link.URL)
+			case "json":
+				if data, err := json.Marshal(link); err == nil {
+					fmt.Println(string(data))
+				}
+			case "long":
+				parser.PrintLinksAsLong([]common.Link{link})
+			}
+		}
+		if options.download {
+			if err := downloader.DownloadLink(link, options.ignoreCert, true); err != nil {
+				log.Printf("Error downloading %s: %v", link.URL, err)
+				downloadErrors++
+			}
+		}
+
+		return options.limit <= 0 || count < options.limit
+	}
+
+	for _, src := range sources {
+		more, err := streamSource(src, options, handle)
+		if err != nil {
+			return count, err
+		}
+		if !more {
+			break
+		}
+	}
+
+	if downloadErrors > 0 {
+		return count, fmt.Errorf("%d downloads failed", downloadErrors)
+	}
+	return count, nil
+}
+
+// streamSource streams the links of one source to handle, attributing links from
+// standard input to it. Returns false if handle asked to stop.
+func streamSource(src source, options streamOptions, handle func(common.Link) bool) (bool, error) {
+	more := true
+	wrapped := func(link common.Link) bool {
+		if src.location == stdinName {
+			link.Source = src.name()
+		}
+		more = handle(link)
+		return more
+	}
+
+	if !src.isFile {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		links, errc := parser.StreamLinks(ctx, src.location, options.ignoreCert)
+		for link := range links {
+			if !wrapped(link) {
+				cancel()
+				break
+			}
+		}
+		// Drain the channel so the stream can finish after an early stop
+		for range links {
+		}
+		if err := <-errc; err != nil && err != context.Canceled {
+			return more, err
+		}
+		return more, nil
+	}
+
+	// Relative links resolve against -base, or the file's own URL
+	baseURL := &url.URL{}
+	if options.base != "" {
+		var err error
+		if baseURL, err = url.Parse(options.base); err != nil {
+			return more, fmt.Errorf("invalid base URL: %w", err)
+		}
+	}
+
+	if src.location == stdinName {
+		return more, parser.StreamLinksFromReader(os.Stdin, src.name(), baseURL, wrapped)
+	}
+
+	file, err := os.Open(src.location)
+	if err != nil {
+		return more, fmt.Errorf("error opening file: %w", err)
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil {
+			fmt.Printf("Error closing file: %v\n", closeErr)
+		}
+	}()
+
+	if options.base == "" {
+		absPath, err := filepath.Abs(src.location)
+		if err != nil {
+			return more, fmt.Errorf("error resolving file path: %w", err)
 		}
+		baseURL = &url.URL{Scheme: "file", Path: filepath.ToSlash(absPath)}
 	}
+	return more, parser.StreamLinksFromReader(file, src.location, baseURL, wrapped)
 }
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hemzaz/lsweb/pkg/robots"
+	"github.com/hemzaz/lsweb/pkg/snapshot"
+)
+
+// runMainEnv makes the test binary run main instead of the tests, so that tests can
+// run lsweb as a command
+const runMainEnv = "LSWEB_TEST_RUN_MAIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(runMainEnv) == "1" {
+		main()
+		os.Exit(0)
+	}
+
+	// The test servers are local, so there is no need to space out requests
+	robots.SetHostDelay(0)
+	os.Exit(m.Run())
+}
+
+// Helper function to run lsweb with args and save its stdout at path
+func runLsweb(t *testing.T, path string, args ...string) {
+	t.Helper()
+	runLswebWithInput(t, path, "", args...)
+}
+
+// Helper function to run lsweb with args, feeding it input on stdin, and save its
+// stdout at path
+func runLswebWithInput(t *testing.T, path, input string, args ...string) {
+	t.Helper()
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), runMainEnv+"=1")
+	cmd.Stdin = strings.NewReader(input)
+	cmd.Stdout = out
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("lsweb %v failed: %v", args, err)
+	}
+}
+
+func TestDiffCLIOutput(t *testing.T) {
+	dir := t.TempDir()
+	oldPage := filepath.Join(dir, "old.html")
+	newPage := filepath.Join(dir, "new.html")
+	os.WriteFile(oldPage, []byte(`<a href="https://example.com/a.zip">A</a> <a href="https://example.com/b.zip">B</a>`), 0o644)
+	os.WriteFile(newPage, []byte(`<a href="https://example.com/b.zip">B</a> <a href="https://example.com/c.zip">C</a>`), 0o644)
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"JSON", []string{"-o", "json"}},
+		{"Streamed JSON", []string{"-stream", "-o", "json"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldJSON := filepath.Join(dir, tt.name+"-old.json")
+			newJSON := filepath.Join(dir, tt.name+"-new.json")
+			runLsweb(t, oldJSON, append([]string{"-f", oldPage}, tt.args...)...)
+			runLsweb(t, newJSON, append([]string{"-f", newPage}, tt.args...)...)
+
+			for _, path := range []string{oldJSON, newJSON} {
+				if loaded, err := snapshot.Load(path); err != nil || len(loaded.Links) != 2 {
+					t.Fatalf("Expected 2 links in %s, got %v (error: %v)", filepath.Base(path), loaded, err)
+				}
+			}
+
+			if status := runDiff([]string{oldJSON, newJSON}); status != diffChanged {
+				t.Errorf("Expected exit status %d, got %d", diffChanged, status)
+			}
+		})
+	}
+}
+
+func TestJSONOutputWithWarnings(t *testing.T) {
+	// The malformed link makes the parser print a warning
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<a href="http://[::1">Bad</a> <a href="https://example.com/a.zip">A</a>`)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	for name, args := range map[string][]string{
+		"json":        {"-u", server.URL + "/", "-o", "json"},
+		"stream.json": {"-u", server.URL + "/", "-stream", "-o", "json"},
+	} {
+		path := filepath.Join(dir, name)
+		runLsweb(t, path, args...)
+		if loaded, err := snapshot.Load(path); err != nil || len(loaded.Links) != 1 {
+			t.Errorf("Expected 1 link in the %s output, got %v (error: %v)", name, loaded, err)
+		}
+	}
+}
+
+func TestStreamStdin(t *testing.T) {
+	var gzipped bytes.Buffer
+	writer := gzip.NewWriter(&gzipped)
+	writer.Write([]byte(`<a href="https://example.com/a.zip">A</a>`))
+	writer.Close()
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"JSON", `{"files": [{"url": "https://example.com/a.zip"}]}`},
+		{"Markdown", "Get [the archive](https://example.com/a.zip)."},
+		{"Gzipped HTML", gzipped.String()},
+	}
+
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name+".json")
+			runLswebWithInput(t, path, tt.input, "-stream", "-u", "-", "-o", "json")
+			loaded, err := snapshot.Load(path)
+			if err != nil || len(loaded.Links) != 1 || loaded.Links[0].URL != "https://example.com/a.zip" {
+				t.Errorf("Expected https://example.com/a.zip from stdin, got %v (error: %v)", loaded, err)
+			}
+		})
+	}
+}
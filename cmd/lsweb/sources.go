@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/hemzaz/lsweb/pkg/common"
+	"github.com/hemzaz/lsweb/pkg/crawler"
+	"github.com/hemzaz/lsweb/pkg/downloader"
+	"github.com/hemzaz/lsweb/pkg/mirror"
+	"github.com/hemzaz/lsweb/pkg/parser"
+)
+
+// stdinName is the -u, -f or -i value that stands for standard input
+const stdinName = "-"
+
+// statusOutput receives progress and summary lines such as "Crawled N pages". It is
+// stdout, or stderr when stdout carries JSON that these lines would corrupt.
+var statusOutput io.Writer = os.Stdout
+
+// stringList is a flag that collects every value it is given
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// source is an input to read links from: a URL, a file, or standard input
+type source struct {
+	location string
+	isFile   bool
+}
+
+// name returns how links from the source are attributed
+func (s source) name() string {
+	if s.location == stdinName {
+		return "stdin"
+	}
+	return s.location
+}
+
+// fetchMode holds the flags that choose how URL sources are listed
+type fetchMode struct {
+	github, s3, index, sitemap bool
+	crawl, crawlPages          bool
+	crawlOptions               crawler.Options
+	ignoreCert                 bool
+}
+
+// collectSources lists the sources given with -u and -f, followed by the seed URLs
+// listed one per line in seedFile (-i). A value of "-" reads standard input, which
+// can only be used once: as a document with -u or -f, or as the seed list with -i.
+func collectSources(urls, files []string, seedFile string) ([]source, error) {
+	var sources []source
+	stdinUsed := false
+
+	useStdin := func() error {
+		if stdinUsed {
+			return fmt.Errorf("standard input (-) can only be read once")
+		}
+		stdinUsed = true
+		return nil
+	}
+
+	for _, u := range urls {
+		if u == stdinName {
+			if err := useStdin(); err != nil {
+				return nil, err
+			}
+			sources = append(sources, source{location: stdinName, isFile: true})
+			continue
+		}
+		sources = append(sources, source{location: u})
+	}
+	for _, f := range files {
+		if f == stdinName {
+			if err := useStdin(); err != nil {
+				return nil, err
+			}
+		}
+		sources = append(sources, source{location: f, isFile: true})
+	}
+
+	if seedFile != "" {
+		var r io.Reader
+		if seedFile == stdinName {
+			if err := useStdin(); err != nil {
+				return nil, err
+			}
+			r = os.Stdin
+		} else {
+			file, err := os.Open(seedFile)
+			if err != nil {
+				return nil, fmt.Errorf("error opening seed file: %w", err)
+			}
+			defer file.Close()
+			r = file
+		}
+
+		seeds, err := readSeedURLs(r)
+		if err != nil {
+			return nil, err
+		}
+		for _, seed := range seeds {
+			sources = append(sources, source{location: seed})
+		}
+	}
+
+	return sources, nil
+}
+
+// readSeedURLs reads one URL per line, skipping blank lines and # comments
+func readSeedURLs(r io.Reader) ([]string, error) {
+	var urls []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading seed URLs: %w", err)
+	}
+	return urls, nil
+}
+
+// fetchSourceLinks lists the links of one source, attributing those that do not
+// name the page they came from to the source itself
+func fetchSourceLinks(src source, mode fetchMode) ([]common.Link, error) {
+	var links []common.Link
+	var err error
+
+	switch {
+	case src.location == stdinName:
+		links, err = parser.ReadLinksFromReader(os.Stdin, src.name())
+	case src.isFile:
+		links, err = parser.ReadLinks(src.location)
+	default:
+		links, err = fetchURLLinks(src.location, mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range links {
+		if links[i].Source == "" {
+			links[i].Source = src.name()
+		}
+	}
+	return links, nil
+}
+
+// fetchURLLinks lists the links of a URL as a GitHub repository, bucket, directory
+// listing or sitemap when one of those modes is chosen, crawls it with -crawl,
+// or reads it as a document otherwise
+func fetchURLLinks(targetURL string, mode fetchMode) ([]common.Link, error) {
+	var links []common.Link
+
+	switch {
+	case mode.crawl:
+		return crawlLinks(targetURL, mode)
+	case mode.github:
+		return downloader.FetchGitHubReleaseLinks(targetURL, mode.ignoreCert)
+	case mode.s3:
+		objects, err := parser.ExtractS3Objects(targetURL, mode.ignoreCert)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range objects {
+			links = append(links, object.Link())
+		}
+	case mode.index:
+		entries, err := parser.ExtractDirectoryIndex(targetURL, mode.ignoreCert)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			links = append(links, entry.Link())
+		}
+	case mode.sitemap:
+		entries, err := fetchSitemapEntries(targetURL, mode.ignoreCert)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			links = append(links, entry.Link())
+		}
+	default:
+		return parser.FetchLinks(targetURL, mode.ignoreCert)
+	}
+
+	return links, nil
+}
+
+// fetchAllLinks lists the links of every source and merges them, keeping the first
+// link found for each URL. A single failing source is fatal; with several sources,
+// failures are reported and the others are still read. Returns the number of sources
+// that failed alongside the links.
+func fetchAllLinks(sources []source, mode fetchMode) ([]common.Link, int, error) {
+	if len(sources) == 1 {
+		links, err := fetchSourceLinks(sources[0], mode)
+		if err != nil {
+			return nil, 1, err
+		}
+		return links, 0, nil
+	}
+
+	var lists [][]common.Link
+	failures := 0
+	for _, src := range sources {
+		links, err := fetchSourceLinks(src, mode)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", src.name(), err)
+			failures++
+			continue
+		}
+		lists = append(lists, links)
+	}
+
+	if failures == len(sources) {
+		return nil, failures, fmt.Errorf("no links could be read from any of the %d sources", len(sources))
+	}
+	return common.MergeLinks(lists...), failures, nil
+}
+
+// crawlLinks crawls from startURL and returns the files found, or the pages
+// crawled with -crawl-pages
+func crawlLinks(startURL string, mode fetchMode) ([]common.Link, error) {
+	result, err := crawlSite(startURL, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	if mode.crawlPages {
+		return result.PageLinks(), nil
+	}
+	return result.Files, nil
+}
+
+// crawlSite crawls from startURL, reporting pages that failed to load and a page
+// budget that stopped the crawl as warnings
+func crawlSite(startURL string, mode fetchMode) (*crawler.Result, error) {
+	result, err := crawler.Crawl(startURL, mode.crawlOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, page := range result.Pages {
+		if page.Err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping page %s: %v\n", page.URL, page.Err)
+		}
+	}
+	fmt.Fprintf(statusOutput, "Crawled %d pages from %s\n", len(result.Pages), startURL)
+	if result.Truncated {
+		fmt.Fprintf(os.Stderr, "Warning: page limit of %d reached, some pages were not crawled\n", mode.crawlOptions.MaxPages)
+	}
+	return result, nil
+}
+
+// mirrorSites crawls each URL source and mirrors the pages crawled together with
+// the files found that pass filters. The URLs saved stylesheets reference are
+// mirrored if they are within the scope of one of the crawls and match -filter.
+// As with fetchAllLinks, a single failing source is fatal and failures among
+// several sources are reported. Returns the number of sources that failed
+// alongside the mirror's result.
+func mirrorSites(sources []source, mode fetchMode, filters linkFilters, options mirror.Options) (*mirror.Result, int, error) {
+	var pages []string
+	var fileLists [][]common.Link
+	var starts []*url.URL
+	failures := 0
+
+	for _, src := range sources {
+		result, err := crawlSite(src.location, mode)
+		if err != nil {
+			if len(sources) == 1 {
+				return nil, 1, err
+			}
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", src.name(), err)
+			failures++
+			continue
+		}
+
+		for _, page := range result.Pages {
+			if page.Err == nil {
+				pages = append(pages, page.URL)
+			}
+		}
+		fileLists = append(fileLists, result.Files)
+		if start, err := url.Parse(src.location); err == nil {
+			starts = append(starts, start)
+		}
+	}
+	if failures == len(sources) {
+		return nil, failures, fmt.Errorf("no pages could be crawled from any of the %d sources", len(sources))
+	}
+
+	// Filters select the files; pages are always kept so that the copy can be browsed
+	files, err := filters.apply(common.MergeLinks(fileLists...))
+	if err != nil {
+		return nil, failures, err
+	}
+
+	// Stylesheet references have no text, size or date to filter on
+	var urlFilter *regexp.Regexp
+	if filters.filter != "" {
+		urlFilter = regexp.MustCompile(filters.filter)
+	}
+	options.Allow = func(rawURL string) bool {
+		target, err := url.Parse(rawURL)
+		if err != nil || (urlFilter != nil && !urlFilter.MatchString(rawURL)) {
+			return false
+		}
+		for _, start := range starts {
+			if crawler.InScope(target, start, mode.crawlOptions) {
+				return true
+			}
+		}
+		return false
+	}
+	options.Output = statusOutput
+
+	return mirror.Mirror(append(pages, common.URLs(files)...), options), failures, nil
+}
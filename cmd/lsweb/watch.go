@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hemzaz/lsweb/pkg/common"
+	"github.com/hemzaz/lsweb/pkg/crawler"
+	"github.com/hemzaz/lsweb/pkg/downloader"
+	"github.com/hemzaz/lsweb/pkg/parser"
+	"github.com/hemzaz/lsweb/pkg/robots"
+	"github.com/hemzaz/lsweb/pkg/snapshot"
+)
+
+// watchOptions holds the flags that apply to each poll of "lsweb watch"
+type watchOptions struct {
+	mode       fetchMode
+	filters    linkFilters
+	state      string
+	output     string
+	download   bool
+	ignoreCert bool
+}
+
+// runWatch implements "lsweb watch": it polls its sources every interval and
+// reports the links added, removed and changed since the snapshot kept in the
+// state file, optionally downloading the new ones. Returns the exit status.
+func runWatch(args []string) int {
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: lsweb watch [flags]\n\nPoll sources and report new, changed and removed links.\n\nFlags:")
+		flags.PrintDefaults()
+	}
+
+	var urlFlags, fileFlags stringList
+	flags.Var(&urlFlags, "u", "URL to watch (can be specified multiple times)")
+	flags.Var(&fileFlags, "f", "File to watch (can be specified multiple times)")
+	seedFlag := flags.String("i", "", "File of seed URLs to watch, one per line")
+	intervalFlag := flags.Duration("interval", 10*time.Minute, "Time between polls, e.g. 10m or 1h")
+	stateFlag := flags.String("state", "lsweb-watch.json", "File the last snapshot of the links is kept in")
+	onceFlag := flags.Bool("once", false, "Poll once, report the changes since the last snapshot and exit, e.g. from cron")
+	tagsFlag := flags.String("tags", "a", "Comma-separated HTML elements to extract links from (e.g. a,img,script,srcset,style or all)")
+	filterFlag := flags.String("filter", "", "Regex selecting the new links to download (with -download)")
+	filterTextFlag := flags.String("filter-text", "", "Regex selecting the new links to download by anchor text or title (with -download)")
+	ghFlag := flags.Bool("gh", false, "Watch GitHub release assets")
+	s3Flag := flags.Bool("s3", false, "Treat the URLs as S3-compatible bucket endpoints")
+	indexFlag := flags.Bool("index", false, "Treat the URLs as web server directory listings and read entry sizes and dates")
+	sitemapFlag := flags.Bool("sitemap", false, "Treat the URLs as sitemaps")
+	crawlFlag := flags.Bool("crawl", false, "Crawl the URLs and watch the files found")
+	depthFlag := flags.Int("depth", crawler.DefaultMaxDepth, "Maximum number of links to follow from the start page (with -crawl)")
+	outputFlag := flags.String("o", "txt", "Output format for changes (txt, json)")
+	downloadFlag := flags.Bool("download", false, "Download the links added since the last poll")
+	overwriteFlag := flags.Bool("overwrite", false, "Overwrite existing files when downloading")
+	dirFlag := flags.String("dir", "", "Directory to save downloaded files under (default the current directory)")
+	layoutFlag := flags.String("layout", downloader.DefaultLayout, "Path of each downloaded file under -dir, from {host}, {dir}, {path}, {name}, {tag} and {date}, e.g. {tag}/{name}")
+	ignoreCertFlag := flags.Bool("ic", false, "Ignore certificate errors")
+	timeoutFlag := flags.Int("timeout", 60, "Timeout in seconds for HTTP requests")
+	ignoreRobotsFlag := flags.Bool("ignore-robots", false, "Ignore robots.txt when crawling and downloading")
+	delayFlag := flags.Duration("delay", robots.DefaultHostDelay, "Minimum time between requests to the same host, e.g. 1s")
+	flags.Parse(args)
+
+	options := watchOptions{
+		mode: fetchMode{
+			github:       *ghFlag,
+			s3:           *s3Flag,
+			index:        *indexFlag,
+			sitemap:      *sitemapFlag,
+			crawl:        *crawlFlag,
+			crawlOptions: crawler.Options{MaxDepth: *depthFlag, IgnoreCert: *ignoreCertFlag},
+			ignoreCert:   *ignoreCertFlag,
+		},
+		filters:    linkFilters{filter: *filterFlag, filterText: *filterTextFlag},
+		state:      *stateFlag,
+		output:     strings.ToLower(*outputFlag),
+		download:   *downloadFlag,
+		ignoreCert: *ignoreCertFlag,
+	}
+	if options.output != "txt" && options.output != "json" {
+		log.Fatalf("Invalid output format: %s (valid formats: txt, json)", *outputFlag)
+	}
+	if options.output == "json" {
+		// Keep stdout to one JSON object per poll
+		statusOutput = os.Stderr
+	}
+	if _, err := options.filters.apply(nil); err != nil {
+		log.Fatal(err)
+	}
+	if *intervalFlag <= 0 {
+		log.Fatal("-interval must be positive")
+	}
+
+	sources, err := collectSources(urlFlags, fileFlags, *seedFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(sources) == 0 {
+		log.Fatal("Please provide a URL (-u), file (-f) or seed file (-i) to watch")
+	}
+	for _, src := range sources {
+		if src.location == stdinName {
+			log.Fatal("lsweb watch cannot read standard input, which can only be read once")
+		}
+	}
+
+	downloader.SetTimeout(time.Duration(*timeoutFlag) * time.Second)
+	downloader.SetOverwriteFiles(*overwriteFlag)
+	downloader.SetOutputDir(*dirFlag)
+	if err := downloader.SetLayout(*layoutFlag); err != nil {
+		log.Fatal(err)
+	}
+	robots.SetIgnoreRobots(*ignoreRobotsFlag)
+	robots.SetHostDelay(*delayFlag)
+	if err := parser.SetTags(strings.Split(*tagsFlag, ",")); err != nil {
+		log.Fatal(err)
+	}
+
+	for {
+		err := pollSources(sources, options)
+		if *onceFlag {
+			if err != nil {
+				log.Print(err)
+				return 1
+			}
+			return 0
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		time.Sleep(*intervalFlag)
+	}
+}
+
+// pollSources lists the links of the sources once and compares them with the
+// snapshot in the state file, reporting the changes and optionally downloading the
+// added links that pass the filters, then replaces the snapshot. The first poll only
+// records the snapshot. If any source cannot be read, the snapshot is kept, so that
+// its links are not reported as removed. Links whose download failed are left out
+// of the new snapshot, so that the next poll reports and downloads them again.
+func pollSources(sources []source, options watchOptions) error {
+	links, failedSources, err := fetchAllLinks(sources, options.mode)
+	if err == nil && failedSources > 0 {
+		err = fmt.Errorf("%d of %d sources could not be read", failedSources, len(sources))
+	}
+	if err != nil {
+		return fmt.Errorf("skipping poll: %w", err)
+	}
+
+	current := &snapshot.Snapshot{Taken: time.Now().UTC(), Links: links}
+	for _, src := range sources {
+		current.Sources = append(current.Sources, src.name())
+	}
+
+	previous, err := snapshot.Load(options.state)
+	if os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "Recorded %d links in %s\n", len(links), options.state)
+		return snapshot.Save(options.state, current)
+	}
+	if err != nil {
+		return err
+	}
+
+	diff := snapshot.Compare(previous.Links, links)
+	if !diff.Empty() {
+		if err := printWatchDiff(current.Taken, diff, options.output); err != nil {
+			return err
+		}
+		if options.download {
+			wanted, err := options.filters.apply(diff.Added)
+			if err != nil {
+				return err
+			}
+			failed := downloadAdded(wanted, options.ignoreCert)
+			if len(failed) > 0 {
+				kept := make([]common.Link, 0, len(current.Links))
+				for _, link := range current.Links {
+					if !failed[link.URL] {
+						kept = append(kept, link)
+					}
+				}
+				current.Links = kept
+			}
+		}
+	}
+
+	return snapshot.Save(options.state, current)
+}
+
+// downloadAdded downloads the links added since the last poll and returns the URLs
+// of those that failed and should be retried. Links whose file already exists and
+// links robots.txt disallows are not retried.
+func downloadAdded(links []common.Link, ignoreCert bool) map[string]bool {
+	failed := make(map[string]bool)
+	for i, link := range links {
+		fmt.Fprintf(statusOutput, "[%d/%d] Downloading: %s\n", i+1, len(links), link.URL)
+		err := downloader.DownloadLink(link, ignoreCert, true)
+		switch {
+		case err == nil:
+		case errors.Is(err, downloader.ErrFileExists), errors.Is(err, robots.ErrDisallowed):
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		default:
+			fmt.Fprintf(os.Stderr, "Warning: error downloading %s, retrying on the next poll: %v\n", link.URL, err)
+			failed[link.URL] = true
+		}
+	}
+	return failed
+}
+
+// printWatchDiff prints the changes found by a poll: in txt format, a line with the
+// time and counts followed by the changes; in json format, one object per poll
+func printWatchDiff(taken time.Time, diff snapshot.Diff, format string) error {
+	if format == "json" {
+		data, err := json.Marshal(struct {
+			Time time.Time `json:"time"`
+			snapshot.Diff
+		}{taken, diff})
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("%s: %d added, %d removed, %d changed\n",
+		taken.Format(time.RFC3339), len(diff.Added), len(diff.Removed), len(diff.Changed))
+	return snapshot.PrintDiff(diff, "txt", "", "")
+}
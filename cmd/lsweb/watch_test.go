@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/hemzaz/lsweb/pkg/common"
+	"github.com/hemzaz/lsweb/pkg/downloader"
+	"github.com/hemzaz/lsweb/pkg/robots"
+	"github.com/hemzaz/lsweb/pkg/snapshot"
+)
+
+func TestPollSourcesRetriesFailedDownloads(t *testing.T) {
+	var mu sync.Mutex
+	files := []string{"a.bin"}
+	broken := true
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			for _, name := range files {
+				fmt.Fprintf(w, `<a href="/%s">%s</a>`, name, name)
+			}
+		case "/b.bin":
+			if broken {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprint(w, "b")
+		default:
+			fmt.Fprint(w, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	defer robots.Reset()
+
+	dir := t.TempDir()
+	downloader.SetOutputDir(filepath.Join(dir, "downloads"))
+	defer downloader.SetOutputDir("")
+
+	options := watchOptions{
+		filters:  linkFilters{filter: `\.bin$`},
+		state:    filepath.Join(dir, "state.json"),
+		output:   "txt",
+		download: true,
+	}
+	sources := []source{{location: server.URL + "/"}}
+	stateURLs := func() map[string]bool {
+		state, err := snapshot.Load(options.state)
+		if err != nil {
+			t.Fatalf("Failed to load the snapshot: %v", err)
+		}
+		urls := make(map[string]bool)
+		for _, url := range common.URLs(state.Links) {
+			urls[url] = true
+		}
+		return urls
+	}
+
+	// The first poll records the snapshot
+	if err := pollSources(sources, options); err != nil {
+		t.Fatalf("First poll failed: %v", err)
+	}
+
+	// b.bin fails to download and is left out of the snapshot; c.txt is watched but
+	// not downloaded, since it does not match -filter
+	mu.Lock()
+	files = []string{"a.bin", "b.bin", "c.txt"}
+	mu.Unlock()
+	if err := pollSources(sources, options); err != nil {
+		t.Fatalf("Second poll failed: %v", err)
+	}
+	urls := stateURLs()
+	if urls[server.URL+"/b.bin"] || !urls[server.URL+"/c.txt"] || !urls[server.URL+"/a.bin"] {
+		t.Errorf("Expected the snapshot to hold a.bin and c.txt but not b.bin, got %v", urls)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "downloads", "c.txt")); !os.IsNotExist(err) {
+		t.Error("Expected c.txt not to be downloaded")
+	}
+
+	// b.bin is reported again and downloaded once the server recovers
+	mu.Lock()
+	broken = false
+	mu.Unlock()
+	if err := pollSources(sources, options); err != nil {
+		t.Fatalf("Third poll failed: %v", err)
+	}
+	if !stateURLs()[server.URL+"/b.bin"] {
+		t.Error("Expected b.bin to be in the snapshot after it was downloaded")
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "downloads", "b.bin")); err != nil || string(data) != "b" {
+		t.Errorf("Expected b.bin to be downloaded, got %q (error: %v)", data, err)
+	}
+}
@@ -3,14 +3,21 @@ module github.com/hemzaz/lsweb
 go 1.22
 
 require (
+	github.com/andybalholm/brotli v1.1.1
+	github.com/andybalholm/cascadia v1.3.2
+	github.com/antchfx/htmlquery v1.3.0
+	github.com/antchfx/xpath v1.3.3
+	github.com/klauspost/compress v1.17.9
 	github.com/schollz/progressbar/v3 v3.13.1
 	golang.org/x/net v0.14.0
 )
 
 require (
+	github.com/golang/groupcache v0.0.0-20210331224755-41bb18bfe9da // indirect
 	github.com/mattn/go-runewidth v0.0.15 // indirect
 	github.com/mitchellh/colorstring v0.0.0-20190213212951-d06e56a500db // indirect
 	github.com/rivo/uniseg v0.4.4 // indirect
 	golang.org/x/sys v0.11.0 // indirect
 	golang.org/x/term v0.11.0 // indirect
+	golang.org/x/text v0.12.0 // indirect
 )
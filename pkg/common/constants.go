@@ -1,4 +1,4 @@
-// Package common provides shared constants and utilities for the lsweb application.
+// Package common provides shared constants, types and utilities for the lsweb application.
 package common
 
 import "time"
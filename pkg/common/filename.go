@@ -0,0 +1,56 @@
+package common
+
+import (
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxFileNameLength is the longest file name, in bytes, that common filesystems accept
+const MaxFileNameLength = 255
+
+// windowsDeviceNames are the names Windows reserves for devices, with or without an extension
+var windowsDeviceNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// SanitizeFileName makes a name safe to use as a single file name on Unix, macOS and
+// Windows. Control characters and the characters /\:*?"<>| become underscores,
+// trailing dots and spaces are dropped, Windows device names such as CON get an
+// underscore prefix, and names longer than MaxFileNameLength are shortened, keeping
+// the extension. Names that are empty, "." or ".." become "_".
+func SanitizeFileName(name string) string {
+	name = strings.ToValidUTF8(name, "_")
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20 || r == 0x7f:
+			return '_'
+		case strings.ContainsRune(`/\:*?"<>|`, r):
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimRight(name, ". ")
+
+	if name == "" {
+		return "_"
+	}
+	if stem, _, _ := strings.Cut(name, "."); windowsDeviceNames[strings.ToUpper(stem)] {
+		name = "_" + name
+	}
+
+	if len(name) > MaxFileNameLength {
+		ext := path.Ext(name)
+		if len(ext) > MaxFileNameLength/8 {
+			ext = ""
+		}
+		stem := name[:MaxFileNameLength-len(ext)]
+		for !utf8.ValidString(stem) {
+			stem = stem[:len(stem)-1]
+		}
+		name = stem + ext
+	}
+	return name
+}
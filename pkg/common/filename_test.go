@@ -0,0 +1,40 @@
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeFileName(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"Plain", "app-1.2.tar.gz", "app-1.2.tar.gz"},
+		{"Unicode", "résumé 2026.pdf", "résumé 2026.pdf"},
+		{"Separators", "../etc/passwd", ".._etc_passwd"},
+		{"Reserved characters", `a:b*c?"d"<e>|f\g`, "a_b_c__d__e__f_g"},
+		{"Control characters", "a\x00b\nc", "a_b_c"},
+		{"Trailing dots and spaces", "report. . ", "report"},
+		{"Dot", ".", "_"},
+		{"Dot dot", "..", "_"},
+		{"Empty", "", "_"},
+		{"Device name", "con.txt", "_con.txt"},
+		{"Device name prefix", "console.txt", "console.txt"},
+		{"Invalid UTF-8", "a\xffb", "a_b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeFileName(tt.input); got != tt.expected {
+				t.Errorf("SanitizeFileName(%q) = %q, expected %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+
+	long := SanitizeFileName(strings.Repeat("é", 200) + ".tar.gz")
+	if len(long) > MaxFileNameLength || !strings.HasSuffix(long, "é.gz") {
+		t.Errorf("Expected a long name to be shortened to %d bytes keeping its extension, got %d bytes: %q", MaxFileNameLength, len(long), long)
+	}
+}
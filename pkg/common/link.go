@@ -0,0 +1,99 @@
+package common
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Link is a URL found in a source together with the metadata known about it.
+// Only URL is always set; the other fields are filled in when the source provides them.
+type Link struct {
+	// URL is the absolute URL of the link
+	URL string `json:"url"`
+
+	// Text is the anchor text, image alt text or listing name
+	Text string `json:"text,omitempty"`
+
+	// Title is the title attribute of the element
+	Title string `json:"title,omitempty"`
+
+	// Rel is the rel attribute of the element
+	Rel string `json:"rel,omitempty"`
+
+	// Element and Attribute name where the link was found, such as "img" and "srcset"
+	Element   string `json:"element,omitempty"`
+	Attribute string `json:"attribute,omitempty"`
+
+	// Source is the page, file or listing the link came from
+	Source string `json:"source,omitempty"`
+
+	// Position is the 1-based position of the link within its source
+	Position int `json:"position,omitempty"`
+
+	// Size is the size in bytes reported by a listing, or zero if unknown
+	Size int64 `json:"size,omitempty"`
+
+	// Modified is the modification time reported by a listing, or the zero time if unknown
+	Modified time.Time `json:"modified"`
+
+	// ETag is the entity tag reported by a listing
+	ETag string `json:"etag,omitempty"`
+
+	// IsDir is set for directories in directory listings and prefixes in bucket listings
+	IsDir bool `json:"is_dir,omitempty"`
+
+	// Release is the release tag for GitHub release assets
+	Release string `json:"release,omitempty"`
+
+	// Priority and ChangeFreq are the sitemap hints for the page
+	Priority   string `json:"priority,omitempty"`
+	ChangeFreq string `json:"changefreq,omitempty"`
+}
+
+// MarshalJSON encodes the link, omitting Modified when it is the zero time.
+func (l Link) MarshalJSON() ([]byte, error) {
+	type plainLink Link
+	aux := struct {
+		plainLink
+		Modified *time.Time `json:"modified,omitempty"`
+	}{plainLink: plainLink(l)}
+
+	if !l.Modified.IsZero() {
+		aux.Modified = &l.Modified
+	}
+	return json.Marshal(aux)
+}
+
+// URLs returns the URL of each link, in order.
+func URLs(links []Link) []string {
+	urls := make([]string, 0, len(links))
+	for _, link := range links {
+		urls = append(urls, link.URL)
+	}
+	return urls
+}
+
+// LinksFromURLs wraps plain URLs in links that carry no metadata.
+func LinksFromURLs(urls []string) []Link {
+	links := make([]Link, 0, len(urls))
+	for _, u := range urls {
+		links = append(links, Link{URL: u})
+	}
+	return links
+}
+
+// MergeLinks combines lists of links from several sources in order, keeping the
+// first link found for each URL together with its Source and other metadata.
+func MergeLinks(lists ...[]Link) []Link {
+	var merged []Link
+	seen := make(map[string]bool)
+	for _, links := range lists {
+		for _, link := range links {
+			if !seen[link.URL] {
+				seen[link.URL] = true
+				merged = append(merged, link)
+			}
+		}
+	}
+	return merged
+}
@@ -0,0 +1,73 @@
+package common
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLinkMarshalJSON(t *testing.T) {
+	data, err := json.Marshal(Link{URL: "https://example.com/a"})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(data) != `{"url":"https://example.com/a"}` {
+		t.Errorf("Expected only the URL for a link without metadata, got %s", data)
+	}
+
+	modified := time.Date(2024, 3, 2, 10, 30, 0, 0, time.UTC)
+	data, err = json.Marshal(Link{URL: "https://example.com/a", Size: 42, Modified: modified})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !strings.Contains(string(data), `"modified":"2024-03-02T10:30:00Z"`) || !strings.Contains(string(data), `"size":42`) {
+		t.Errorf("Expected size and modified in JSON, got %s", data)
+	}
+
+	var decoded Link
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !decoded.Modified.Equal(modified) || decoded.Size != 42 {
+		t.Errorf("Round trip lost metadata: %+v", decoded)
+	}
+}
+
+func TestURLsAndLinksFromURLs(t *testing.T) {
+	urls := []string{"https://example.com/1", "https://example.com/2"}
+
+	links := LinksFromURLs(urls)
+	if len(links) != len(urls) {
+		t.Fatalf("Expected %d links, got %d", len(urls), len(links))
+	}
+
+	result := URLs(links)
+	for i, u := range result {
+		if u != urls[i] {
+			t.Errorf("Expected %s at position %d, got %s", urls[i], i, u)
+		}
+	}
+}
+
+func TestMergeLinks(t *testing.T) {
+	first := []Link{
+		{URL: "https://example.com/a", Source: "https://example.com/"},
+		{URL: "https://example.com/b", Source: "https://example.com/"},
+	}
+	second := []Link{
+		{URL: "https://example.com/b", Source: "links.html", Text: "B"},
+		{URL: "https://example.com/c", Source: "links.html"},
+	}
+
+	merged := MergeLinks(first, nil, second)
+	expected := []Link{first[0], first[1], second[1]}
+	if len(merged) != len(expected) {
+		t.Fatalf("Expected %d links, got %d: %+v", len(expected), len(merged), merged)
+	}
+	for i, link := range merged {
+		if link != expected[i] {
+			t.Errorf("Expected %+v at position %d, got %+v", expected[i], i, link)
+		}
+	}
+}
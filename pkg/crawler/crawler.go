@@ -0,0 +1,269 @@
+// Package crawler follows links breadth-first from a start page, listing the pages
+// it visits separately from the files (leaf links) those pages link to.
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	// Internal dependencies
+	"github.com/hemzaz/lsweb/pkg/common"
+	"github.com/hemzaz/lsweb/pkg/parser"
+	"github.com/hemzaz/lsweb/pkg/robots"
+)
+
+// Scope rules for which pages are followed
+const (
+	// ScopeHost follows pages on the start URL's host
+	ScopeHost = "host"
+
+	// ScopePrefix follows pages on the start URL's host under its directory
+	ScopePrefix = "prefix"
+)
+
+// Default crawl limits
+const (
+	DefaultMaxDepth = 3
+	DefaultMaxPages = 100
+)
+
+// pageExtensions lists the extensions of URLs that are crawled as pages rather than
+// reported as files. URLs without an extension or ending in a slash are pages too.
+var pageExtensions = map[string]bool{
+	".html":  true,
+	".htm":   true,
+	".xhtml": true,
+	".shtml": true,
+	".php":   true,
+	".asp":   true,
+	".aspx":  true,
+	".jsp":   true,
+	".cgi":   true,
+}
+
+// Options configures a crawl
+type Options struct {
+	// MaxDepth is how many links away from the start page pages are followed;
+	// 0 fetches only the start page
+	MaxDepth int
+
+	// MaxPages is the most pages fetched, including the start page
+	MaxPages int
+
+	// Scope is ScopeHost or ScopePrefix
+	Scope string
+
+	// AllowedDomains lists further hosts whose pages are followed, including their subdomains
+	AllowedDomains []string
+
+	// IgnoreCert skips TLS certificate validation
+	IgnoreCert bool
+}
+
+// DefaultOptions returns the options used when none are given: same host, depth 3, 100 pages
+func DefaultOptions() Options {
+	return Options{
+		MaxDepth: DefaultMaxDepth,
+		MaxPages: DefaultMaxPages,
+		Scope:    ScopeHost,
+	}
+}
+
+// Page is a page the crawler fetched
+type Page struct {
+	URL    string
+	Depth  int
+	Parent string
+	Links  int
+	Err    error
+}
+
+// Link converts the page to a link recording the page that linked to it as its source
+// and its depth as its position.
+func (p Page) Link() common.Link {
+	return common.Link{URL: p.URL, Source: p.Parent, Position: p.Depth}
+}
+
+// Result holds what a crawl found
+type Result struct {
+	// Pages lists the pages fetched, in the order they were visited
+	Pages []Page
+
+	// Files lists the leaf links found on the pages, each attributed to the first
+	// page it was found on
+	Files []common.Link
+
+	// Truncated is set when the page budget stopped the crawl with pages left to visit
+	Truncated bool
+}
+
+// PageLinks returns the crawled pages as links.
+func (r *Result) PageLinks() []common.Link {
+	links := make([]common.Link, 0, len(r.Pages))
+	for _, page := range r.Pages {
+		links = append(links, page.Link())
+	}
+	return links
+}
+
+// Crawl fetches startURL and follows the links to pages within scope breadth-first,
+// up to options.MaxDepth links away and options.MaxPages pages in total.
+// Links are extracted with parser.FetchLinks, so the parser's tag, selector and
+// JSONPath settings apply to every page. Each fetch goes through robots.Wait, so
+// pages robots.txt disallows are recorded with robots.ErrDisallowed. URLs are
+// compared after normalization, so each page is fetched and each file reported
+// once. Links to pages that turn out to serve files are reported as files. Pages
+// that fail to load are recorded with their error; only a failure of the start
+// page is returned as an error.
+func Crawl(startURL string, options Options) (*Result, error) {
+	start, err := url.Parse(startURL)
+	if err != nil || start.Host == "" {
+		return nil, fmt.Errorf("invalid start URL: %s", startURL)
+	}
+	if options.MaxPages <= 0 {
+		options.MaxPages = DefaultMaxPages
+	}
+	if options.MaxDepth < 0 {
+		options.MaxDepth = 0
+	}
+	switch options.Scope {
+	case "":
+		options.Scope = ScopeHost
+	case ScopeHost, ScopePrefix:
+	default:
+		return nil, fmt.Errorf("invalid scope: %s (valid scopes: %s, %s)", options.Scope, ScopeHost, ScopePrefix)
+	}
+
+	type queued struct {
+		url    string
+		depth  int
+		parent string
+	}
+
+	result := &Result{}
+	seen := map[string]bool{NormalizeURL(start): true}
+	queue := []queued{{url: start.String()}}
+
+	for len(queue) > 0 {
+		if len(result.Pages) >= options.MaxPages {
+			result.Truncated = true
+			break
+		}
+
+		current := queue[0]
+		queue = queue[1:]
+
+		// robots.txt is honoured and requests to a host spaced out before each fetch
+		var links []common.Link
+		err := robots.Wait(current.url, options.IgnoreCert)
+		if err == nil {
+			links, err = parser.FetchLinks(current.url, options.IgnoreCert)
+		}
+		if errors.Is(err, parser.ErrUnsupportedContentType) && current.depth > 0 {
+			// The link looked like a page but serves a file
+			result.Files = append(result.Files, common.Link{URL: current.url, Source: current.parent})
+			continue
+		}
+
+		page := Page{URL: current.url, Depth: current.depth, Parent: current.parent, Links: len(links), Err: err}
+		result.Pages = append(result.Pages, page)
+		if err != nil {
+			if current.depth == 0 {
+				return nil, err
+			}
+			continue
+		}
+
+		for _, link := range links {
+			target, err := url.Parse(link.URL)
+			if err != nil || (target.Scheme != "http" && target.Scheme != "https") {
+				continue
+			}
+
+			key := NormalizeURL(target)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			if !isPage(target, link) {
+				result.Files = append(result.Files, link)
+				continue
+			}
+			if current.depth < options.MaxDepth && InScope(target, start, options) {
+				queue = append(queue, queued{url: link.URL, depth: current.depth + 1, parent: current.url})
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// Helper function to report whether a link should be crawled as a page: directory
+// listing entries, URLs ending in a slash, and URLs with no or a page-like extension
+func isPage(target *url.URL, link common.Link) bool {
+	if link.IsDir || target.Path == "" || strings.HasSuffix(target.Path, "/") {
+		return true
+	}
+	ext := strings.ToLower(path.Ext(target.Path))
+	return ext == "" || pageExtensions[ext]
+}
+
+// InScope reports whether a crawl from start with options follows a page URL: one on
+// an allowed domain, or on the start URL's host and, with ScopePrefix, under its directory.
+func InScope(target, start *url.URL, options Options) bool {
+	host := strings.ToLower(target.Hostname())
+	for _, domain := range options.AllowedDomains {
+		domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "."))
+		if domain != "" && (host == domain || strings.HasSuffix(host, "."+domain)) {
+			return true
+		}
+	}
+
+	if !strings.EqualFold(target.Host, start.Host) || target.Scheme != start.Scheme {
+		return false
+	}
+	if options.Scope == ScopePrefix {
+		prefix := start.Path[:strings.LastIndex(start.Path, "/")+1]
+		return strings.HasPrefix(target.Path, prefix)
+	}
+	return true
+}
+
+// NormalizeURL normalizes a URL for de-duplication: the scheme and host are
+// lowercased, default ports and fragments dropped, an empty path becomes "/", dot
+// segments are resolved and query parameters are sorted.
+func NormalizeURL(u *url.URL) string {
+	normalized := *u
+	normalized.Scheme = strings.ToLower(normalized.Scheme)
+	normalized.Host = strings.ToLower(normalized.Host)
+	normalized.Fragment = ""
+	normalized.RawFragment = ""
+
+	if port := normalized.Port(); (normalized.Scheme == "http" && port == "80") ||
+		(normalized.Scheme == "https" && port == "443") {
+		normalized.Host = normalized.Hostname()
+	}
+
+	if normalized.Path == "" {
+		normalized.Path = "/"
+		normalized.RawPath = ""
+	} else {
+		// Resolving an absolute URL against itself removes its dot segments
+		normalized = *normalized.ResolveReference(&normalized)
+	}
+
+	if normalized.RawQuery != "" {
+		query := normalized.Query()
+		for _, values := range query {
+			sort.Strings(values)
+		}
+		normalized.RawQuery = query.Encode()
+	}
+
+	return normalized.String()
+}
@@ -0,0 +1,260 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/hemzaz/lsweb/pkg/common"
+	"github.com/hemzaz/lsweb/pkg/robots"
+)
+
+func TestMain(m *testing.M) {
+	// The test servers are local, so there is no need to space out requests
+	robots.SetHostDelay(0)
+	os.Exit(m.Run())
+}
+
+// newTestSite serves a small site: a home page linking to a docs section and a
+// release directory, which links on to nested pages and files
+func newTestSite(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	pages := map[string]string{
+		"/": `<a href="/docs/">Docs</a> <a href="/pub/releases/">Releases</a>
+			<a href="https://external.example.com/page">External</a> <a href="/logo.png">Logo</a>`,
+		"/docs/": `<a href="guide.html">Guide</a> <a href="/./docs/guide.html#intro">Guide again</a>
+			<a href="/manual.pdf">Manual</a>`,
+		"/docs/guide.html":       `<a href="/docs/deep/">Deeper</a> <a href="/">Home</a>`,
+		"/docs/deep/":            `<a href="/docs/deep/file.tar.gz">File</a>`,
+		"/pub/releases/":         `<a href="v1/">v1</a> <a href="app.zip?b=2&a=1">App</a> <a href="app.zip?a=1&b=2">Same app</a> <a href="download">Download</a>`,
+		"/pub/releases/v1/":      `<a href="app-1.0.tar.gz">1.0</a> <a href="/pub/other/">Other</a>`,
+		"/pub/other/":            `<a href="other.tar.gz">Other</a>`,
+		"/pub/releases/download": "",
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/pub/releases/download" {
+			// Looks like a page but serves a file
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write([]byte{0, 1, 2})
+			return
+		}
+		body, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<html><body>%s</body></html>", body)
+	}))
+}
+
+// pathsOf returns the sorted paths of URLs on the test server
+func pathsOf(server *httptest.Server, urls []string) []string {
+	var paths []string
+	for _, u := range urls {
+		paths = append(paths, strings.TrimPrefix(u, server.URL))
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestCrawl(t *testing.T) {
+	server := newTestSite(t)
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		start     string
+		options   Options
+		pages     []string
+		files     []string
+		truncated bool
+	}{
+		{
+			name:    "Start page only",
+			start:   "/",
+			options: Options{MaxDepth: 0, MaxPages: 10},
+			pages:   []string{"/"},
+			files:   []string{"/logo.png"},
+		},
+		{
+			name:    "Depth 1",
+			start:   "/",
+			options: Options{MaxDepth: 1, MaxPages: 10},
+			pages:   []string{"/", "/docs/", "/pub/releases/"},
+			files:   []string{"/logo.png", "/manual.pdf", "/pub/releases/app.zip?b=2&a=1"},
+		},
+		{
+			name:    "Whole site",
+			start:   "/",
+			options: DefaultOptions(),
+			pages:   []string{"/", "/docs/", "/docs/deep/", "/docs/guide.html", "/pub/other/", "/pub/releases/", "/pub/releases/v1/"},
+			files:   []string{"/docs/deep/file.tar.gz", "/logo.png", "/manual.pdf", "/pub/other/other.tar.gz", "/pub/releases/app.zip?b=2&a=1", "/pub/releases/download", "/pub/releases/v1/app-1.0.tar.gz"},
+		},
+		{
+			name:    "Path prefix",
+			start:   "/pub/releases/",
+			options: Options{MaxDepth: 5, Scope: ScopePrefix},
+			pages:   []string{"/pub/releases/", "/pub/releases/v1/"},
+			files:   []string{"/pub/releases/app.zip?b=2&a=1", "/pub/releases/download", "/pub/releases/v1/app-1.0.tar.gz"},
+		},
+		{
+			name:      "Page budget",
+			start:     "/",
+			options:   Options{MaxDepth: 5, MaxPages: 2},
+			pages:     []string{"/", "/docs/"},
+			files:     []string{"/logo.png", "/manual.pdf"},
+			truncated: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Crawl(server.URL+tt.start, tt.options)
+			if err != nil {
+				t.Fatalf("Crawl failed: %v", err)
+			}
+
+			pages := pathsOf(server, common.URLs(result.PageLinks()))
+			if fmt.Sprint(pages) != fmt.Sprint(tt.pages) {
+				t.Errorf("Expected pages %v, got %v", tt.pages, pages)
+			}
+			files := pathsOf(server, common.URLs(result.Files))
+			if fmt.Sprint(files) != fmt.Sprint(tt.files) {
+				t.Errorf("Expected files %v, got %v", tt.files, files)
+			}
+			if result.Truncated != tt.truncated {
+				t.Errorf("Expected truncated %v, got %v", tt.truncated, result.Truncated)
+			}
+		})
+	}
+}
+
+func TestCrawlAttribution(t *testing.T) {
+	server := newTestSite(t)
+	defer server.Close()
+
+	result, err := Crawl(server.URL+"/", DefaultOptions())
+	if err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+
+	for _, page := range result.Pages {
+		if page.URL == server.URL+"/docs/deep/" {
+			if page.Depth != 3 || page.Parent != server.URL+"/docs/guide.html" {
+				t.Errorf("Unexpected page %+v", page)
+			}
+		}
+	}
+	for _, file := range result.Files {
+		if file.URL == server.URL+"/docs/deep/file.tar.gz" && file.Source != server.URL+"/docs/deep/" {
+			t.Errorf("Expected the file to be attributed to its page, got %+v", file)
+		}
+	}
+}
+
+func TestCrawlAllowedDomains(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<a href="/mirror.iso">ISO</a>`)
+	}))
+	defer other.Close()
+
+	home := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<a href="%s/mirrors/">Mirrors</a>`, other.URL)
+	}))
+	defer home.Close()
+
+	result, err := Crawl(home.URL, Options{MaxDepth: 2})
+	if err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+	if len(result.Pages) != 1 || len(result.Files) != 0 {
+		t.Errorf("Expected the other host to be out of scope, got %+v", result)
+	}
+
+	otherURL, _ := url.Parse(other.URL)
+	result, err = Crawl(home.URL, Options{MaxDepth: 2, AllowedDomains: []string{otherURL.Hostname()}})
+	if err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+	if len(result.Pages) != 2 || len(result.Files) != 1 || result.Files[0].URL != other.URL+"/mirror.iso" {
+		t.Errorf("Expected the allowed domain to be crawled, got %+v", result)
+	}
+}
+
+func TestCrawlErrors(t *testing.T) {
+	if _, err := Crawl("not a url", DefaultOptions()); err == nil {
+		t.Error("Expected an error for an invalid start URL")
+	}
+	if _, err := Crawl("https://example.com/", Options{Scope: "planet"}); err == nil {
+		t.Error("Expected an error for an invalid scope")
+	}
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	if _, err := Crawl(server.URL, DefaultOptions()); err == nil {
+		t.Error("Expected an error when the start page fails")
+	}
+}
+
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"HTTP://Example.COM", "http://example.com/"},
+		{"https://example.com:443/a/./b/../c#frag", "https://example.com/a/c"},
+		{"http://example.com:8080/x?b=2&a=1", "http://example.com:8080/x?a=1&b=2"},
+		{"http://example.com/a%20b", "http://example.com/a%20b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			u, _ := url.Parse(tt.input)
+			if normalized := NormalizeURL(u); normalized != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, normalized)
+			}
+		})
+	}
+}
+
+func TestCrawlRobots(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprint(w, "User-agent: *\nDisallow: /private/\n")
+		case "/private/":
+			t.Error("Fetched a page disallowed by robots.txt")
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<a href="/private/">Private</a> <a href="/public/">Public</a>`)
+		}
+	}))
+	defer server.Close()
+	defer robots.Reset()
+
+	result, err := Crawl(server.URL+"/", DefaultOptions())
+	if err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+	for _, page := range result.Pages {
+		disallowed := errors.Is(page.Err, robots.ErrDisallowed)
+		if disallowed != (page.URL == server.URL+"/private/") {
+			t.Errorf("Unexpected page %+v", page)
+		}
+	}
+
+	if _, err := Crawl(server.URL+"/private/", DefaultOptions()); !errors.Is(err, robots.ErrDisallowed) {
+		t.Errorf("Expected a disallowed start page to fail, got %v", err)
+	}
+}
@@ -0,0 +1,356 @@
+package downloader
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	// Internal dependencies
+	"github.com/hemzaz/lsweb/pkg/common"
+	"github.com/hemzaz/lsweb/pkg/robots"
+)
+
+// maxCheckRedirects is the longest redirect chain followed when checking a link
+const maxCheckRedirects = 10
+
+// Redirect is one hop of a redirect chain
+type Redirect struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"status"`
+}
+
+// CheckResult is the outcome of checking one link
+type CheckResult struct {
+	// Link is the link that was checked
+	Link common.Link
+
+	// Method is the request method that produced the result: HEAD, or GET when HEAD failed
+	Method string
+
+	// StatusCode is the status of the final response, or zero if there was none
+	StatusCode int
+
+	// FinalURL is the URL the redirects ended at
+	FinalURL string
+
+	// Redirects lists the redirect responses followed, in order
+	Redirects []Redirect
+
+	// Latency is the time from sending the request to receiving the final response headers
+	Latency time.Duration
+
+	// Err is set when the link could not be requested
+	Err error
+}
+
+// Skipped reports whether the link was not checked because robots.txt disallows it.
+func (r CheckResult) Skipped() bool {
+	return errors.Is(r.Err, robots.ErrDisallowed)
+}
+
+// Broken reports whether the link failed: it could not be requested or the final
+// response has a 4xx or 5xx status. Skipped links are not broken.
+func (r CheckResult) Broken() bool {
+	if r.Err != nil {
+		return !r.Skipped()
+	}
+	return r.StatusCode >= 400
+}
+
+// MarshalJSON encodes the result with its link's URL and source, its latency in
+// milliseconds and its error as a string.
+func (r CheckResult) MarshalJSON() ([]byte, error) {
+	aux := struct {
+		URL        string     `json:"url"`
+		Source     string     `json:"source,omitempty"`
+		Method     string     `json:"method,omitempty"`
+		StatusCode int        `json:"status,omitempty"`
+		FinalURL   string     `json:"final_url,omitempty"`
+		Redirects  []Redirect `json:"redirects,omitempty"`
+		LatencyMS  int64      `json:"latency_ms"`
+		Broken     bool       `json:"broken"`
+		Skipped    bool       `json:"skipped,omitempty"`
+		Error      string     `json:"error,omitempty"`
+	}{
+		URL:        r.Link.URL,
+		Source:     r.Link.Source,
+		Method:     r.Method,
+		StatusCode: r.StatusCode,
+		FinalURL:   r.FinalURL,
+		Redirects:  r.Redirects,
+		LatencyMS:  r.Latency.Milliseconds(),
+		Broken:     r.Broken(),
+		Skipped:    r.Skipped(),
+	}
+	if r.Err != nil {
+		aux.Error = r.Err.Error()
+	}
+	return json.Marshal(aux)
+}
+
+// CheckLinks requests each link concurrently, at most maxConcurrentDownloads at a time,
+// and returns the results in the order of the links. Each link is first requested
+// with HEAD; if that fails or returns an error status, GET is tried, since some
+// servers do not support HEAD. Redirects are followed and recorded. Requests go
+// through robots.Wait, so links robots.txt disallows are skipped rather than checked.
+// The ignoreCert parameter can be used to skip TLS certificate validation.
+func CheckLinks(links []common.Link, ignoreCert bool) []CheckResult {
+	results := make([]CheckResult, len(links))
+	sem := make(chan struct{}, maxConcurrentDownloads)
+
+	var wg sync.WaitGroup
+	for i, link := range links {
+		wg.Add(1)
+		go func(i int, link common.Link) {
+			sem <- struct{}{}
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			results[i] = checkLink(link, ignoreCert)
+		}(i, link)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// Helper function to check one link, falling back from HEAD to GET
+func checkLink(link common.Link, ignoreCert bool) CheckResult {
+	if err := robots.Wait(link.URL, ignoreCert); err != nil {
+		return CheckResult{Link: link, Err: err}
+	}
+
+	result := checkRequest(link, "HEAD", ignoreCert)
+	if result.Err != nil || result.StatusCode >= 400 {
+		if err := robots.Wait(link.URL, ignoreCert); err != nil {
+			return CheckResult{Link: link, Err: err}
+		}
+		result = checkRequest(link, "GET", ignoreCert)
+	}
+	return result
+}
+
+// Helper function to request a link with the given method, recording its redirects
+func checkRequest(link common.Link, method string, ignoreCert bool) CheckResult {
+	result := CheckResult{Link: link, Method: method}
+
+	client := &http.Client{
+		Timeout: defaultTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxCheckRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxCheckRedirects)
+			}
+			result.Redirects = append(result.Redirects, Redirect{
+				URL:        via[len(via)-1].URL.String(),
+				StatusCode: req.Response.StatusCode,
+			})
+			return nil
+		},
+	}
+	if ignoreCert {
+		client.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, link.URL, nil)
+	if err != nil {
+		result.Err = fmt.Errorf("error creating request: %w", err)
+		return result
+	}
+	req.Header.Set("User-Agent", common.UserAgent)
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	result.Latency = time.Since(start)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	// The body is not needed; closing it without reading ends a GET early
+	resp.Body.Close()
+
+	result.StatusCode = resp.StatusCode
+	result.FinalURL = resp.Request.URL.String()
+	return result
+}
+
+// PrintCheckResults prints the results to stdout in the given format: "table" for an
+// aligned table followed by a summary, "json" for a JSON array of objects, or "junit"
+// for a JUnit XML report with one test case per link, which CI systems can display.
+// Returns an error if the format is not recognized.
+func PrintCheckResults(results []CheckResult, format string) error {
+	switch format {
+	case "table":
+		return writeCheckTable(os.Stdout, results)
+	case "json":
+		return writeCheckJSON(os.Stdout, results)
+	case "junit":
+		return writeCheckJUnit(os.Stdout, results)
+	default:
+		return fmt.Errorf("invalid check output format: %s (valid formats: table, json, junit)", format)
+	}
+}
+
+// CheckSummary returns a one-line count of the links checked, broken and skipped.
+func CheckSummary(results []CheckResult) string {
+	broken, skipped := 0, 0
+	for _, result := range results {
+		switch {
+		case result.Skipped():
+			skipped++
+		case result.Broken():
+			broken++
+		}
+	}
+	return fmt.Sprintf("Checked %d links: %d ok, %d broken, %d skipped",
+		len(results), len(results)-broken-skipped, broken, skipped)
+}
+
+// Helper function to write the results as a table and a summary line. Each row shows
+// whether the link is OK, BROKEN or SKIPPED, its status, latency and URL, followed by
+// the redirect chain and the error, if any.
+func writeCheckTable(w io.Writer, results []CheckResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RESULT\tSTATUS\tLATENCY\tURL")
+
+	for _, result := range results {
+		state := "OK"
+		switch {
+		case result.Skipped():
+			state = "SKIPPED"
+		case result.Broken():
+			state = "BROKEN"
+		}
+
+		status := "-"
+		if result.StatusCode != 0 {
+			status = fmt.Sprintf("%d", result.StatusCode)
+		}
+
+		detail := result.Link.URL
+		for _, redirect := range result.Redirects {
+			detail += fmt.Sprintf(" -> (%d)", redirect.StatusCode)
+		}
+		if len(result.Redirects) > 0 {
+			detail += " " + result.FinalURL
+		}
+		if result.Err != nil {
+			detail += ": " + result.Err.Error()
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", state, status, result.Latency.Round(time.Millisecond), detail)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintln(w, CheckSummary(results))
+	return err
+}
+
+// Helper function to write the results as a JSON array
+func writeCheckJSON(w io.Writer, results []CheckResult) error {
+	if results == nil {
+		results = []CheckResult{}
+	}
+	data, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// JUnit XML report elements
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// Helper function to write the results as a JUnit XML report. Each link is a test
+// case named by its URL and classed by its source; broken links are failures.
+func writeCheckJUnit(w io.Writer, results []CheckResult) error {
+	suite := junitTestSuite{Name: "lsweb check", Tests: len(results)}
+	var total time.Duration
+
+	for _, result := range results {
+		total += result.Latency
+		testCase := junitTestCase{
+			Name:      result.Link.URL,
+			ClassName: result.Link.Source,
+			Time:      fmt.Sprintf("%.3f", result.Latency.Seconds()),
+		}
+
+		message := ""
+		switch {
+		case result.Err != nil:
+			message = result.Err.Error()
+		case result.StatusCode != 0:
+			message = fmt.Sprintf("%d %s", result.StatusCode, http.StatusText(result.StatusCode))
+		}
+
+		switch {
+		case result.Skipped():
+			suite.Skipped++
+			testCase.Skipped = &junitMessage{Message: message}
+		case result.Broken():
+			suite.Failures++
+			var text strings.Builder
+			fmt.Fprintf(&text, "%s %s", result.Method, result.Link.URL)
+			for _, redirect := range result.Redirects {
+				fmt.Fprintf(&text, "\n%d redirect from %s", redirect.StatusCode, redirect.URL)
+			}
+			if result.FinalURL != "" {
+				fmt.Fprintf(&text, "\nfinal URL %s", result.FinalURL)
+			}
+			testCase.Failure = &junitMessage{Message: message, Text: text.String()}
+		}
+		suite.Cases = append(suite.Cases, testCase)
+	}
+	suite.Time = fmt.Sprintf("%.3f", total.Seconds())
+
+	data, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s%s\n", xml.Header, data)
+	return err
+}
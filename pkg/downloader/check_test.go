@@ -0,0 +1,141 @@
+package downloader
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hemzaz/lsweb/pkg/common"
+	"github.com/hemzaz/lsweb/pkg/robots"
+)
+
+func TestCheckLinks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprint(w, "User-agent: *\nDisallow: /private\n")
+		case "/ok":
+			w.WriteHeader(http.StatusOK)
+		case "/old":
+			http.Redirect(w, r, "/moved", http.StatusMovedPermanently)
+		case "/moved":
+			http.Redirect(w, r, "/ok", http.StatusFound)
+		case "/no-head":
+			if r.Method == "HEAD" {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	defer robots.Reset()
+
+	links := common.LinksFromURLs([]string{
+		server.URL + "/ok",
+		server.URL + "/old",
+		server.URL + "/no-head",
+		server.URL + "/missing",
+		server.URL + "/loop",
+		server.URL + "/private/page",
+	})
+	results := CheckLinks(links, false)
+
+	tests := []struct {
+		method    string
+		status    int
+		redirects int
+		broken    bool
+		skipped   bool
+	}{
+		{"HEAD", 200, 0, false, false},
+		{"HEAD", 200, 2, false, false},
+		{"GET", 200, 0, false, false},
+		{"GET", 404, 0, true, false},
+		{"GET", 0, maxCheckRedirects, true, false},
+		{"", 0, 0, false, true},
+	}
+
+	for i, tt := range tests {
+		result := results[i]
+		t.Run(result.Link.URL, func(t *testing.T) {
+			if result.Method != tt.method || result.StatusCode != tt.status || len(result.Redirects) != tt.redirects {
+				t.Errorf("Expected %s %d with %d redirects, got %s %d with %d redirects",
+					tt.method, tt.status, tt.redirects, result.Method, result.StatusCode, len(result.Redirects))
+			}
+			if result.Broken() != tt.broken || result.Skipped() != tt.skipped {
+				t.Errorf("Expected broken %v and skipped %v, got %v and %v (error: %v)",
+					tt.broken, tt.skipped, result.Broken(), result.Skipped(), result.Err)
+			}
+		})
+	}
+
+	old := results[1]
+	if old.FinalURL != server.URL+"/ok" || old.Redirects[0].URL != server.URL+"/old" || old.Redirects[0].StatusCode != 301 ||
+		old.Redirects[1].URL != server.URL+"/moved" || old.Redirects[1].StatusCode != 302 {
+		t.Errorf("Unexpected redirect chain %+v ending at %s", old.Redirects, old.FinalURL)
+	}
+}
+
+func TestCheckReports(t *testing.T) {
+	results := []CheckResult{
+		{Link: common.Link{URL: "https://example.com/ok", Source: "page"}, Method: "HEAD", StatusCode: 200, FinalURL: "https://example.com/ok"},
+		{Link: common.Link{URL: "https://example.com/gone", Source: "page"}, Method: "GET", StatusCode: 404, FinalURL: "https://example.com/gone"},
+		{Link: common.Link{URL: "https://example.com/private"}, Err: fmt.Errorf("%w: private", robots.ErrDisallowed)},
+		{Link: common.Link{URL: "https://down.example.com/"}, Method: "GET", Err: errors.New("connection refused")},
+	}
+
+	if summary := CheckSummary(results); summary != "Checked 4 links: 1 ok, 2 broken, 1 skipped" {
+		t.Errorf("Unexpected summary %q", summary)
+	}
+
+	var table bytes.Buffer
+	if err := writeCheckTable(&table, results); err != nil {
+		t.Fatalf("writeCheckTable failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(table.String()), "\n")
+	if len(lines) != 6 || !strings.HasPrefix(lines[2], "BROKEN   404") || !strings.HasPrefix(lines[3], "SKIPPED") ||
+		!strings.HasSuffix(lines[4], "connection refused") {
+		t.Errorf("Unexpected table:\n%s", table.String())
+	}
+
+	var jsonOutput bytes.Buffer
+	if err := writeCheckJSON(&jsonOutput, results); err != nil {
+		t.Fatalf("writeCheckJSON failed: %v", err)
+	}
+	var decoded []map[string]interface{}
+	if err := json.Unmarshal(jsonOutput.Bytes(), &decoded); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if len(decoded) != 4 || decoded[1]["broken"] != true || decoded[1]["status"] != float64(404) || decoded[2]["skipped"] != true {
+		t.Errorf("Unexpected JSON: %s", jsonOutput.String())
+	}
+
+	var junit bytes.Buffer
+	if err := writeCheckJUnit(&junit, results); err != nil {
+		t.Fatalf("writeCheckJUnit failed: %v", err)
+	}
+	var report junitTestSuites
+	if err := xml.Unmarshal(junit.Bytes(), &report); err != nil {
+		t.Fatalf("Invalid JUnit XML: %v", err)
+	}
+	suite := report.Suites[0]
+	if suite.Tests != 4 || suite.Failures != 2 || suite.Skipped != 1 ||
+		suite.Cases[1].Failure == nil || suite.Cases[1].Failure.Message != "404 Not Found" || suite.Cases[0].Failure != nil {
+		t.Errorf("Unexpected JUnit report: %s", junit.String())
+	}
+
+	if err := PrintCheckResults(results, "yaml"); err == nil {
+		t.Error("Expected an error for an invalid format")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -16,18 +17,21 @@ import (
 	"sync"
 	"time"
 
-	// Third-party dependencies
-	"github.com/schollz/progressbar/v3"
-
 	// Internal dependencies
 	"github.com/hemzaz/lsweb/pkg/common"
+	"github.com/hemzaz/lsweb/pkg/robots"
 )
 
+// ErrFileExists is returned when the file a link would be saved at already exists
+// and overwriting is off
+var ErrFileExists = errors.New("file already exists")
+
 // Default configuration values
 var (
-	defaultTimeout         = common.DefaultTimeout
-	maxConcurrentDownloads = 5
-	allowOverwriteFiles    = false
+	defaultTimeout                   = common.DefaultTimeout
+	maxConcurrentDownloads           = 5
+	allowOverwriteFiles              = false
+	output                 io.Writer = os.Stdout
 )
 
 // SetTimeout sets the timeout for HTTP requests
@@ -42,6 +46,11 @@ func SetMaxConcurrent(max int) {
 	}
 }
 
+// SetOutput sets where batch downloads report their progress, stdout by default
+func SetOutput(w io.Writer) {
+	output = w
+}
+
 // SetOverwriteFiles sets whether to overwrite existing files
 func SetOverwriteFiles(overwrite bool) {
 	allowOverwriteFiles = overwrite
@@ -53,10 +62,23 @@ type GitHubRelease struct {
 }
 
 // FetchGitHubReleases retrieves download URLs for assets from all releases in a GitHub repository.
-// It parses the repository URL to extract owner and repo name, then queries the GitHub API.
+// It is a convenience wrapper around FetchGitHubReleaseLinks that returns only the URLs.
 // Returns a slice of all asset download URLs or an error if the fetch fails.
 // The ignoreCert parameter can be used to skip TLS certificate validation.
 func FetchGitHubReleases(repoURL string, ignoreCert bool) ([]string, error) {
+	links, err := FetchGitHubReleaseLinks(repoURL, ignoreCert)
+	if err != nil {
+		return nil, err
+	}
+	return common.URLs(links), nil
+}
+
+// FetchGitHubReleaseLinks retrieves assets from all releases in a GitHub repository.
+// It parses the repository URL to extract owner and repo name, then queries the GitHub API.
+// Each asset is returned as a link carrying its name, size, update time and release tag.
+// Returns an error if the fetch fails or no assets are found.
+// The ignoreCert parameter can be used to skip TLS certificate validation.
+func FetchGitHubReleaseLinks(repoURL string, ignoreCert bool) ([]common.Link, error) {
 	// Parse the URL properly
 	parsedURL, err := url.Parse(repoURL)
 	if err != nil {
@@ -122,9 +144,10 @@ func FetchGitHubReleases(repoURL string, ignoreCert bool) ([]string, error) {
 
 	var releases []struct {
 		Assets []struct {
-			BrowserDownloadURL string `json:"browser_download_url"`
-			Name               string `json:"name"`
-			Size               int    `json:"size"`
+			BrowserDownloadURL string    `json:"browser_download_url"`
+			Name               string    `json:"name"`
+			Size               int64     `json:"size"`
+			UpdatedAt          time.Time `json:"updated_at"`
 		} `json:"assets"`
 		TagName string `json:"tag_name"`
 	}
@@ -133,10 +156,18 @@ func FetchGitHubReleases(repoURL string, ignoreCert bool) ([]string, error) {
 		return nil, fmt.Errorf("error parsing GitHub response: %w", err)
 	}
 
-	var downloadLinks []string
+	var downloadLinks []common.Link
 	for _, release := range releases {
 		for _, asset := range release.Assets {
-			downloadLinks = append(downloadLinks, asset.BrowserDownloadURL)
+			downloadLinks = append(downloadLinks, common.Link{
+				URL:      asset.BrowserDownloadURL,
+				Text:     asset.Name,
+				Source:   apiURL,
+				Position: len(downloadLinks) + 1,
+				Size:     asset.Size,
+				Modified: asset.UpdatedAt,
+				Release:  release.TagName,
+			})
 		}
 	}
 
@@ -147,12 +178,36 @@ func FetchGitHubReleases(repoURL string, ignoreCert bool) ([]string, error) {
 	return downloadLinks, nil
 }
 
-// DownloadFile downloads a single file from the specified URL to the current directory.
-// The file is named based on the last part of the URL path.
-// If showProgress is true, it displays a progress bar during download.
-// The ignoreCert parameter can be used to skip TLS certificate validation.
+// DownloadFile downloads a single file from the specified URL to the output directory.
+// It is a convenience wrapper around DownloadLink for a link without metadata.
 // Returns an error if download fails, file already exists, or file is too large.
 func DownloadFile(url string, ignoreCert bool, showProgress bool) error {
+	return DownloadLink(common.Link{URL: url}, ignoreCert, showProgress)
+}
+
+// DownloadLink downloads a single link to the output directory, at the path LocalPath
+// gives it, creating any parent directories. The file is written through a .part file,
+// so that an interrupted download resumes where it stopped when run again.
+// If showProgress is true, it displays a progress bar during download, sized from the
+// link's listed size when the server does not send a Content-Length.
+// The download waits for robots.Wait, so URLs robots.txt disallows are refused and
+// requests to the same host are spaced out.
+// The ignoreCert parameter can be used to skip TLS certificate validation.
+// Returns an error if download fails, file already exists, or file is too large.
+func DownloadLink(link common.Link, ignoreCert bool, showProgress bool) error {
+	return downloadLink(link, ignoreCert, showProgress, claimPath)
+}
+
+// Helper function to download a link as DownloadLink does, reserving the path it is
+// saved at with claim
+func downloadLink(link common.Link, ignoreCert bool, showProgress bool, claim func(localPath, rawURL string) string) error {
+	url := link.URL
+
+	// Honour robots.txt and the per-host request spacing
+	if err := robots.Wait(url, ignoreCert); err != nil {
+		return err
+	}
+
 	// Create a context with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
 	defer cancel()
@@ -167,22 +222,22 @@ func DownloadFile(url string, ignoreCert bool, showProgress bool) error {
 		}
 	}
 
-	// Create a request with context
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return fmt.Errorf("error creating request: %w", err)
+	// Ask for the rest of an interrupted download of the link, if there is one
+	resume := findPartResume(link)
+	resp, err := requestLink(ctx, client, url, resume)
+	if err == nil && resume != nil && resume.rejectedBy(resp) {
+		// The part file cannot be continued: start over with the whole file
+		resp.Body.Close()
+		resume.discard()
+		resume = nil
+		resp, err = requestLink(ctx, client, url, nil)
 	}
-
-	// Add a user-agent to be polite
-	req.Header.Set("User-Agent", common.UserAgent)
-
-	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("error downloading %s: %w", url, err)
+		return err
 	}
 	defer func() {
 		if closeErr := resp.Body.Close(); closeErr != nil {
-			fmt.Printf("Error closing response body: %v\n", closeErr)
+			fmt.Fprintf(os.Stderr, "Error closing response body: %v\n", closeErr)
 		}
 	}()
 
@@ -196,52 +251,75 @@ func DownloadFile(url string, ignoreCert bool, showProgress bool) error {
 		return fmt.Errorf("file too large (%.2f GB). Use a dedicated download tool instead", float64(resp.ContentLength)/(1024*1024*1024))
 	}
 
-	filename := filepath.Base(url)
+	var filename string
+	if resume != nil && resume.resumedBy(resp) {
+		// The rest of the file goes where its first part is
+		filename = resume.filename
+	} else {
+		if resume != nil {
+			// The server ignored the range or the file changed
+			resume.discard()
+			resume = nil
+		}
+
+		// Name the file from the response, now that any redirects have been followed
+		filename, err = LocalPath(link, resp)
+		if err != nil {
+			return err
+		}
+	}
+	filename = claim(filename, url)
 
 	// Check if file already exists
 	if !allowOverwriteFiles {
 		if _, err := os.Stat(filename); err == nil {
-			return fmt.Errorf("file %s already exists, skipping download (use -overwrite to override)", filename)
+			return fmt.Errorf("%w: %s, skipping download (use -overwrite to override)", ErrFileExists, filename)
 		}
 	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+		return fmt.Errorf("error creating directory for %s: %w", filename, err)
+	}
 
-	// Create the file
-	file, err := os.Create(filename)
+	return saveResponse(link, resp, filename, resume, showProgress)
+}
+
+// Helper function to send the GET request of a download, asking for the rest of the
+// part file if resume is not nil
+func requestLink(ctx context.Context, client *http.Client, url string, resume *partResume) (*http.Response, error) {
+	// Create a request with context
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return fmt.Errorf("error creating file %s: %w", filename, err)
+		return nil, fmt.Errorf("error creating request: %w", err)
 	}
-	defer func() {
-		if closeErr := file.Close(); closeErr != nil {
-			fmt.Printf("Error closing file: %v\n", closeErr)
-		}
-	}()
 
-	if showProgress {
-		bar := progressbar.DefaultBytes(
-			resp.ContentLength,
-			"downloading "+filename,
-		)
-		_, err = io.Copy(io.MultiWriter(file, bar), resp.Body)
-	} else {
-		_, err = io.Copy(file, resp.Body)
+	// Add a user-agent to be polite
+	req.Header.Set("User-Agent", common.UserAgent)
+	if resume != nil {
+		resume.setHeaders(req)
 	}
 
+	resp, err := client.Do(req)
 	if err != nil {
-		// On error, clean up the partial file
-		os.Remove(filename)
-		return fmt.Errorf("error writing to file: %w", err)
+		return nil, fmt.Errorf("error downloading %s: %w", url, err)
 	}
-
-	return nil
+	return resp, nil
 }
 
 // DownloadFiles downloads multiple files sequentially from the provided URLs.
+// It is a convenience wrapper around DownloadLinks for links without metadata.
+func DownloadFiles(urls []string, ignoreCert bool, showProgress bool) error {
+	return DownloadLinks(common.LinksFromURLs(urls), ignoreCert, showProgress)
+}
+
+// DownloadLinks downloads multiple links sequentially, each like DownloadLink.
+// Links that would be saved at the same path, going by their URLs, are each saved
+// under a name with a short hash of their URL added, whatever their order.
 // If showProgress is true, it displays a progress bar for each download.
 // The ignoreCert parameter can be used to skip TLS certificate validation.
-// The function continues to the next URL if a download fails and returns an error
+// The function continues to the next link if a download fails and returns an error
 // at the end if any downloads failed.
-func DownloadFiles(urls []string, ignoreCert bool, showProgress bool) error {
-	if len(urls) == 0 {
+func DownloadLinks(links []common.Link, ignoreCert bool, showProgress bool) error {
+	if len(links) == 0 {
 		return fmt.Errorf("no URLs to download")
 	}
 
@@ -251,48 +329,58 @@ func DownloadFiles(urls []string, ignoreCert bool, showProgress bool) error {
 
 	var failedCount int
 
-	for i, url := range urls {
+	// Names several of the links would share go to none of them
+	shared := sharedPaths(links)
+	claim := func(localPath, rawURL string) string {
+		return claimPath(unsharedPath(shared, localPath, rawURL), rawURL)
+	}
+
+	for i, link := range links {
 		// Check for context cancellation between downloads
 		select {
 		case <-ctx.Done():
-			return fmt.Errorf("download operation timed out after %d/%d files", i, len(urls))
+			return fmt.Errorf("download operation timed out after %d/%d files", i, len(links))
 		default:
 			// Continue with download
 		}
 
-		fmt.Printf("[%d/%d] Downloading: %s\n", i+1, len(urls), url)
-		err := DownloadFile(url, ignoreCert, showProgress)
+		fmt.Fprintf(output, "[%d/%d] Downloading: %s\n", i+1, len(links), link.URL)
+		err := downloadLink(link, ignoreCert, showProgress, claim)
 		if err != nil {
-			fmt.Printf("Error downloading %s: %v\n", url, err)
+			fmt.Fprintf(output, "Error downloading %s: %v\n", link.URL, err)
 			failedCount++
 			// Continue with next URL rather than stopping
 		} else if showProgress {
 			// Add a newline after progress bar completes
-			fmt.Println()
-		}
-
-		// Add a small delay between downloads to be kind to servers
-		if i < len(urls)-1 {
-			time.Sleep(500 * time.Millisecond)
+			fmt.Fprintln(output)
 		}
 	}
 
-	fmt.Printf("Download complete: %d/%d files\n", len(urls)-failedCount, len(urls))
+	fmt.Fprintf(output, "Download complete: %d/%d files\n", len(links)-failedCount, len(links))
 
 	if failedCount > 0 {
-		return fmt.Errorf("%d/%d downloads failed", failedCount, len(urls))
+		return fmt.Errorf("%d/%d downloads failed", failedCount, len(links))
 	}
 
 	return nil
 }
 
 // DownloadFilesSimultaneously downloads multiple files concurrently from the provided URLs.
-// It uses a semaphore to limit the number of concurrent downloads to maxConcurrentDownloads.
+// It is a convenience wrapper around DownloadLinksSimultaneously for links without metadata.
+func DownloadFilesSimultaneously(urls []string, ignoreCert bool, showProgress bool) error {
+	return DownloadLinksSimultaneously(common.LinksFromURLs(urls), ignoreCert, showProgress)
+}
+
+// DownloadLinksSimultaneously downloads multiple links concurrently, each like DownloadLink
+// and named as DownloadLinks names them, except that a file already at a link's path is
+// kept and the link saved under the first free numbered name, such as app.zip.1.
+// It uses a semaphore to limit the number of concurrent downloads to maxConcurrentDownloads,
+// and robots.Wait to honour robots.txt and space out requests to the same host.
 // The ignoreCert parameter can be used to skip TLS certificate validation.
 // The showProgress parameter determines whether to display progress bars (defaults to true).
 // Returns an error if any download fails, including the count of failed downloads.
-func DownloadFilesSimultaneously(urls []string, ignoreCert bool, showProgress bool) error {
-	if len(urls) == 0 {
+func DownloadLinksSimultaneously(links []common.Link, ignoreCert bool, showProgress bool) error {
+	if len(links) == 0 {
 		return fmt.Errorf("no URLs to download")
 	}
 
@@ -300,108 +388,35 @@ func DownloadFilesSimultaneously(urls []string, ignoreCert bool, showProgress bo
 	maxConcurrent := maxConcurrentDownloads
 	sem := make(chan struct{}, maxConcurrent)
 
-	// Use a mutex to protect file creation
-	var mu sync.Mutex
-
 	// Track errors
-	errorChan := make(chan error, len(urls))
+	errorChan := make(chan error, len(links))
+
+	// Names several of the links would share go to none of them, and other paths are
+	// claimed in list order, so that names do not depend on which response arrives first
+	shared := sharedPaths(links)
+	order := newClaimOrder(len(links))
 
 	var wg sync.WaitGroup
-	for _, url := range urls {
+	for i, link := range links {
+		// Acquire semaphore in list order, so that every earlier link holds a slot
+		// or is done while this one waits for them to claim their paths
+		sem <- struct{}{}
 		wg.Add(1)
-		go func(url string) {
-			// Acquire semaphore
-			sem <- struct{}{}
+		go func(i int, link common.Link) {
 			defer func() {
 				// Release semaphore when done
+				order.done(i)
 				<-sem
 				wg.Done()
 			}()
 
-			// Use a more atomic file creation approach
-			mu.Lock()
-			filename := filepath.Base(url)
-
-			// Check if file already exists
-			if !allowOverwriteFiles {
-				if _, err := os.Stat(filename); err == nil {
-					// File exists, create a unique name
-					for i := 1; ; i++ {
-						newName := fmt.Sprintf("%s.%d", filename, i)
-						if _, err := os.Stat(newName); os.IsNotExist(err) {
-							filename = newName
-							break
-						}
-					}
-				}
-			}
-			mu.Unlock()
-
-			// Custom download to use our unique filename
-			client := &http.Client{
-				Timeout: defaultTimeout,
-			}
-			if ignoreCert {
-				client.Transport = &http.Transport{
-					TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-				}
-			}
-
-			// Create a request with context
-			ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-			defer cancel()
-			req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-			if err != nil {
-				errorChan <- fmt.Errorf("error creating request for %s: %w", url, err)
-				return
-			}
-
-			// Add a user-agent to be polite
-			req.Header.Set("User-Agent", common.UserAgent)
-
-			resp, err := client.Do(req)
-			if err != nil {
-				errorChan <- fmt.Errorf("error downloading %s: %w", url, err)
-				return
-			}
-			defer func() {
-				if closeErr := resp.Body.Close(); closeErr != nil {
-					fmt.Printf("Error closing response body: %v\n", closeErr)
-				}
-			}()
-
-			// Check for successful status code
-			if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-				errorChan <- fmt.Errorf("server returned non-success status for %s: %d %s", url, resp.StatusCode, resp.Status)
-				return
-			}
-
-			file, err := os.Create(filename)
-			if err != nil {
-				errorChan <- fmt.Errorf("error creating file %s: %w", filename, err)
-				return
-			}
-			defer func() {
-				if closeErr := file.Close(); closeErr != nil {
-					fmt.Printf("Error closing file: %v\n", closeErr)
-				}
-			}()
-
-			if showProgress {
-				bar := progressbar.DefaultBytes(
-					resp.ContentLength,
-					"downloading "+filename,
-				)
-				_, err = io.Copy(io.MultiWriter(file, bar), resp.Body)
-			} else {
-				_, err = io.Copy(file, resp.Body)
+			claim := func(localPath, rawURL string) string {
+				return order.claim(i, unsharedPath(shared, localPath, rawURL), rawURL)
 			}
-			if err != nil {
-				// Clean up partial file
-				os.Remove(filename)
-				errorChan <- fmt.Errorf("error writing to file %s: %w", filename, err)
+			if err := downloadLink(link, ignoreCert, showProgress, claim); err != nil {
+				errorChan <- fmt.Errorf("%s: %w", link.URL, err)
 			}
-		}(url)
+		}(i, link)
 	}
 
 	// Wait for all downloads to complete
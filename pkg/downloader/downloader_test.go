@@ -5,13 +5,19 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
-	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/hemzaz/lsweb/pkg/common"
+	"github.com/hemzaz/lsweb/pkg/robots"
 )
 
+func TestMain(m *testing.M) {
+	// The test servers are local, so there is no need to space out requests
+	robots.SetHostDelay(0)
+	os.Exit(m.Run())
+}
+
 func TestSetTimeout(t *testing.T) {
 	// Save original value to restore after test
 	originalTimeout := defaultTimeout
@@ -163,8 +169,8 @@ func TestDownloadFile(t *testing.T) {
 		t.Errorf("DownloadFile failed: %v", err)
 	}
 
-	// Check that the file was downloaded
-	filename := filepath.Base(server.URL)
+	// Check that the file was downloaded; a URL without a path is saved as index.html
+	filename := "index.html"
 	content, err := os.ReadFile(filename)
 	if err != nil {
 		t.Errorf("Failed to read downloaded file: %v", err)
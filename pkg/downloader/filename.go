@@ -0,0 +1,269 @@
+package downloader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	// Internal dependencies
+	"github.com/hemzaz/lsweb/pkg/common"
+)
+
+// defaultFileName names files whose URL path ends in a directory, as wget does
+const defaultFileName = "index.html"
+
+// Content-Disposition filename parameters, matched leniently for the RFC 5987
+// charsets and malformed headers mime.ParseMediaType does not handle
+var (
+	extFilenamePattern = regexp.MustCompile(`(?i)\bfilename\*\s*=\s*"?([\w!#$%&+^` + "`" + `{}~.-]*)'[^']*'([^";\s]+)`)
+	filenamePattern    = regexp.MustCompile(`(?i)\bfilename\s*=\s*(?:"([^"]*)"|([^;]+))`)
+)
+
+// Paths claimed by downloads during this run, and the URLs that claimed them
+var (
+	claimMu      sync.Mutex
+	claimedPaths = map[string]string{}
+)
+
+// FileName returns the name a download is saved under: the filename given by the
+// response's Content-Disposition header, preferring an RFC 5987 filename*, else the
+// last segment of the final URL after redirects, else that of rawURL, else
+// index.html. The name is sanitized for the filesystem. resp may be nil.
+func FileName(resp *http.Response, rawURL string) string {
+	var name string
+	if resp != nil {
+		name = contentDispositionName(resp.Header.Get("Content-Disposition"))
+		if name == "" && resp.Request != nil && resp.Request.URL != nil {
+			name = urlFileName(resp.Request.URL)
+		}
+	}
+	if name == "" {
+		if u, err := url.Parse(rawURL); err == nil {
+			name = urlFileName(u)
+		}
+	}
+	if name == "" {
+		name = defaultFileName
+	}
+	return common.SanitizeFileName(name)
+}
+
+// Helper function to extract the file name from a Content-Disposition header value,
+// dropping any directories the server included in it
+func contentDispositionName(header string) string {
+	if header == "" {
+		return ""
+	}
+
+	var name string
+	if m := extFilenamePattern.FindStringSubmatch(header); m != nil {
+		name = decodeExtValue(m[1], m[2])
+	}
+	if name == "" {
+		if _, params, err := mime.ParseMediaType(header); err == nil {
+			name = params["filename"]
+		}
+	}
+	if name == "" {
+		if m := filenamePattern.FindStringSubmatch(header); m != nil {
+			name = strings.TrimSpace(m[1] + m[2])
+		}
+	}
+
+	segments := strings.FieldsFunc(name, isPathSeparator)
+	if len(segments) == 0 {
+		return ""
+	}
+	return segments[len(segments)-1]
+}
+
+// Helper function to decode an RFC 5987 ext-value in UTF-8 or ISO-8859-1
+func decodeExtValue(charset, value string) string {
+	decoded, err := url.PathUnescape(value)
+	if err != nil {
+		return ""
+	}
+
+	switch strings.ToLower(charset) {
+	case "utf-8", "us-ascii":
+		if utf8.ValidString(decoded) {
+			return decoded
+		}
+	case "iso-8859-1":
+		runes := make([]rune, 0, len(decoded))
+		for i := 0; i < len(decoded); i++ {
+			runes = append(runes, rune(decoded[i]))
+		}
+		return string(runes)
+	}
+	return ""
+}
+
+// Helper function to return the last segment of a URL path, or "" if the path ends
+// in a directory
+func urlFileName(u *url.URL) string {
+	if u.Path == "" || strings.HasSuffix(u.Path, "/") {
+		return ""
+	}
+	return path.Base(u.Path)
+}
+
+// Helper function to reserve a local path for a URL for the rest of the run. If a
+// different URL already holds the path, the URL gets the path with a short hash of
+// the URL added before the extension instead.
+func claimPath(localPath, rawURL string) string {
+	claimMu.Lock()
+	defer claimMu.Unlock()
+
+	if owner, ok := claimedPaths[pathKey(localPath)]; ok && owner != rawURL {
+		localPath = hashedPath(localPath, rawURL)
+	}
+	claimedPaths[pathKey(localPath)] = rawURL
+	return localPath
+}
+
+// Helper function to claim a path as claimPath does, except that if a file is already
+// there and overwriting is off, the first free name with a numbered suffix, such as
+// app.zip.1, is claimed instead, so that simultaneous downloads keep existing files
+func claimFreePath(localPath, rawURL string) string {
+	localPath = claimPath(localPath, rawURL)
+	if allowOverwriteFiles {
+		return localPath
+	}
+	if _, err := os.Stat(localPath); err != nil {
+		return localPath
+	}
+
+	claimMu.Lock()
+	defer claimMu.Unlock()
+
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s.%d", localPath, i)
+		if owner, ok := claimedPaths[pathKey(candidate)]; ok && owner != rawURL {
+			continue
+		}
+		if _, err := os.Stat(candidate); err == nil {
+			continue
+		}
+		claimedPaths[pathKey(candidate)] = rawURL
+		return candidate
+	}
+}
+
+// Helper function to return the paths that more than one of links would be saved at
+// when named from their URLs, keyed as claimedPaths is
+func sharedPaths(links []common.Link) map[string]bool {
+	owners := make(map[string]string)
+	shared := make(map[string]bool)
+	for _, link := range links {
+		localPath, err := LocalPath(link, nil)
+		if err != nil {
+			continue
+		}
+		key := pathKey(localPath)
+		if owner, ok := owners[key]; !ok {
+			owners[key] = link.URL
+		} else if owner != link.URL {
+			shared[key] = true
+		}
+	}
+	return shared
+}
+
+// Helper function to give a URL its hashed path if its path is one of shared, so that
+// no URL of a list keeps a name that several of them would take, and which of them
+// gets which name does not depend on the order of the list
+func unsharedPath(shared map[string]bool, localPath, rawURL string) string {
+	if shared[pathKey(localPath)] {
+		return hashedPath(localPath, rawURL)
+	}
+	return localPath
+}
+
+// Helper function to return the key of a path in claimedPaths: the absolute path
+func pathKey(localPath string) string {
+	if abs, err := filepath.Abs(localPath); err == nil {
+		return abs
+	}
+	return localPath
+}
+
+// Helper function to return the path a URL is saved at when another URL already holds
+// localPath: localPath with a short hash of the URL added before the extension
+func hashedPath(localPath, rawURL string) string {
+	return insertBeforeExt(localPath, "-"+urlHash(rawURL))
+}
+
+// Helper function to return a short hash of a URL, used to tell apart the files of
+// URLs that would share a name
+func urlHash(rawURL string) string {
+	sum := sha256.Sum256([]byte(rawURL))
+	return hex.EncodeToString(sum[:4])
+}
+
+// claimOrder makes the links of a batch downloaded concurrently claim their paths in
+// list order with claimFreePath, so that of two links whose responses name the same
+// path the first listed keeps it, as it does when they are downloaded one by one
+type claimOrder struct {
+	claimed []chan struct{}
+	once    []sync.Once
+}
+
+// Helper function to create a claimOrder for a batch of n links
+func newClaimOrder(n int) *claimOrder {
+	order := &claimOrder{
+		claimed: make([]chan struct{}, n),
+		once:    make([]sync.Once, n),
+	}
+	for i := range order.claimed {
+		order.claimed[i] = make(chan struct{})
+	}
+	return order
+}
+
+// Helper function to claim a path for link i once the links before it have claimed
+// theirs or failed
+func (o *claimOrder) claim(i int, localPath, rawURL string) string {
+	if i > 0 {
+		<-o.claimed[i-1]
+	}
+	localPath = claimFreePath(localPath, rawURL)
+	o.done(i)
+	return localPath
+}
+
+// Helper function to mark link i as having claimed its path, or as not going to, once
+// the links before it are done too
+func (o *claimOrder) done(i int) {
+	o.once[i].Do(func() {
+		if i > 0 {
+			<-o.claimed[i-1]
+		}
+		close(o.claimed[i])
+	})
+}
+
+// Helper function to insert a suffix into the file name of a path before its
+// extension, treating .tar.gz and the like as one extension
+func insertBeforeExt(localPath, suffix string) string {
+	dir, name := filepath.Split(localPath)
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	if inner := filepath.Ext(stem); strings.EqualFold(inner, ".tar") {
+		stem, ext = strings.TrimSuffix(stem, inner), inner+ext
+	}
+	if stem == "" {
+		stem, ext = name, ""
+	}
+	return dir + stem + suffix + ext
+}
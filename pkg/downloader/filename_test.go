@@ -0,0 +1,265 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hemzaz/lsweb/pkg/common"
+)
+
+func TestFileName(t *testing.T) {
+	tests := []struct {
+		name        string
+		disposition string
+		finalURL    string
+		url         string
+		expected    string
+	}{
+		{"URL path", "", "", "https://example.com/files/app.tar.gz", "app.tar.gz"},
+		{"Query dropped", "", "", "https://example.com/download?id=42", "download"},
+		{"Escaped path", "", "", "https://example.com/my%20file.zip", "my file.zip"},
+		{"Directory", "", "", "https://example.com/files/", "index.html"},
+		{"No path", "", "", "https://example.com", "index.html"},
+		{"Final URL", "", "https://cdn.example.com/v2/app-2.0.zip?token=x", "https://example.com/latest", "app-2.0.zip"},
+		{"Final URL directory", "", "https://example.com/", "https://example.com/latest", "latest"},
+		{"Quoted filename", `attachment; filename="report 2026.pdf"`, "", "https://example.com/download?id=42", "report 2026.pdf"},
+		{"Token filename", `attachment; filename=report.pdf`, "", "https://example.com/download", "report.pdf"},
+		{"UTF-8 filename*", `attachment; filename="naive.txt"; filename*=UTF-8''na%C3%AFve.txt`, "", "https://example.com/d", "naïve.txt"},
+		{"ISO-8859-1 filename*", `attachment; filename*=iso-8859-1'en'%A3%20rates.txt`, "", "https://example.com/d", "£ rates.txt"},
+		{"Malformed header", `attachment; filename=my report.pdf; size=10`, "", "https://example.com/d", "my report.pdf"},
+		{"Directories dropped", `attachment; filename="../../etc/passwd"`, "", "https://example.com/d", "passwd"},
+		{"Windows path", `attachment; filename="C:\\temp\\setup.exe"`, "", "https://example.com/d", "setup.exe"},
+		{"Sanitized", `attachment; filename="what?.txt"`, "", "https://example.com/d", "what_.txt"},
+		{"Inline without filename", `inline`, "https://example.com/docs/guide.pdf", "https://example.com/d", "guide.pdf"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			finalURL := tt.finalURL
+			if finalURL == "" {
+				finalURL = tt.url
+			}
+			u, _ := url.Parse(finalURL)
+			resp := &http.Response{Header: http.Header{}, Request: &http.Request{URL: u}}
+			if tt.disposition != "" {
+				resp.Header.Set("Content-Disposition", tt.disposition)
+			}
+
+			if got := FileName(resp, tt.url); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+
+	if got := FileName(nil, "https://example.com/a/b.zip"); got != "b.zip" {
+		t.Errorf("Expected b.zip without a response, got %q", got)
+	}
+}
+
+func TestClaimPath(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "app.tar.gz")
+
+	first := claimPath(target, "https://example.com/a/app.tar.gz")
+	again := claimPath(target, "https://example.com/a/app.tar.gz")
+	second := claimPath(target, "https://example.com/b/app.tar.gz")
+	secondAgain := claimPath(target, "https://example.com/b/app.tar.gz")
+
+	if first != target || again != target {
+		t.Errorf("Expected the first URL to keep %s, got %s and %s", target, first, again)
+	}
+	if second == target || second != secondAgain || !strings.HasSuffix(second, ".tar.gz") ||
+		!strings.HasPrefix(filepath.Base(second), "app-") {
+		t.Errorf("Expected a stable name like app-<hash>.tar.gz for the second URL, got %s and %s", second, secondAgain)
+	}
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"app.zip", "app-x.zip"},
+		{"app.TAR.GZ", "app-x.TAR.GZ"},
+		{"app", "app-x"},
+		{".bashrc", ".bashrc-x"},
+		{"dir/app.zip", "dir/app-x.zip"},
+	}
+	for _, tt := range tests {
+		if got := insertBeforeExt(filepath.FromSlash(tt.path), "-x"); got != filepath.FromSlash(tt.expected) {
+			t.Errorf("insertBeforeExt(%s) = %s, expected %s", tt.path, got, tt.expected)
+		}
+	}
+}
+
+func TestDownloadNames(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/download":
+			if r.URL.Query().Get("id") == "42" {
+				w.Header().Set("Content-Disposition", `attachment; filename*=UTF-8''r%C3%A9sum%C3%A9.pdf`)
+			}
+			fmt.Fprint(w, r.URL.RawQuery)
+		case "/latest":
+			http.Redirect(w, r, "/files/app-2.0.zip", http.StatusFound)
+		default:
+			fmt.Fprint(w, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	SetOutputDir(dir)
+	defer SetOutputDir("")
+
+	links := common.LinksFromURLs([]string{
+		server.URL + "/download?id=42",
+		server.URL + "/download?id=7",
+		server.URL + "/download?id=8",
+		server.URL + "/latest",
+	})
+	if err := DownloadLinks(links[:2], false, false); err != nil {
+		t.Fatalf("DownloadLinks failed: %v", err)
+	}
+	if err := DownloadLinksSimultaneously(links[2:], false, false); err != nil {
+		t.Fatalf("DownloadLinksSimultaneously failed: %v", err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	if len(names) != 4 || names[0] != "app-2.0.zip" || names[1] != "download" ||
+		!strings.HasPrefix(names[2], "download-") || names[3] != "résumé.pdf" {
+		t.Fatalf("Unexpected file names %v", names)
+	}
+
+	// id=7 shares its name with id=42 in its list, so it gets a hashed name, and
+	// id=8 then keeps its own file under the plain name
+	if data, _ := os.ReadFile(filepath.Join(dir, "download")); string(data) != "id=8" {
+		t.Errorf("Expected download to hold id=8, got %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, names[2])); string(data) != "id=7" {
+		t.Errorf("Expected %s to hold id=7, got %q", names[2], data)
+	}
+}
+
+func TestDownloadNamesStable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a/data.bin", "/a/get":
+			// The first link answers last
+			time.Sleep(200 * time.Millisecond)
+			if r.URL.Path == "/a/get" {
+				w.Header().Set("Content-Disposition", `attachment; filename="data.bin"`)
+			}
+			fmt.Fprint(w, "a")
+		case "/b/data.bin", "/b/get":
+			if r.URL.Path == "/b/get" {
+				w.Header().Set("Content-Disposition", `attachment; filename="data.bin"`)
+			}
+			fmt.Fprint(w, "b")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	download := map[string]func([]common.Link, bool, bool) error{
+		"sequential":   DownloadLinks,
+		"simultaneous": DownloadLinksSimultaneously,
+	}
+	for name, downloadLinks := range download {
+		t.Run(name, func(t *testing.T) {
+			// URLs that would share a name all get hashed names, in any order
+			urls := []string{server.URL + "/a/data.bin", server.URL + "/missing/data.bin", server.URL + "/b/data.bin"}
+			for _, reversed := range []bool{false, true} {
+				dir := t.TempDir()
+				SetOutputDir(dir)
+
+				links := common.LinksFromURLs(urls)
+				if reversed {
+					links[0], links[2] = links[2], links[0]
+				}
+				if err := downloadLinks(links, false, false); err == nil {
+					t.Fatal("Expected the missing link to fail")
+				}
+
+				plain := filepath.Join(dir, "data.bin")
+				if _, err := os.Stat(plain); !os.IsNotExist(err) {
+					t.Errorf("Expected no link to keep data.bin (reversed: %v)", reversed)
+				}
+				for i, content := range map[int]string{0: "a", 2: "b"} {
+					hashed := hashedPath(plain, urls[i])
+					if data, _ := os.ReadFile(hashed); string(data) != content {
+						t.Errorf("Expected %s to hold %s (reversed: %v), got %q", filepath.Base(hashed), content, reversed, data)
+					}
+				}
+			}
+
+			// Names that only the responses share go to the first link listed,
+			// whichever response arrives first
+			dir := t.TempDir()
+			SetOutputDir(dir)
+			defer SetOutputDir("")
+
+			links := common.LinksFromURLs([]string{server.URL + "/a/get", server.URL + "/b/get"})
+			if err := downloadLinks(links, false, false); err != nil {
+				t.Fatalf("Download failed: %v", err)
+			}
+			if data, _ := os.ReadFile(filepath.Join(dir, "data.bin")); string(data) != "a" {
+				t.Errorf("Expected data.bin to hold a, got %q", data)
+			}
+			hashed := hashedPath(filepath.Join(dir, "data.bin"), links[1].URL)
+			if data, _ := os.ReadFile(hashed); string(data) != "b" {
+				t.Errorf("Expected %s to hold b, got %q", filepath.Base(hashed), data)
+			}
+		})
+	}
+}
+
+func TestDownloadExistingFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "new")
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	SetOutputDir(dir)
+	defer SetOutputDir("")
+	existing := filepath.Join(dir, "app.zip")
+	if err := os.WriteFile(existing, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	links := common.LinksFromURLs([]string{server.URL + "/app.zip"})
+
+	// Sequential downloads skip the file
+	if err := DownloadLinks(links, false, false); err == nil {
+		t.Error("Expected the download to be skipped")
+	}
+	if err := DownloadLink(links[0], false, false); !errors.Is(err, ErrFileExists) {
+		t.Errorf("Expected ErrFileExists, got %v", err)
+	}
+
+	// Simultaneous downloads keep it and save the link under a numbered name
+	if err := DownloadLinksSimultaneously(links, false, false); err != nil {
+		t.Fatalf("DownloadLinksSimultaneously failed: %v", err)
+	}
+	if err := DownloadLinksSimultaneously(links, false, false); err != nil {
+		t.Fatalf("DownloadLinksSimultaneously failed: %v", err)
+	}
+	for name, content := range map[string]string{"app.zip": "old", "app.zip.1": "new", "app.zip.2": "new"} {
+		if data, _ := os.ReadFile(filepath.Join(dir, name)); string(data) != content {
+			t.Errorf("Expected %s to hold %s, got %q", name, content, data)
+		}
+	}
+}
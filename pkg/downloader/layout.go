@@ -0,0 +1,153 @@
+package downloader
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	// Internal dependencies
+	"github.com/hemzaz/lsweb/pkg/common"
+)
+
+// DefaultLayout saves each file under its name, directly in the output directory
+const DefaultLayout = "{name}"
+
+// untaggedDir stands in for {tag} for links that do not belong to a release
+const untaggedDir = "untagged"
+
+// Where downloaded files are saved
+var (
+	outputDir = ""
+	layout    = DefaultLayout
+)
+
+// placeholderPattern matches the placeholders of a layout template
+var placeholderPattern = regexp.MustCompile(`\{[^{}]*\}`)
+
+// layoutPlaceholders are the placeholders a layout template may use
+var layoutPlaceholders = map[string]bool{
+	"{host}": true,
+	"{dir}":  true,
+	"{path}": true,
+	"{name}": true,
+	"{tag}":  true,
+	"{date}": true,
+}
+
+// SetOutputDir sets the directory downloaded files are saved under; empty means the
+// current directory. It is created when the first file is saved.
+func SetOutputDir(dir string) {
+	outputDir = dir
+}
+
+// SetLayout sets the template for the path of each downloaded file under the output
+// directory, such as "{host}/{path}", "{tag}/{name}" or "{date}/{name}". The
+// placeholders are:
+//
+//	{host}  the host of the URL, with any port appended after an underscore
+//	{dir}   the directory part of the URL path
+//	{path}  the URL path, i.e. {dir}/{name}
+//	{name}  the file name
+//	{tag}   the release the link belongs to (with -gh), or "untagged"
+//	{date}  the date the link was last modified, or else today, as YYYY-MM-DD
+//
+// An empty template restores DefaultLayout. Returns an error for unknown
+// placeholders and for templates that are absolute, climb out of the output
+// directory with "..", or do not include {name} or {path}.
+func SetLayout(template string) error {
+	if template == "" {
+		layout = DefaultLayout
+		return nil
+	}
+
+	for _, placeholder := range placeholderPattern.FindAllString(template, -1) {
+		if !layoutPlaceholders[placeholder] {
+			return fmt.Errorf("unknown placeholder %s in layout %q (valid placeholders: {host}, {dir}, {path}, {name}, {tag}, {date})", placeholder, template)
+		}
+	}
+	if !strings.Contains(template, "{name}") && !strings.Contains(template, "{path}") {
+		return fmt.Errorf("layout %q must include {name} or {path}", template)
+	}
+	if strings.HasPrefix(template, "/") || filepath.IsAbs(template) {
+		return fmt.Errorf("layout %q must be relative to the output directory", template)
+	}
+	for _, segment := range strings.FieldsFunc(template, isPathSeparator) {
+		if segment == ".." {
+			return fmt.Errorf("layout %q must not leave the output directory", template)
+		}
+	}
+
+	layout = template
+	return nil
+}
+
+// LocalPath returns the path a link is saved at: the layout expanded for the link,
+// under the output directory, with the file named by FileName from the response the
+// link was fetched with, which may be nil. Returns an error if the URL cannot be
+// parsed or the path would fall outside the output directory.
+func LocalPath(link common.Link, resp *http.Response) (string, error) {
+	u, err := url.Parse(link.URL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL %s: %w", link.URL, err)
+	}
+	return layoutPath(u, FileName(resp, link.URL), link, time.Now())
+}
+
+// Helper function to expand the layout for a link saved under the given name,
+// keeping the result inside the output directory
+func layoutPath(u *url.URL, name string, link common.Link, now time.Time) (string, error) {
+	name = common.SanitizeFileName(name)
+
+	var dirs []string
+	for _, segment := range strings.Split(path.Dir(u.Path), "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			continue
+		}
+		dirs = append(dirs, common.SanitizeFileName(segment))
+	}
+	dir := strings.Join(dirs, "/")
+
+	tag := untaggedDir
+	if link.Release != "" {
+		tag = common.SanitizeFileName(link.Release)
+	}
+	date := now
+	if !link.Modified.IsZero() {
+		date = link.Modified
+	}
+
+	expanded := placeholderPattern.ReplaceAllStringFunc(layout, func(placeholder string) string {
+		switch placeholder {
+		case "{host}":
+			return common.SanitizeFileName(strings.ToLower(u.Host))
+		case "{dir}":
+			return dir
+		case "{path}":
+			return path.Join(dir, name)
+		case "{name}":
+			return name
+		case "{tag}":
+			return tag
+		case "{date}":
+			return date.Format("2006-01-02")
+		}
+		return placeholder
+	})
+
+	// The placeholders cannot introduce ".." segments, but check the result anyway
+	local := filepath.Clean(filepath.FromSlash(expanded))
+	if !filepath.IsLocal(local) {
+		return "", fmt.Errorf("path %s for %s is outside the output directory", expanded, link.URL)
+	}
+	return filepath.Join(outputDir, local), nil
+}
+
+// Helper function to report whether a rune separates path segments on any platform
+func isPathSeparator(r rune) bool {
+	return r == '/' || r == '\\'
+}
@@ -0,0 +1,131 @@
+package downloader
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hemzaz/lsweb/pkg/common"
+)
+
+func TestSetLayout(t *testing.T) {
+	defer SetLayout("")
+
+	tests := []struct {
+		template string
+		valid    bool
+	}{
+		{"{name}", true},
+		{"{host}/{path}", true},
+		{"{tag}/{name}", true},
+		{"releases/{date}/{name}", true},
+		{"{version}/{name}", false},
+		{"{host}", false},
+		{"/tmp/{name}", false},
+		{"../{name}", false},
+		{"a/..\\{name}", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			err := SetLayout(tt.template)
+			if (err == nil) != tt.valid {
+				t.Errorf("Expected valid %v, got error %v", tt.valid, err)
+			}
+		})
+	}
+}
+
+func TestLayoutPath(t *testing.T) {
+	defer SetLayout("")
+	defer SetOutputDir("")
+
+	now := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+	modified := time.Date(2025, 12, 31, 23, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		layout   string
+		dir      string
+		link     common.Link
+		expected string
+	}{
+		{"{name}", "", common.Link{URL: "https://example.com/a/b/file.zip"}, "file.zip"},
+		{"{name}", "out", common.Link{URL: "https://example.com/file.zip"}, "out/file.zip"},
+		{"{host}/{path}", "out", common.Link{URL: "https://Example.com:8443/a/b/file.zip"}, "out/example.com_8443/a/b/file.zip"},
+		{"{host}/{path}", "out", common.Link{URL: "https://example.com/a/../../b/./file.zip"}, "out/example.com/b/file.zip"},
+		{"{host}/{dir}/{name}", "", common.Link{URL: "https://example.com/file.zip"}, "example.com/file.zip"},
+		{"{tag}/{name}", "out", common.Link{URL: "https://example.com/file.zip", Release: "v1.2.0"}, "out/v1.2.0/file.zip"},
+		{"{tag}/{name}", "out", common.Link{URL: "https://example.com/file.zip"}, "out/untagged/file.zip"},
+		{"{tag}/{name}", "out", common.Link{URL: "https://example.com/file.zip", Release: "../../etc"}, "out/.._.._etc/file.zip"},
+		{"{tag}/{name}", "out", common.Link{URL: "https://example.com/file.zip", Release: ".."}, "out/_/file.zip"},
+		{"{date}/{name}", "", common.Link{URL: "https://example.com/file.zip", Modified: modified}, "2025-12-31/file.zip"},
+		{"{date}/{name}", "", common.Link{URL: "https://example.com/file.zip"}, "2026-03-04/file.zip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.layout+" "+tt.link.URL, func(t *testing.T) {
+			if err := SetLayout(tt.layout); err != nil {
+				t.Fatal(err)
+			}
+			SetOutputDir(tt.dir)
+
+			u, _ := url.Parse(tt.link.URL)
+			got, err := layoutPath(u, filepath.Base(tt.link.URL), tt.link, now)
+			if err != nil {
+				t.Fatalf("layoutPath failed: %v", err)
+			}
+			if got != filepath.FromSlash(tt.expected) {
+				t.Errorf("Expected %s, got %s", filepath.FromSlash(tt.expected), got)
+			}
+		})
+	}
+
+	SetLayout("{name}")
+	u, _ := url.Parse("https://example.com/")
+	if got, err := layoutPath(u, "..", common.Link{URL: u.String()}, now); err != nil || got != "_" {
+		t.Errorf("Expected a name of .. to be replaced, got %s (error: %v)", got, err)
+	}
+}
+
+func TestDownloadLayout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.URL.Path)
+	}))
+	defer server.Close()
+
+	dir := filepath.Join(t.TempDir(), "downloads")
+	SetOutputDir(dir)
+	defer SetOutputDir("")
+	if err := SetLayout("{tag}/{name}"); err != nil {
+		t.Fatal(err)
+	}
+	defer SetLayout("")
+
+	links := []common.Link{
+		{URL: server.URL + "/a/app.tar.gz", Release: "v1.0.0"},
+		{URL: server.URL + "/b/app.tar.gz", Release: "v2.0.0"},
+	}
+	if err := DownloadLinks(links[:1], false, false); err != nil {
+		t.Fatalf("DownloadLinks failed: %v", err)
+	}
+	if err := DownloadLinksSimultaneously(links[1:], false, false); err != nil {
+		t.Fatalf("DownloadLinksSimultaneously failed: %v", err)
+	}
+
+	for path, content := range map[string]string{
+		"v1.0.0/app.tar.gz": "/a/app.tar.gz",
+		"v2.0.0/app.tar.gz": "/b/app.tar.gz",
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(path)))
+		if err != nil {
+			t.Errorf("Expected %s to be downloaded: %v", path, err)
+		} else if string(data) != content {
+			t.Errorf("Expected %s to hold %q, got %q", path, content, data)
+		}
+	}
+}
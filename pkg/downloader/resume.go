@@ -0,0 +1,227 @@
+package downloader
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	// Third-party dependencies
+	"github.com/schollz/progressbar/v3"
+
+	// Internal dependencies
+	"github.com/hemzaz/lsweb/pkg/common"
+)
+
+// Suffix of the file a download is written to until it completes, and the prefix and
+// suffix of the sidecar file that records how to resume it
+const (
+	partSuffix  = ".part"
+	statePrefix = ".lsweb-"
+	stateSuffix = ".part.json"
+)
+
+// partState is the sidecar of a .part file: what was being downloaded and where to,
+// the validators the server sent for it and how many bytes were written
+type partState struct {
+	URL          string `json:"url"`
+	Path         string `json:"path"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	Bytes        int64  `json:"bytes"`
+}
+
+// Helper function to return the path of the sidecar of a download of rawURL. It is
+// named from the URL rather than the file, whose name may come from the response, so
+// that it can be found before the URL is requested again.
+func statePath(rawURL string) string {
+	return filepath.Join(outputDir, statePrefix+urlHash(rawURL)+stateSuffix)
+}
+
+// partResume is the part file of an interrupted download, found before the link
+// is requested again so that the request can ask for the rest of it
+type partResume struct {
+	filename string
+	state    *partState
+	offset   int64
+}
+
+// Helper function to find the part file of an interrupted download of a link through
+// the sidecar of its URL, which records the file's path. Only a part file whose sidecar
+// records the link's URL and a validator for If-Range is taken. Returns nil if there
+// is none to resume.
+func findPartResume(link common.Link) *partResume {
+	state, err := loadPartState(statePath(link.URL))
+	if err != nil || state.URL != link.URL || state.Path == "" || ifRangeValidator(state) == "" {
+		return nil
+	}
+	info, err := os.Stat(state.Path + partSuffix)
+	if err != nil || info.Size() == 0 || info.Size() < state.Bytes {
+		return nil
+	}
+	return &partResume{filename: state.Path, state: state, offset: info.Size()}
+}
+
+// Helper function to ask for the rest of the part file with Range, guarded by If-Range
+// so that a server whose file changed sends all of it instead
+func (p *partResume) setHeaders(req *http.Request) {
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", p.offset))
+	req.Header.Set("If-Range", ifRangeValidator(p.state))
+}
+
+// Helper function to report whether a response continues the part file at its end
+func (p *partResume) resumedBy(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusPartialContent && contentRangeStart(resp.Header.Get("Content-Range")) == p.offset
+}
+
+// Helper function to report whether a response refuses the range asked for, or
+// answers it from another offset, so that the whole file has to be requested again
+func (p *partResume) rejectedBy(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusRequestedRangeNotSatisfiable ||
+		(resp.StatusCode == http.StatusPartialContent && !p.resumedBy(resp))
+}
+
+// Helper function to delete the part file and its sidecar
+func (p *partResume) discard() {
+	os.Remove(p.filename + partSuffix)
+	os.Remove(statePath(p.state.URL))
+}
+
+// saveResponse writes the body of a successful GET response for a link to filename.
+// The body goes to filename.part, with a sidecar named from the link's URL recording
+// the URL, filename, ETag, Last-Modified and the bytes written, and the part file is
+// renamed to filename once complete. If resume is not nil, resp continues its part file, and the body is
+// appended to it. On failure the part file and sidecar are kept so that the next
+// attempt can resume.
+func saveResponse(link common.Link, resp *http.Response, filename string, resume *partResume, showProgress bool) error {
+	partPath := filename + partSuffix
+	stateFile := statePath(link.URL)
+
+	state := partState{
+		URL:          link.URL,
+		Path:         filename,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if resume != nil {
+		flags = os.O_WRONLY | os.O_APPEND
+		state.Bytes = resume.offset
+
+		// A 206 response need not repeat the validators
+		if state.ETag == "" && state.LastModified == "" {
+			state.ETag, state.LastModified = resume.state.ETag, resume.state.LastModified
+		}
+
+		// The part file moves with the name, if another URL took it in this run
+		if resume.filename != filename {
+			if err := os.Rename(resume.filename+partSuffix, partPath); err != nil {
+				return fmt.Errorf("error renaming %s to %s: %w", resume.filename+partSuffix, partPath, err)
+			}
+		}
+	}
+	file, err := os.OpenFile(partPath, flags, 0o644)
+	if err != nil {
+		return fmt.Errorf("error creating file %s: %w", partPath, err)
+	}
+
+	if err := savePartState(stateFile, state); err != nil {
+		file.Close()
+		return err
+	}
+
+	offset := state.Bytes
+	var written int64
+	if showProgress {
+		contentLength := resp.ContentLength
+		if contentLength < 0 && link.Size > 0 {
+			contentLength = link.Size - offset
+		}
+		if contentLength >= 0 {
+			contentLength += offset
+		}
+		bar := progressbar.DefaultBytes(
+			contentLength,
+			"downloading "+filepath.Base(filename),
+		)
+		bar.Set64(offset)
+		written, err = io.Copy(io.MultiWriter(file, bar), resp.Body)
+	} else {
+		written, err = io.Copy(file, resp.Body)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		// Keep the part file and record how much of it was written, to resume later
+		state.Bytes = offset + written
+		if stateErr := savePartState(stateFile, state); stateErr != nil {
+			fmt.Fprintf(os.Stderr, "Error saving download state: %v\n", stateErr)
+		}
+		return fmt.Errorf("error writing to file %s after %d bytes (run again to resume): %w", partPath, state.Bytes, err)
+	}
+
+	if err := os.Rename(partPath, filename); err != nil {
+		return fmt.Errorf("error renaming %s to %s: %w", partPath, filename, err)
+	}
+	os.Remove(stateFile)
+	return nil
+}
+
+// Helper function to choose the If-Range value for a partial download: its ETag if
+// strong, since weak ETags never match, else its Last-Modified date
+func ifRangeValidator(state *partState) string {
+	if state.ETag != "" && !strings.HasPrefix(state.ETag, "W/") {
+		return state.ETag
+	}
+	return state.LastModified
+}
+
+// Helper function to parse the first byte position of a Content-Range header value
+// such as "bytes 100-199/200", returning -1 if it cannot be parsed
+func contentRangeStart(contentRange string) int64 {
+	spec, ok := strings.CutPrefix(contentRange, "bytes ")
+	if !ok {
+		return -1
+	}
+	first, _, ok := strings.Cut(spec, "-")
+	if !ok {
+		return -1
+	}
+	start, err := strconv.ParseInt(strings.TrimSpace(first), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return start
+}
+
+// Helper function to read the sidecar of a part file
+func loadPartState(path string) (*partState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var state partState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	return &state, nil
+}
+
+// Helper function to write the sidecar of a part file
+func savePartState(path string, state partState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("error writing %s: %w", path, err)
+	}
+	return nil
+}
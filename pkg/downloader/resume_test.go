@@ -0,0 +1,163 @@
+package downloader
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hemzaz/lsweb/pkg/robots"
+)
+
+func TestResumeDownload(t *testing.T) {
+	original := bytes.Repeat([]byte("0123456789"), 1000)
+	changed := bytes.Repeat([]byte("abcdefghij"), 1200)
+
+	tests := []struct {
+		name           string
+		path           string
+		ignoreRanges   bool
+		rejectRanges   bool
+		changeFile     bool
+		expectRequests int
+		expectContent  []byte
+	}{
+		{"Resumes with Range", "/file.bin", false, false, false, 2, original},
+		{"Resumes a file named by Content-Disposition", "/download?id=1", false, false, false, 2, original},
+		{"Restarts when the server ignores ranges", "/file.bin", true, false, false, 2, original},
+		{"Restarts when the file changed", "/file.bin", false, false, true, 2, changed},
+		{"Restarts when the range is not satisfiable", "/file.bin", false, true, false, 3, original},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var requests int
+			var ranges []string
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/robots.txt" {
+					http.NotFound(w, r)
+					return
+				}
+
+				mu.Lock()
+				requests++
+				first := requests == 1
+				if r.Header.Get("Range") != "" {
+					ranges = append(ranges, r.Header.Get("Range")+" "+r.Header.Get("If-Range"))
+				}
+				mu.Unlock()
+
+				content, etag := original, `"v1"`
+				if tt.changeFile && !first {
+					content, etag = changed, `"v2"`
+				}
+				w.Header().Set("ETag", etag)
+				if r.URL.Path == "/download" {
+					w.Header().Set("Content-Disposition", `attachment; filename="file.bin"`)
+				}
+
+				if first {
+					// Send half of the file, then drop the connection
+					w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+					w.Write(content[:len(content)/2])
+					w.(http.Flusher).Flush()
+					panic(http.ErrAbortHandler)
+				}
+				if tt.rejectRanges && r.Header.Get("Range") != "" {
+					http.Error(w, "range not satisfiable", http.StatusRequestedRangeNotSatisfiable)
+					return
+				}
+				if tt.ignoreRanges {
+					w.Write(content)
+					return
+				}
+				http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+			}))
+			defer server.Close()
+			defer robots.Reset()
+
+			dir := t.TempDir()
+			SetOutputDir(dir)
+			defer SetOutputDir("")
+			filename := filepath.Join(dir, "file.bin")
+			fileURL := server.URL + tt.path
+
+			err := DownloadFile(fileURL, false, false)
+			if err == nil || !strings.Contains(err.Error(), "resume") {
+				t.Fatalf("Expected the first download to fail and be resumable, got %v", err)
+			}
+			state, err := loadPartState(statePath(fileURL))
+			if err != nil {
+				t.Fatalf("Expected a sidecar for the part file: %v", err)
+			}
+			if state.URL != fileURL || state.Path != filename || state.ETag != `"v1"` || state.Bytes != int64(len(original)/2) {
+				t.Errorf("Unexpected sidecar %+v", state)
+			}
+
+			if err := DownloadFile(fileURL, false, false); err != nil {
+				t.Fatalf("Expected the second download to succeed, got %v", err)
+			}
+
+			content, err := os.ReadFile(filename)
+			if err != nil {
+				t.Fatalf("Expected %s to be downloaded: %v", filename, err)
+			}
+			if !bytes.Equal(content, tt.expectContent) {
+				t.Errorf("Expected %d bytes of the right content, got %d bytes", len(tt.expectContent), len(content))
+			}
+			for _, leftover := range []string{filename + partSuffix, statePath(fileURL)} {
+				if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+					t.Errorf("Expected %s to be removed", leftover)
+				}
+			}
+
+			// The retry asks for the rest of the file on its first request
+			if requests != tt.expectRequests {
+				t.Errorf("Expected %d requests, got %d", tt.expectRequests, requests)
+			}
+			if len(ranges) != 1 || ranges[0] != "bytes=5000- \"v1\"" {
+				t.Errorf("Expected one range request for the rest of the file, got %q", ranges)
+			}
+		})
+	}
+}
+
+func TestContentRangeStart(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected int64
+	}{
+		{"bytes 100-199/200", 100},
+		{"bytes 0-0/*", 0},
+		{"bytes */200", -1},
+		{"items 1-2/3", -1},
+		{"", -1},
+	}
+
+	for _, tt := range tests {
+		if got := contentRangeStart(tt.header); got != tt.expected {
+			t.Errorf("contentRangeStart(%q) = %d, expected %d", tt.header, got, tt.expected)
+		}
+	}
+}
+
+func TestIfRangeValidator(t *testing.T) {
+	lastModified := "Wed, 21 Oct 2026 07:28:00 GMT"
+	if got := ifRangeValidator(&partState{ETag: `"abc"`, LastModified: lastModified}); got != `"abc"` {
+		t.Errorf("Expected the strong ETag, got %q", got)
+	}
+	if got := ifRangeValidator(&partState{ETag: `W/"abc"`, LastModified: lastModified}); got != lastModified {
+		t.Errorf("Expected Last-Modified instead of a weak ETag, got %q", got)
+	}
+	if got := ifRangeValidator(&partState{ETag: `W/"abc"`}); got != "" {
+		t.Errorf("Expected no validator, got %q", got)
+	}
+}
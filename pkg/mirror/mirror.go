@@ -0,0 +1,325 @@
+// Package mirror saves pages and files in a directory tree that follows their hosts
+// and paths, and rewrites the links between saved HTML and CSS files to relative
+// local paths so that the copy can be browsed offline.
+package mirror
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	// Internal dependencies
+	"github.com/hemzaz/lsweb/pkg/common"
+	"github.com/hemzaz/lsweb/pkg/crawler"
+	"github.com/hemzaz/lsweb/pkg/parser"
+	"github.com/hemzaz/lsweb/pkg/robots"
+)
+
+// indexName is the file name given to URLs that end in a directory
+const indexName = "index.html"
+
+// Options configures a mirror
+type Options struct {
+	// Dir is the directory the host directories are created in; empty means the
+	// current directory
+	Dir string
+
+	// IgnoreCert skips TLS certificate validation
+	IgnoreCert bool
+
+	// Allow reports whether a URL referenced by a saved stylesheet, such as a font or
+	// background image, is mirrored too; nil mirrors all of them
+	Allow func(rawURL string) bool
+
+	// Output receives the progress lines; nil means os.Stdout. Errors go to os.Stderr.
+	Output io.Writer
+}
+
+// File is a URL the mirror saved, or failed to save
+type File struct {
+	URL string
+
+	// FinalURL is the URL the request ended at after redirects
+	FinalURL string
+
+	// Path is where the URL was saved, relative to Options.Dir and slash-separated
+	Path string
+
+	ContentType string
+	Err         error
+}
+
+// Result holds what a mirror saved
+type Result struct {
+	// Files lists each URL in the order it was fetched
+	Files []File
+}
+
+// Failed returns the number of URLs that could not be saved.
+func (r *Result) Failed() int {
+	failed := 0
+	for _, file := range r.Files {
+		if file.Err != nil {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Mirror saves each URL under options.Dir at its LocalPath, then rewrites the links
+// in the saved HTML and CSS files: links to saved URLs become relative local paths
+// and every other link becomes absolute, so nothing points back at a relative URL
+// that was not saved. The url() and @import references of saved stylesheets, such as
+// background images and fonts, are saved too if options.Allow allows them. Existing
+// files are overwritten, so mirroring again refreshes the copy. Each fetch goes
+// through robots.Wait. URLs that fail are recorded with their error and the others
+// are still saved.
+//
+// Mirror fetches every URL itself, so pages found by a crawl are fetched a second
+// time: the crawl extracts links from decoded and transcoded bodies and does not keep
+// them, while the mirror saves the bytes exactly as served.
+func Mirror(urls []string, options Options) *Result {
+	output := options.Output
+	if output == nil {
+		output = os.Stdout
+	}
+
+	result := &Result{}
+	saved := make(map[string]string)
+	seen := make(map[string]bool)
+
+	queue := append([]string(nil), urls...)
+	for i := 0; i < len(queue); i++ {
+		target, err := url.Parse(queue[i])
+		if err != nil || target.Host == "" {
+			continue
+		}
+		key := crawler.NormalizeURL(target)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		target.Fragment = ""
+		target.RawFragment = ""
+
+		fmt.Fprintf(output, "[%d/%d] Mirroring: %s\n", i+1, len(queue), target)
+		file := saveURL(target.String(), options)
+		result.Files = append(result.Files, file)
+		if file.Err != nil {
+			fmt.Fprintf(os.Stderr, "Error mirroring %s: %v\n", file.URL, file.Err)
+			continue
+		}
+
+		// Links to the URL a redirect ended at are rewritten to the same file
+		saved[key] = file.Path
+		if final, err := url.Parse(file.FinalURL); err == nil {
+			if finalKey := crawler.NormalizeURL(final); saved[finalKey] == "" {
+				saved[finalKey] = file.Path
+			}
+		}
+
+		// The crawl does not look inside stylesheets, so queue what they reference
+		if isCSSFile(file) {
+			queue = append(queue, stylesheetLinks(file, options)...)
+		}
+	}
+
+	for i, file := range result.Files {
+		if file.Err == nil {
+			if err := rewriteFile(file, saved, options.Dir); err != nil {
+				result.Files[i].Err = err
+				fmt.Fprintf(os.Stderr, "Error rewriting links in %s: %v\n", file.Path, err)
+			}
+		}
+	}
+
+	return result
+}
+
+// LocalPath returns the slash-separated path a URL is saved at: its host, followed by
+// its path with URLs ending in a directory saved as index.html. A query is appended
+// to the file name after an "@", and HTML saved under a name without an HTML extension
+// gets ".html" added so that browsers open it as a page. Characters that are not
+// allowed in file names are replaced and dot segments are dropped, so the path always
+// stays inside the host's directory.
+func LocalPath(u *url.URL, isHTML bool) string {
+	host := u.Hostname()
+	if port := u.Port(); port != "" && !(u.Scheme == "http" && port == "80") && !(u.Scheme == "https" && port == "443") {
+		host += "_" + port
+	}
+	segments := []string{common.SanitizeFileName(strings.ToLower(host))}
+
+	for _, segment := range strings.Split(u.Path, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			continue
+		}
+		segments = append(segments, common.SanitizeFileName(segment))
+	}
+	if len(segments) == 1 || strings.HasSuffix(u.Path, "/") {
+		segments = append(segments, indexName)
+	}
+
+	name := segments[len(segments)-1]
+	if u.RawQuery != "" {
+		name += "@" + common.SanitizeFileName(u.RawQuery)
+	}
+	if isHTML {
+		if ext := strings.ToLower(path.Ext(name)); ext != ".html" && ext != ".htm" {
+			name += ".html"
+		}
+	}
+	segments[len(segments)-1] = name
+
+	return path.Join(segments...)
+}
+
+// Helper function to fetch one URL and save it at its local path
+func saveURL(rawURL string, options Options) File {
+	file := File{URL: rawURL}
+
+	if err := robots.Wait(rawURL, options.IgnoreCert); err != nil {
+		file.Err = err
+		return file
+	}
+
+	// Bound the wait for headers rather than the whole transfer
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = common.DefaultTimeout
+	if options.IgnoreCert {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	client := &http.Client{Transport: transport}
+
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		file.Err = fmt.Errorf("error creating request: %w", err)
+		return file
+	}
+	req.Header.Set("User-Agent", common.UserAgent)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		file.Err = fmt.Errorf("error fetching %s: %w", rawURL, err)
+		return file
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		file.Err = fmt.Errorf("server returned non-success status: %d %s", resp.StatusCode, resp.Status)
+		return file
+	}
+
+	file.ContentType = resp.Header.Get("Content-Type")
+	target, _ := url.Parse(rawURL)
+	file.Path = LocalPath(target, isHTMLContentType(file.ContentType))
+
+	localPath := filepath.Join(options.Dir, filepath.FromSlash(file.Path))
+	if err := os.MkdirAll(filepath.Dir(localPath), 0o755); err != nil {
+		file.Err = fmt.Errorf("error creating directory: %w", err)
+		return file
+	}
+	out, err := os.Create(localPath)
+	if err != nil {
+		file.Err = fmt.Errorf("error creating file %s: %w", localPath, err)
+		return file
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, resp.Body); err != nil {
+		// On error, clean up the partial file
+		out.Close()
+		os.Remove(localPath)
+		file.Err = fmt.Errorf("error writing to file: %w", err)
+		return file
+	}
+
+	file.FinalURL = resp.Request.URL.String()
+	return file
+}
+
+// Helper function to rewrite the links of a saved HTML or CSS file to point at the
+// local copies of saved URLs. Other files are left alone.
+func rewriteFile(file File, saved map[string]string, dir string) error {
+	isHTML := isHTMLContentType(file.ContentType)
+	if !isHTML && !isCSSFile(file) {
+		return nil
+	}
+
+	// Relative links resolve against the URL the page was served from
+	baseURL, err := url.Parse(file.FinalURL)
+	if err != nil {
+		return err
+	}
+	localPath := filepath.Join(dir, filepath.FromSlash(file.Path))
+	body, err := os.ReadFile(localPath)
+	if err != nil {
+		return err
+	}
+
+	rewrite := func(link string) string {
+		target, err := url.Parse(link)
+		if err != nil {
+			return link
+		}
+		fragment := target.Fragment
+		local, ok := saved[crawler.NormalizeURL(target)]
+		if !ok {
+			return link
+		}
+
+		rel, err := filepath.Rel(filepath.FromSlash(path.Dir(file.Path)), filepath.FromSlash(local))
+		if err != nil {
+			return link
+		}
+		ref := &url.URL{Path: filepath.ToSlash(rel), Fragment: fragment}
+		return ref.String()
+	}
+
+	var rewritten []byte
+	if isHTML {
+		rewritten = parser.RewriteHTMLLinks(body, baseURL, rewrite)
+	} else {
+		rewritten = []byte(parser.RewriteCSSLinks(string(body), baseURL, rewrite))
+	}
+	return os.WriteFile(localPath, rewritten, 0o644)
+}
+
+// Helper function to return the http and https URLs a saved stylesheet references
+// that options.Allow allows
+func stylesheetLinks(file File, options Options) []string {
+	baseURL, err := url.Parse(file.FinalURL)
+	if err != nil {
+		return nil
+	}
+	body, err := os.ReadFile(filepath.Join(options.Dir, filepath.FromSlash(file.Path)))
+	if err != nil {
+		return nil
+	}
+
+	var links []string
+	for _, link := range parser.ExtractCSSLinks(string(body), baseURL) {
+		if !strings.HasPrefix(link, "http://") && !strings.HasPrefix(link, "https://") {
+			continue
+		}
+		if options.Allow == nil || options.Allow(link) {
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// Helper function to report whether a saved file is a stylesheet
+func isCSSFile(file File) bool {
+	return strings.Contains(file.ContentType, "text/css") || strings.HasSuffix(file.Path, ".css")
+}
+
+// Helper function to report whether a Content-Type header value denotes an HTML page
+func isHTMLContentType(contentType string) bool {
+	return strings.Contains(contentType, "text/html") || strings.Contains(contentType, "application/xhtml+xml")
+}
@@ -0,0 +1,139 @@
+package mirror
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hemzaz/lsweb/pkg/robots"
+)
+
+func TestMain(m *testing.M) {
+	// The test servers are local, so there is no need to space out requests
+	robots.SetHostDelay(0)
+	os.Exit(m.Run())
+}
+
+func TestLocalPath(t *testing.T) {
+	tests := []struct {
+		input    string
+		isHTML   bool
+		expected string
+	}{
+		{"https://example.com", true, "example.com/index.html"},
+		{"https://Example.com/docs/", true, "example.com/docs/index.html"},
+		{"https://example.com/docs/guide.html", true, "example.com/docs/guide.html"},
+		{"https://example.com/about", true, "example.com/about.html"},
+		{"https://example.com/download.php?id=42", true, "example.com/download.php@id=42.html"},
+		{"https://example.com/files/app.tar.gz", false, "example.com/files/app.tar.gz"},
+		{"https://example.com/files/app.zip?a=1&b=x/y", false, "example.com/files/app.zip@a=1&b=x_y"},
+		{"http://example.com:8080/a.txt", false, "example.com_8080/a.txt"},
+		{"https://example.com:443/a.txt", false, "example.com/a.txt"},
+		{"https://example.com/a/../../b/./c%3A.txt", false, "example.com/a/b/c_.txt"},
+		{"https://example.com/%2e%2e/%2e%2e/etc/passwd", false, "example.com/etc/passwd"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			u, err := url.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Invalid test URL: %v", err)
+			}
+			if result := LocalPath(u, tt.isHTML); result != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestMirror(t *testing.T) {
+	// A third-party host the stylesheet references, which is not to be mirrored
+	var cdnRequests atomic.Int32
+	cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			cdnRequests.Add(1)
+		}
+		http.NotFound(w, r)
+	}))
+	defer cdn.Close()
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintf(w, `<link rel="stylesheet" href="/css/site.css"><a href="docs">Docs</a> <a href="%s/files/app.zip">App</a> <a href="/missing">Missing</a>`, server.URL)
+		case "/docs":
+			http.Redirect(w, r, "/docs/", http.StatusFound)
+		case "/docs/":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<a href="../">Home</a> <a href="../files/app.zip#v1">App</a> <img src="logo.png">`)
+		case "/css/site.css":
+			w.Header().Set("Content-Type", "text/css")
+			fmt.Fprintf(w, `body { background: url(../docs/logo.png) } @font-face { src: url("/fonts/a.woff2"), url(%s/b.woff2) }`, cdn.URL)
+		case "/docs/logo.png", "/files/app.zip", "/fonts/a.woff2":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			fmt.Fprint(w, "data")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	defer robots.Reset()
+
+	dir := t.TempDir()
+	urls := []string{
+		server.URL + "/",
+		server.URL + "/docs",
+		server.URL + "/css/site.css",
+		server.URL + "/files/app.zip",
+		server.URL + "/files/app.zip#again",
+		server.URL + "/missing",
+	}
+	options := Options{
+		Dir: dir,
+		Allow: func(rawURL string) bool {
+			return strings.HasPrefix(rawURL, server.URL+"/")
+		},
+		Output: io.Discard,
+	}
+	result := Mirror(urls, options)
+
+	// The stylesheet's background image and font are saved as well, but not the
+	// font on the host Allow rejects
+	if len(result.Files) != 7 || result.Failed() != 1 {
+		t.Fatalf("Expected 7 URLs with 1 failure, got %+v", result.Files)
+	}
+	if cdnRequests.Load() != 0 {
+		t.Errorf("Expected no requests to the third-party host, got %d", cdnRequests.Load())
+	}
+
+	u, _ := url.Parse(server.URL)
+	host := strings.ReplaceAll(u.Host, ":", "_")
+	expected := map[string]string{
+		"index.html": `<link rel="stylesheet" href="css/site.css"><a href="docs.html">Docs</a> <a href="files/app.zip">App</a> <a href="` +
+			server.URL + `/missing">Missing</a>`,
+		"docs.html":     `<a href="index.html">Home</a> <a href="files/app.zip#v1">App</a> <img src="docs/logo.png">`,
+		"css/site.css":  `body { background: url(../docs/logo.png) } @font-face { src: url("../fonts/a.woff2"), url(` + cdn.URL + `/b.woff2) }`,
+		"files/app.zip": "data",
+		"docs/logo.png": "data",
+		"fonts/a.woff2": "data",
+	}
+	for name, content := range expected {
+		data, err := os.ReadFile(filepath.Join(dir, host, filepath.FromSlash(name)))
+		if err != nil {
+			t.Errorf("Expected %s to be saved: %v", name, err)
+			continue
+		}
+		if string(data) != content {
+			t.Errorf("Expected %s to hold %q, got %q", name, content, string(data))
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+
+	"golang.org/x/net/html/charset"
+)
+
+// Helper function to transcode an HTML body to UTF-8. The encoding is taken from a
+// byte order mark, the charset parameter of contentType, or a <meta charset> or
+// <meta http-equiv="Content-Type"> tag in the first 1024 bytes, in that order.
+// Undeclared bodies that are not valid UTF-8 are decoded as Windows-1252, as browsers do.
+func decodeHTML(body []byte, contentType string) ([]byte, error) {
+	encoding, name, _ := charset.DetermineEncoding(body, contentType)
+	if name == "utf-8" {
+		return body, nil
+	}
+
+	decoded, err := encoding.NewDecoder().Bytes(body)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding %s content: %w", name, err)
+	}
+	return decoded, nil
+}
+
+// Helper function to wrap an HTML stream in a reader that transcodes it to UTF-8,
+// detecting the encoding as decodeHTML does from the first 1024 bytes read.
+func newHTMLReader(r io.Reader, contentType string) (io.Reader, error) {
+	reader, err := charset.NewReader(r, contentType)
+	if err != nil {
+		return nil, fmt.Errorf("error detecting character encoding: %w", err)
+	}
+	return reader, nil
+}
@@ -0,0 +1,124 @@
+package parser
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hemzaz/lsweb/pkg/common"
+)
+
+func TestDecodeHTML(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        []byte
+		contentType string
+		expected    string
+	}{
+		{
+			name:        "Windows-1251 from Content-Type header",
+			body:        []byte("<a href=\"/\xcf\xf0\xe8\xe2\xe5\xf2.zip\">x</a>"),
+			contentType: "text/html; charset=windows-1251",
+			expected:    `<a href="/Привет.zip">x</a>`,
+		},
+		{
+			name:     "Shift_JIS from meta charset",
+			body:     []byte("<meta charset=\"Shift_JIS\"><a href=\"/\x93\xfa\x96\x7b.html\">x</a>"),
+			expected: `<meta charset="Shift_JIS"><a href="/日本.html">x</a>`,
+		},
+		{
+			name:     "ISO-8859-1 from meta http-equiv",
+			body:     []byte("<meta http-equiv=\"Content-Type\" content=\"text/html; charset=iso-8859-1\"><a href=\"caf\xe9\">x</a>"),
+			expected: `<meta http-equiv="Content-Type" content="text/html; charset=iso-8859-1"><a href="café">x</a>`,
+		},
+		{
+			name:     "UTF-8 byte order mark wins over meta charset",
+			body:     []byte("\xef\xbb\xbf<meta charset=\"windows-1251\"><a href=\"/Привет\">x</a>"),
+			expected: "\ufeff<meta charset=\"windows-1251\"><a href=\"/Привет\">x</a>",
+		},
+		{
+			name:        "UTF-8 is unchanged",
+			body:        []byte(`<a href="/日本">x</a>`),
+			contentType: "text/html; charset=utf-8",
+			expected:    `<a href="/日本">x</a>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded, err := decodeHTML(tt.body, tt.contentType)
+			if err != nil {
+				t.Fatalf("decodeHTML failed: %v", err)
+			}
+			if string(decoded) != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, decoded)
+			}
+		})
+	}
+}
+
+func TestFetchLinksCharset(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=windows-1251")
+		_, _ = w.Write([]byte("<html><body><a href=\"/\xcf\xf0\xe8\xe2\xe5\xf2.zip\">\xcf\xf0\xe8\xe2\xe5\xf2</a></body></html>"))
+	}))
+	defer server.Close()
+
+	expectedURL := server.URL + "/" + url.PathEscape("Привет") + ".zip"
+
+	links, err := FetchLinks(server.URL, false)
+	if err != nil {
+		t.Fatalf("FetchLinks failed: %v", err)
+	}
+	if len(links) != 1 || links[0].URL != expectedURL || links[0].Text != "Привет" {
+		t.Errorf("Expected %s with text Привет, got %+v", expectedURL, links)
+	}
+
+	// The streaming extractor transcodes the same way
+	streamed, errc := StreamLinks(context.Background(), server.URL, false)
+	var urls []string
+	for link := range streamed {
+		urls = append(urls, link.URL)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("StreamLinks failed: %v", err)
+	}
+	if len(urls) != 1 || urls[0] != expectedURL {
+		t.Errorf("Expected streamed %s, got %v", expectedURL, urls)
+	}
+}
+
+func TestReadLinksCharset(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "page.html")
+	content := "<html><head><meta charset=\"Shift_JIS\"></head><body><a href=\"https://example.jp/\x93\xfa\x96\x7b.html\">x</a></body></html>"
+	if err := os.WriteFile(filePath, []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	links, err := ReadLinks(filePath)
+	if err != nil {
+		t.Fatalf("ReadLinks failed: %v", err)
+	}
+
+	expected := "https://example.jp/" + url.PathEscape("日本") + ".html"
+	if len(links) != 1 || links[0].URL != expected {
+		t.Errorf("Expected %s, got %+v", expected, links)
+	}
+
+	var streamed []string
+	err = StreamLinksFromReader(strings.NewReader(content), filePath, &url.URL{Scheme: "file", Path: filePath}, func(link common.Link) bool {
+		streamed = append(streamed, link.URL)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("StreamLinksFromReader failed: %v", err)
+	}
+	if len(streamed) != 1 || streamed[0] != expected {
+		t.Errorf("Expected streamed %s, got %v", expected, streamed)
+	}
+}
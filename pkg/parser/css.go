@@ -0,0 +1,98 @@
+package parser
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hemzaz/lsweb/pkg/common"
+)
+
+// cssCommentPattern matches CSS comments, which may span lines
+var cssCommentPattern = regexp.MustCompile(`(?s)/\*.*?\*/`)
+
+// cssReferencePattern matches url() tokens, optionally preceded by @import, and
+// @import rules with a plain string. Submatches 1 marks an @import url(); 2-4 hold
+// the double-quoted, single-quoted or unquoted url() value; 5-6 the @import string.
+var cssReferencePattern = regexp.MustCompile(
+	`(?i)(@import\s+)?url\(\s*(?:"((?:[^"\\]|\\.)*)"|'((?:[^'\\]|\\.)*)'|((?:[^"'()\s\\]|\\[0-9a-fA-F]{1,6}[ \t\n\r\f]?|\\.)*))\s*\)` +
+		`|@import\s+(?:"((?:[^"\\]|\\.)*)"|'((?:[^'\\]|\\.)*)')`)
+
+// cssEscapePattern matches a CSS escape: up to six hex digits and an optional
+// whitespace character, or any other single escaped character
+var cssEscapePattern = regexp.MustCompile(`\\(?:([0-9a-fA-F]{1,6})[ \t\n\r\f]?|((?s:.)))`)
+
+// ExtractCSSLinks returns the url() and @import references of a stylesheet, resolved
+// against baseURL, which should be the stylesheet's own URL.
+func ExtractCSSLinks(css string, baseURL *url.URL) []string {
+	return common.URLs(collectCSSLinks(css, baseURL, "css"))
+}
+
+// Helper function to extract the url() and @import references from a stylesheet,
+// resolved against baseURL, which should be the stylesheet's own URL.
+// Each link has the given element and an attribute of "url" or "@import".
+// data:, fragment-only and malformed references are skipped.
+func collectCSSLinks(css string, baseURL *url.URL, element string) []common.Link {
+	var links []common.Link
+	visited := make(map[string]bool)
+
+	css = cssCommentPattern.ReplaceAllString(css, "")
+	for _, match := range cssReferencePattern.FindAllStringSubmatch(css, -1) {
+		attribute := "url"
+		if match[1] != "" || match[5] != "" || match[6] != "" {
+			attribute = "@import"
+		}
+
+		raw := ""
+		for _, value := range match[2:] {
+			if value != "" {
+				raw = value
+				break
+			}
+		}
+
+		raw = strings.TrimSpace(unescapeCSS(raw))
+		if raw == "" || strings.HasPrefix(raw, "#") {
+			continue
+		}
+
+		urlStr, err := resolveLink(raw, baseURL)
+		if err != nil || urlStr == "" || visited[urlStr] {
+			continue
+		}
+		visited[urlStr] = true
+		links = append(links, common.Link{URL: urlStr, Element: element, Attribute: attribute})
+	}
+
+	return links
+}
+
+// Helper function to decode CSS escapes such as \" or \20 in a string or url() value
+func unescapeCSS(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+
+	return cssEscapePattern.ReplaceAllStringFunc(value, func(escape string) string {
+		match := cssEscapePattern.FindStringSubmatch(escape)
+		if match[1] == "" {
+			// Escaped newlines are line continuations
+			if match[2] == "\n" {
+				return ""
+			}
+			return match[2]
+		}
+
+		code, err := strconv.ParseUint(match[1], 16, 32)
+		if err != nil || code == 0 || code > 0x10FFFF {
+			return "\uFFFD"
+		}
+		return string(rune(code))
+	})
+}
+
+// Helper function to report whether a Content-Type header value denotes a stylesheet
+func isCSSContentType(contentType string) bool {
+	return strings.Contains(contentType, "text/css")
+}
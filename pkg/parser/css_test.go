@@ -0,0 +1,172 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+
+	"github.com/hemzaz/lsweb/pkg/common"
+)
+
+func TestExtractLinksFromCSS(t *testing.T) {
+	baseURL, _ := url.Parse("https://example.com/assets/css/site.css")
+
+	tests := []struct {
+		name     string
+		css      string
+		expected []string
+	}{
+		{
+			name:     "Unquoted, single and double quoted url()",
+			css:      `a { background: url(img/a.png) } b { background: url('img/b.png') } c { background: URL( "../img/c.png" ) }`,
+			expected: []string{"https://example.com/assets/css/img/a.png", "https://example.com/assets/css/img/b.png", "https://example.com/assets/img/c.png"},
+		},
+		{
+			name:     "@import with string and url()",
+			css:      `@import "reset.css"; @import url("/fonts/fonts.css") screen;`,
+			expected: []string{"https://example.com/assets/css/reset.css", "https://example.com/fonts/fonts.css"},
+		},
+		{
+			name: "@font-face sources",
+			css: `@font-face { font-family: X; src: url(x.woff2) format("woff2"),
+				url(x.woff) format("woff"); }`,
+			expected: []string{"https://example.com/assets/css/x.woff2", "https://example.com/assets/css/x.woff"},
+		},
+		{
+			name:     "Comments, data URIs and fragments are skipped",
+			css:      `/* url(commented.png) */ a { background: url(data:image/png;base64,AAAA) } b { filter: url(#blur) } c { background: url() }`,
+			expected: nil,
+		},
+		{
+			name:     "Escapes are decoded",
+			css:      `a { background: url("my\ image.png") } b { background: url(caf\e9 .png) }`,
+			expected: []string{"https://example.com/assets/css/my%20image.png", "https://example.com/assets/css/caf%C3%A9.png"},
+		},
+		{
+			name:     "Duplicates are removed",
+			css:      `a { background: url(a.png) } b { background: url("a.png") }`,
+			expected: []string{"https://example.com/assets/css/a.png"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			links := ExtractCSSLinks(tt.css, baseURL)
+			if len(links) != len(tt.expected) {
+				t.Fatalf("Expected %d links, got %d: %v", len(tt.expected), len(links), links)
+			}
+			for i, link := range links {
+				if link != tt.expected[i] {
+					t.Errorf("Expected %s at position %d, got %s", tt.expected[i], i, link)
+				}
+			}
+		})
+	}
+
+	links := collectCSSLinks(`@import "a.css"; b { background: url(b.png) }`, baseURL, "css")
+	if len(links) != 2 || links[0].Attribute != "@import" || links[1].Attribute != "url" || links[0].Element != "css" {
+		t.Errorf("Unexpected link metadata: %+v", links)
+	}
+}
+
+func TestExtractLinksFromHTMLWithStyle(t *testing.T) {
+	defer func() {
+		_ = SetTags(nil)
+	}()
+	if err := SetTags([]string{"a", "style"}); err != nil {
+		t.Fatalf("SetTags failed: %v", err)
+	}
+
+	page := `<html><head><style>@import "print.css"; body { background: url(/bg.png) }</style></head>
+<body><div style="background-image: url('hero.jpg')"><a href="/page">Page</a></div></body></html>`
+	baseURL, _ := url.Parse("https://example.com/dir/index.html")
+
+	expected := []common.Link{
+		{URL: "https://example.com/dir/print.css", Element: "style", Attribute: "@import"},
+		{URL: "https://example.com/bg.png", Element: "style", Attribute: "url"},
+		{URL: "https://example.com/dir/hero.jpg", Element: "div", Attribute: "style"},
+		{URL: "https://example.com/page", Text: "Page", Element: "a", Attribute: "href"},
+	}
+
+	doc, err := html.Parse(strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("Failed to parse HTML: %v", err)
+	}
+	links, _ := collectHTMLLinks(doc, baseURL)
+	if len(links) != len(expected) {
+		t.Fatalf("Expected %d links, got %d: %+v", len(expected), len(links), links)
+	}
+	for i, link := range links {
+		if link != expected[i] {
+			t.Errorf("Expected %+v at position %d, got %+v", expected[i], i, link)
+		}
+	}
+
+	var streamed []common.Link
+	err = StreamLinksFromReader(strings.NewReader(page), "page.html", baseURL, func(link common.Link) bool {
+		link.Source, link.Position = "", 0
+		streamed = append(streamed, link)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("StreamLinksFromReader failed: %v", err)
+	}
+	if len(streamed) != len(expected) {
+		t.Fatalf("Expected %d streamed links, got %d: %+v", len(expected), len(streamed), streamed)
+	}
+	for i, link := range streamed {
+		if link != expected[i] {
+			t.Errorf("Expected streamed %+v at position %d, got %+v", expected[i], i, link)
+		}
+	}
+}
+
+func TestExtractLinksFromURLStylesheet(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/css; charset=utf-8")
+		fmt.Fprint(w, `@import "theme.css"; .logo { background: url(../img/logo.svg) }`)
+	}))
+	defer server.Close()
+
+	links, err := ExtractLinksFromURL(server.URL+"/static/css/main.css", false)
+	if err != nil {
+		t.Fatalf("ExtractLinksFromURL failed: %v", err)
+	}
+
+	expected := []string{server.URL + "/static/css/theme.css", server.URL + "/static/img/logo.svg"}
+	if len(links) != len(expected) || links[0] != expected[0] || links[1] != expected[1] {
+		t.Errorf("Expected %v, got %v", expected, links)
+	}
+
+	streamed, errc := StreamLinks(context.Background(), server.URL+"/static/css/main.css", false)
+	count := 0
+	for range streamed {
+		count++
+	}
+	if err := <-errc; err != nil || count != len(expected) {
+		t.Errorf("Expected %d streamed links, got %d (err %v)", len(expected), count, err)
+	}
+}
+
+func TestReadLinksStylesheet(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "style.css")
+	if err := os.WriteFile(filePath, []byte(`body { background: url(https://cdn.example.com/bg.png) }`), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	links, err := ExtractLinksFromFile(filePath)
+	if err != nil {
+		t.Fatalf("ExtractLinksFromFile failed: %v", err)
+	}
+	if len(links) != 1 || links[0] != "https://cdn.example.com/bg.png" {
+		t.Errorf("Unexpected links: %v", links)
+	}
+}
@@ -0,0 +1,161 @@
+package parser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/hemzaz/lsweb/pkg/common"
+)
+
+// acceptEncoding is the Accept-Encoding header sent with requests whose bodies lsweb decodes itself
+const acceptEncoding = "gzip, br, zstd"
+
+// maxDecompressedSize limits how large a decompressed body may grow, guarding against decompression bombs
+var maxDecompressedSize int64 = common.MaxContentSize
+
+// SetMaxDecompressedSize sets the maximum size in bytes of a decompressed body or file.
+// Content that would decompress beyond it is rejected rather than truncated.
+func SetMaxDecompressedSize(max int64) {
+	if max > 0 {
+		maxDecompressedSize = max
+	}
+}
+
+// Helper function to identify how a body is compressed: "gzip", "br", "zstd", or "" if it is not.
+// The Content-Encoding value wins; otherwise gzip and zstd are recognized by their magic
+// bytes, and brotli, which has none, by a .br extension on name for bodies that are not text.
+func compressionFormat(body []byte, contentEncoding, name string) string {
+	switch strings.ToLower(strings.TrimSpace(contentEncoding)) {
+	case "gzip", "x-gzip":
+		return "gzip"
+	case "br":
+		return "br"
+	case "zstd":
+		return "zstd"
+	}
+
+	switch {
+	case bytes.HasPrefix(body, []byte{0x1f, 0x8b}):
+		return "gzip"
+	case bytes.HasPrefix(body, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return "zstd"
+	case strings.EqualFold(path.Ext(name), ".br") &&
+		!strings.HasPrefix(http.DetectContentType(body), "text/"):
+		return "br"
+	}
+	return ""
+}
+
+// Helper function to wrap r in a reader that decompresses the given format
+func newDecompressingReader(r io.Reader, format string) (io.ReadCloser, error) {
+	switch format {
+	case "gzip":
+		reader, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("error decompressing gzip content: %w", err)
+		}
+		return reader, nil
+	case "br":
+		return io.NopCloser(brotli.NewReader(r)), nil
+	case "zstd":
+		decoder, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("error decompressing zstd content: %w", err)
+		}
+		return decoder.IOReadCloser(), nil
+	}
+	return nil, fmt.Errorf("unsupported compression: %s", format)
+}
+
+// Helper function to decompress a body in the given format, failing if it
+// decompresses to more than maxDecompressedSize bytes.
+func decompressBody(body []byte, format string) ([]byte, error) {
+	reader, err := newDecompressingReader(bytes.NewReader(body), format)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	decompressed, err := io.ReadAll(io.LimitReader(reader, maxDecompressedSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing %s content: %w", format, err)
+	}
+	if int64(len(decompressed)) > maxDecompressedSize {
+		return nil, fmt.Errorf("decompressed %s content exceeds the limit of %d bytes", format, maxDecompressedSize)
+	}
+	return decompressed, nil
+}
+
+// Helper function to decompress a body detected as gzip, brotli or zstd by its
+// magic bytes or the extension of name. Other bodies are returned unchanged.
+func decompressIfCompressed(body []byte, name string) ([]byte, error) {
+	format := compressionFormat(body, "", name)
+	if format == "" {
+		return body, nil
+	}
+	return decompressBody(body, format)
+}
+
+// Helper function to read a response body up to common.MaxContentSize and decode its
+// Content-Encoding. Requests should send acceptEncoding so that Go's transport leaves
+// the body encoded; an encoding of "identity" or none leaves the body as it is.
+// A longer body is cut at the limit with a warning on stderr.
+func readResponseBody(resp *http.Response) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, common.MaxContentSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %w", err)
+	}
+	if len(body) > common.MaxContentSize {
+		body = body[:common.MaxContentSize]
+		name := "response"
+		if resp.Request != nil && resp.Request.URL != nil {
+			name = resp.Request.URL.String()
+		}
+		fmt.Fprintf(os.Stderr, "Warning: %s is larger than %d bytes, only the first %d bytes were read (use -stream for large HTML pages)\n", name, common.MaxContentSize, common.MaxContentSize)
+	}
+
+	contentEncoding := resp.Header.Get("Content-Encoding")
+	if contentEncoding == "" || strings.EqualFold(contentEncoding, "identity") {
+		return body, nil
+	}
+
+	format := compressionFormat(nil, contentEncoding, "")
+	if format == "" {
+		return nil, fmt.Errorf("unsupported content encoding: %s", contentEncoding)
+	}
+	return decompressBody(body, format)
+}
+
+// Helper function to return the extension of a file name, ignoring a .gz, .br or .zst
+// compression suffix, so that "style.css.gz" has the extension ".css"
+func fileExtension(name string) string {
+	ext := path.Ext(name)
+	switch strings.ToLower(ext) {
+	case ".gz", ".br", ".zst":
+		return path.Ext(strings.TrimSuffix(name, ext))
+	}
+	return ext
+}
+
+// Helper function to report whether a Content-Type header value denotes a gzip file
+func isGzipContentType(contentType string) bool {
+	return strings.Contains(contentType, "application/gzip") ||
+		strings.Contains(contentType, "application/x-gzip")
+}
+
+// Helper function to report whether a Content-Type header value denotes a gzip, brotli or zstd file
+func isCompressedContentType(contentType string) bool {
+	return isGzipContentType(contentType) ||
+		strings.Contains(contentType, "application/zstd") ||
+		strings.Contains(contentType, "application/x-zstd") ||
+		strings.Contains(contentType, "application/x-brotli")
+}
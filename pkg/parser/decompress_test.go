@@ -0,0 +1,225 @@
+package parser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/hemzaz/lsweb/pkg/common"
+)
+
+// compressForTest compresses data in the given format ("gzip", "br" or "zstd")
+func compressForTest(t *testing.T, data []byte, format string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "gzip":
+		writer := gzip.NewWriter(&buf)
+		_, err = writer.Write(data)
+		if err == nil {
+			err = writer.Close()
+		}
+	case "br":
+		writer := brotli.NewWriter(&buf)
+		_, err = writer.Write(data)
+		if err == nil {
+			err = writer.Close()
+		}
+	case "zstd":
+		var writer *zstd.Encoder
+		writer, err = zstd.NewWriter(&buf)
+		if err == nil {
+			_, err = writer.Write(data)
+		}
+		if err == nil {
+			err = writer.Close()
+		}
+	}
+	if err != nil {
+		t.Fatalf("Compressing %s failed: %v", format, err)
+	}
+	return buf.Bytes()
+}
+
+func TestCompressionFormat(t *testing.T) {
+	page := []byte("<html><body></body></html>")
+
+	tests := []struct {
+		name            string
+		body            []byte
+		contentEncoding string
+		fileName        string
+		expected        string
+	}{
+		{"Content-Encoding gzip", page, "gzip", "", "gzip"},
+		{"Content-Encoding x-gzip", page, "x-gzip", "", "gzip"},
+		{"Content-Encoding br", page, "br", "", "br"},
+		{"Content-Encoding zstd", page, "ZSTD", "", "zstd"},
+		{"gzip magic bytes", compressForTest(t, page, "gzip"), "", "page", "gzip"},
+		{"zstd magic bytes", compressForTest(t, page, "zstd"), "", "page", "zstd"},
+		{"brotli by extension", compressForTest(t, page, "br"), "", "page.html.br", "br"},
+		{"text with .br extension", page, "", "page.html.br", ""},
+		{"uncompressed", page, "", "page.html", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if format := compressionFormat(tt.body, tt.contentEncoding, tt.fileName); format != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, format)
+			}
+		})
+	}
+}
+
+func TestDecompressBodyLimit(t *testing.T) {
+	original := maxDecompressedSize
+	defer func() {
+		maxDecompressedSize = original
+	}()
+	SetMaxDecompressedSize(1000)
+
+	for _, format := range []string{"gzip", "br", "zstd"} {
+		t.Run(format, func(t *testing.T) {
+			// A megabyte of zeros compresses to a few hundred bytes
+			bomb := compressForTest(t, make([]byte, 1<<20), format)
+			if _, err := decompressBody(bomb, format); err == nil || !strings.Contains(err.Error(), "exceeds the limit") {
+				t.Errorf("Expected size limit error, got %v", err)
+			}
+
+			small := compressForTest(t, []byte("hello"), format)
+			decompressed, err := decompressBody(small, format)
+			if err != nil {
+				t.Fatalf("decompressBody failed: %v", err)
+			}
+			if string(decompressed) != "hello" {
+				t.Errorf("Expected hello, got %q", decompressed)
+			}
+		})
+	}
+}
+
+func TestReadResponseBodyLimit(t *testing.T) {
+	// Capture the warning
+	stderr := os.Stderr
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stderr = w
+	defer func() {
+		os.Stderr = stderr
+	}()
+
+	request, _ := http.NewRequest("GET", "https://example.com/huge.html", nil)
+	resp := &http.Response{
+		Body:    io.NopCloser(bytes.NewReader(make([]byte, common.MaxContentSize+10))),
+		Header:  http.Header{},
+		Request: request,
+	}
+	body, err := readResponseBody(resp)
+	w.Close()
+	warning, _ := io.ReadAll(r)
+
+	if err != nil {
+		t.Fatalf("readResponseBody failed: %v", err)
+	}
+	if len(body) != common.MaxContentSize {
+		t.Errorf("Expected the body to be cut at %d bytes, got %d", common.MaxContentSize, len(body))
+	}
+	if !strings.Contains(string(warning), "Warning: https://example.com/huge.html is larger than") {
+		t.Errorf("Expected a warning about the cut body, got %q", warning)
+	}
+}
+
+func TestFetchLinksContentEncoding(t *testing.T) {
+	page := []byte(`<html><body><a href="/file.zip">File</a></body></html>`)
+
+	for _, format := range []string{"gzip", "br", "zstd"} {
+		t.Run(format, func(t *testing.T) {
+			var acceptEncoding string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				acceptEncoding = r.Header.Get("Accept-Encoding")
+				w.Header().Set("Content-Type", "text/html")
+				w.Header().Set("Content-Encoding", format)
+				_, _ = w.Write(compressForTest(t, page, format))
+			}))
+			defer server.Close()
+
+			links, err := ExtractLinksFromURL(server.URL, false)
+			if err != nil {
+				t.Fatalf("ExtractLinksFromURL failed: %v", err)
+			}
+			if len(links) != 1 || links[0] != server.URL+"/file.zip" {
+				t.Errorf("Unexpected links: %v", links)
+			}
+			if !strings.Contains(acceptEncoding, format) {
+				t.Errorf("Expected Accept-Encoding to include %s, got %q", format, acceptEncoding)
+			}
+
+			streamed, errc := StreamLinks(context.Background(), server.URL, false)
+			var urls []string
+			for link := range streamed {
+				urls = append(urls, link.URL)
+			}
+			if err := <-errc; err != nil {
+				t.Fatalf("StreamLinks failed: %v", err)
+			}
+			if len(urls) != 1 || urls[0] != server.URL+"/file.zip" {
+				t.Errorf("Unexpected streamed links: %v", urls)
+			}
+		})
+	}
+}
+
+func TestReadLinksCompressedFiles(t *testing.T) {
+	page := []byte(`<html><body><a href="https://example.com/file.zip">File</a></body></html>`)
+	sitemap := []byte(`<?xml version="1.0"?><urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><url><loc>https://example.com/page</loc></url></urlset>`)
+
+	tests := []struct {
+		fileName string
+		content  []byte
+		format   string
+		expected string
+	}{
+		{"page.html.gz", page, "gzip", "https://example.com/file.zip"},
+		{"page.html.br", page, "br", "https://example.com/file.zip"},
+		{"page.html.zst", page, "zstd", "https://example.com/file.zip"},
+		{"sitemap.xml.gz", sitemap, "gzip", "https://example.com/page"},
+	}
+
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.fileName, func(t *testing.T) {
+			filePath := filepath.Join(dir, tt.fileName)
+			if err := os.WriteFile(filePath, compressForTest(t, tt.content, tt.format), 0o644); err != nil {
+				t.Fatalf("WriteFile failed: %v", err)
+			}
+
+			links, err := ExtractLinksFromFile(filePath)
+			if err != nil {
+				t.Fatalf("ExtractLinksFromFile failed: %v", err)
+			}
+			found := false
+			for _, link := range links {
+				if link == tt.expected {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("Expected %s in %v", tt.expected, links)
+			}
+		})
+	}
+}
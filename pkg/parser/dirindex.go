@@ -0,0 +1,381 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+
+	"github.com/hemzaz/lsweb/pkg/common"
+)
+
+// DirEntry is a file or subdirectory listed in a web server directory index.
+// Size is -1 when the listing does not report it, and Modified is the zero
+// time when no modification time is shown.
+type DirEntry struct {
+	Name     string    `json:"name"`
+	URL      string    `json:"url"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+	IsDir    bool      `json:"is_dir"`
+}
+
+// Link converts the entry to a link carrying its name, size, modification time and directory flag.
+func (e DirEntry) Link() common.Link {
+	link := common.Link{
+		URL:      e.URL,
+		Text:     e.Name,
+		Element:  "a",
+		Modified: e.Modified,
+		IsDir:    e.IsDir,
+	}
+	if e.Size > 0 {
+		link.Size = e.Size
+	}
+	return link
+}
+
+// dirIndexDatePattern matches the timestamps used by Apache, nginx and lighttpd listings
+var dirIndexDatePattern = regexp.MustCompile(
+	`\d{4}-\d{2}-\d{2}[ T]\d{2}:\d{2}(?::\d{2})?|\d{2}-[A-Za-z]{3}-\d{4} \d{2}:\d{2}(?::\d{2})?|\d{4}-[A-Za-z]{3}-\d{2} \d{2}:\d{2}(?::\d{2})?`)
+
+// dirIndexDateLayouts are the time layouts tried for a dirIndexDatePattern match
+var dirIndexDateLayouts = []string{
+	"2006-01-02 15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02T15:04:05",
+	"02-Jan-2006 15:04",
+	"02-Jan-2006 15:04:05",
+	"2006-Jan-02 15:04",
+	"2006-Jan-02 15:04:05",
+}
+
+// dirIndexSizePattern matches sizes such as "1234", "1.2M", "4.0K" or "1.2 KiB"
+var dirIndexSizePattern = regexp.MustCompile(`(?i)^(\d+(?:\.\d+)?)\s*([KMGTP]?)(?:i?B|bytes)?$`)
+
+// parentDirectoryNames are anchor texts used for links to the parent directory
+var parentDirectoryNames = map[string]bool{
+	"parent directory":  true,
+	"parent directory/": true,
+	"../":               true,
+	"..":                true,
+	"up":                true,
+}
+
+// ExtractDirectoryIndex fetches a web server directory listing (Apache mod_autoindex,
+// nginx autoindex, lighttpd, Caddy or Python http.server) and returns its entries
+// with name, size, modification time and directory flag where available.
+// Parent directory and sort-column links are skipped.
+// The ignoreCert parameter can be used to skip TLS certificate validation.
+// Returns an error if the fetch fails or the page is not a directory listing.
+func ExtractDirectoryIndex(targetURL string, ignoreCert bool) ([]DirEntry, error) {
+	client := &http.Client{
+		Timeout: common.DefaultTimeout,
+	}
+	if ignoreCert {
+		client.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), common.DefaultTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", targetURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("User-Agent", common.UserAgent)
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching webpage: %w", err)
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			fmt.Fprintf(os.Stderr, "Error closing response body: %v\n", closeErr)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned non-success status: %d %s", resp.StatusCode, resp.Status)
+	}
+
+	bodyBytes, err := readResponseBody(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	bodyBytes, err = decodeHTML(bodyBytes, resp.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := html.Parse(bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing HTML: %w", err)
+	}
+
+	if !isDirectoryIndex(doc) {
+		return nil, fmt.Errorf("%s is not a directory listing", targetURL)
+	}
+
+	return parseDirectoryIndex(doc, resp.Request.URL), nil
+}
+
+// ParseSize parses a human-readable size such as "1500", "1.2M" or "4 KiB" into bytes.
+// Unit prefixes are binary (K = 1024). Returns an error if the size is not recognized.
+func ParseSize(size string) (int64, error) {
+	matches := dirIndexSizePattern.FindStringSubmatch(strings.TrimSpace(size))
+	if matches == nil {
+		return 0, fmt.Errorf("invalid size: %s", size)
+	}
+
+	value, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size: %s", size)
+	}
+
+	multiplier := float64(1)
+	switch strings.ToUpper(matches[2]) {
+	case "K":
+		multiplier = 1 << 10
+	case "M":
+		multiplier = 1 << 20
+	case "G":
+		multiplier = 1 << 30
+	case "T":
+		multiplier = 1 << 40
+	case "P":
+		multiplier = 1 << 50
+	}
+
+	return int64(value * multiplier), nil
+}
+
+// Helper function to recognize directory listings generated by common web servers
+func isDirectoryIndex(doc *html.Node) bool {
+	title := findElement(doc, func(n *html.Node) bool { return n.Data == "title" })
+	if title != nil {
+		text := strings.TrimSpace(nodeText(title))
+		if strings.HasPrefix(text, "Index of ") || strings.HasPrefix(text, "Directory listing for ") {
+			return true
+		}
+	}
+
+	heading := findElement(doc, func(n *html.Node) bool { return n.Data == "h1" })
+	if heading != nil && strings.HasPrefix(strings.TrimSpace(nodeText(heading)), "Index of ") {
+		return true
+	}
+
+	// Caddy's file browser wraps its table in <div class="listing">
+	listing := findElement(doc, func(n *html.Node) bool {
+		return n.Data == "div" && hasClass(n, "listing")
+	})
+	return listing != nil && findElement(listing, func(n *html.Node) bool { return n.Data == "table" }) != nil
+}
+
+// Helper function to extract entries from a directory listing.
+// Table-based listings (Apache FancyIndexing, lighttpd, Caddy) read metadata from
+// the anchor's row; <pre>-based listings (nginx, Apache) read it from the text
+// following the anchor on the same line.
+func parseDirectoryIndex(doc *html.Node, pageURL *url.URL) []DirEntry {
+	var entries []DirEntry
+	seen := make(map[string]bool)
+
+	baseURL := findBaseURL(doc, pageURL)
+	dirURL := baseURL.ResolveReference(&url.URL{Path: "./"})
+
+	var traverse func(*html.Node)
+	traverse = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "a" && hasAttribute(n, "href") {
+			if entry, ok := dirEntryFromAnchor(n, baseURL, dirURL); ok && !seen[entry.URL] {
+				seen[entry.URL] = true
+				entries = append(entries, entry)
+			}
+			return
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			traverse(c)
+		}
+	}
+
+	traverse(doc)
+	return entries
+}
+
+// Helper function to build a directory entry from an anchor.
+// The second return value is false for parent directory, sort-column and
+// out-of-directory links.
+func dirEntryFromAnchor(a *html.Node, baseURL, dirURL *url.URL) (DirEntry, bool) {
+	href := strings.TrimSpace(getAttribute(a, "href"))
+	if href == "" || strings.HasPrefix(href, "?") || strings.HasPrefix(href, "#") {
+		return DirEntry{}, false
+	}
+	if parentDirectoryNames[strings.ToLower(strings.TrimSpace(nodeText(a)))] {
+		return DirEntry{}, false
+	}
+
+	ref, err := url.Parse(href)
+	if err != nil {
+		return DirEntry{}, false
+	}
+	entryURL := baseURL.ResolveReference(ref)
+	entryURL.RawQuery = ""
+	entryURL.Fragment = ""
+
+	// Only entries inside the listed directory count; this drops parent and site links
+	if entryURL.Scheme != dirURL.Scheme || entryURL.Host != dirURL.Host ||
+		!strings.HasPrefix(entryURL.Path, dirURL.Path) || entryURL.Path == dirURL.Path {
+		return DirEntry{}, false
+	}
+
+	entry := DirEntry{
+		Name:  path.Base(strings.TrimSuffix(entryURL.Path, "/")),
+		URL:   entryURL.String(),
+		Size:  -1,
+		IsDir: strings.HasSuffix(entryURL.Path, "/"),
+	}
+
+	if row := findAncestor(a, "tr"); row != nil {
+		readRowMetadata(&entry, row, a)
+	} else if findAncestor(a, "pre") != nil {
+		readLineMetadata(&entry, trailingLineText(a))
+	}
+
+	return entry, true
+}
+
+// Helper function to read size, date and type from the cells of a table row
+func readRowMetadata(entry *DirEntry, row, anchor *html.Node) {
+	for cell := row.FirstChild; cell != nil; cell = cell.NextSibling {
+		if cell.Type != html.ElementNode || (cell.Data != "td" && cell.Data != "th") || isAncestor(cell, anchor) {
+			continue
+		}
+
+		// Caddy stores the exact size in data-order and the timestamp in <time datetime>
+		if order := getAttribute(cell, "data-order"); order != "" && entry.Size < 0 {
+			if size, err := strconv.ParseInt(order, 10, 64); err == nil && size >= 0 {
+				entry.Size = size
+				continue
+			}
+		}
+		if t := findElement(cell, func(n *html.Node) bool { return n.Data == "time" && hasAttribute(n, "datetime") }); t != nil {
+			if modified, err := time.Parse(time.RFC3339, getAttribute(t, "datetime")); err == nil {
+				entry.Modified = modified.UTC()
+				continue
+			}
+		}
+
+		text := strings.TrimSpace(strings.ReplaceAll(nodeText(cell), "\u00a0", " "))
+		switch {
+		case text == "":
+		case strings.EqualFold(text, "Directory"):
+			entry.IsDir = true
+		case entry.Modified.IsZero() && dirIndexDatePattern.MatchString(text):
+			entry.Modified = parseDirIndexDate(dirIndexDatePattern.FindString(text))
+		case entry.Size < 0 && text != "-":
+			if size, err := ParseSize(text); err == nil {
+				entry.Size = size
+			}
+		}
+	}
+}
+
+// Helper function to read date and size from the text following an anchor in a <pre> listing,
+// for example "   01-May-2023 12:34    1234567".
+func readLineMetadata(entry *DirEntry, line string) {
+	loc := dirIndexDatePattern.FindStringIndex(line)
+	if loc == nil {
+		return
+	}
+	entry.Modified = parseDirIndexDate(line[loc[0]:loc[1]])
+
+	fields := strings.Fields(line[loc[1]:])
+	if len(fields) == 0 || fields[0] == "-" {
+		return
+	}
+	if size, err := ParseSize(fields[0]); err == nil {
+		entry.Size = size
+	}
+}
+
+// Helper function to parse a listing timestamp, returning the zero time if no layout matches
+func parseDirIndexDate(value string) time.Time {
+	for _, layout := range dirIndexDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Helper function to collect the text after a node up to the end of its line
+func trailingLineText(n *html.Node) string {
+	var line strings.Builder
+	for sibling := n.NextSibling; sibling != nil; sibling = sibling.NextSibling {
+		text := nodeText(sibling)
+		if i := strings.IndexByte(text, '\n'); i >= 0 {
+			line.WriteString(text[:i])
+			break
+		}
+		line.WriteString(text)
+	}
+	return line.String()
+}
+
+// nodeText returns the concatenated text content of a node and its descendants.
+func nodeText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+
+	var text strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		text.WriteString(nodeText(c))
+	}
+	return text.String()
+}
+
+// findAncestor returns the nearest ancestor element with the given tag name, or nil.
+func findAncestor(n *html.Node, tag string) *html.Node {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.Type == html.ElementNode && p.Data == tag {
+			return p
+		}
+	}
+	return nil
+}
+
+// isAncestor reports whether ancestor is n or one of n's ancestors.
+func isAncestor(ancestor, n *html.Node) bool {
+	for p := n; p != nil; p = p.Parent {
+		if p == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+// hasClass reports whether the node's class attribute contains the given class.
+func hasClass(n *html.Node, class string) bool {
+	for _, c := range strings.Fields(getAttribute(n, "class")) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
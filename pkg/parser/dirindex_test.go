@@ -0,0 +1,137 @@
+package parser
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+func TestParseDirectoryIndex(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected []DirEntry
+	}{
+		{
+			name: "Apache FancyIndexing table",
+			content: `<html><head><title>Index of /pub</title></head><body>
+<h1>Index of /pub</h1>
+<table>
+<tr><th valign="top"><img src="/icons/blank.gif" alt="[ICO]"></th><th><a href="?C=N;O=D">Name</a></th><th><a href="?C=M;O=A">Last modified</a></th><th><a href="?C=S;O=A">Size</a></th><th><a href="?C=D;O=A">Description</a></th></tr>
+<tr><td valign="top"><img src="/icons/back.gif" alt="[PARENTDIR]"></td><td><a href="/">Parent Directory</a></td><td>&nbsp;</td><td align="right">  - </td><td>&nbsp;</td></tr>
+<tr><td valign="top"><img src="/icons/folder.gif" alt="[DIR]"></td><td><a href="docs/">docs/</a></td><td align="right">2024-03-01 09:15  </td><td align="right">  - </td><td>&nbsp;</td></tr>
+<tr><td valign="top"><img src="/icons/compressed.gif" alt="[   ]"></td><td><a href="app-1.0.tar.gz">app-1.0.tar.gz</a></td><td align="right">2024-03-02 10:30  </td><td align="right">1.5M</td><td>&nbsp;</td></tr>
+</table></body></html>`,
+			expected: []DirEntry{
+				{Name: "docs", URL: "https://example.com/pub/docs/", Size: -1, Modified: time.Date(2024, 3, 1, 9, 15, 0, 0, time.UTC), IsDir: true},
+				{Name: "app-1.0.tar.gz", URL: "https://example.com/pub/app-1.0.tar.gz", Size: 1572864, Modified: time.Date(2024, 3, 2, 10, 30, 0, 0, time.UTC)},
+			},
+		},
+		{
+			name: "nginx autoindex",
+			content: `<html><head><title>Index of /pub/</title></head><body>
+<h1>Index of /pub/</h1><hr><pre><a href="../">../</a>
+<a href="docs/">docs/</a>                                              01-Mar-2024 09:15                   -
+<a href="a%20very%20long%20file%20name%20that%20nginx%20truncates.iso">a very long file name that nginx truncates.iso</a>  02-Mar-2024 10:30            12345678
+</pre><hr></body></html>`,
+			expected: []DirEntry{
+				{Name: "docs", URL: "https://example.com/pub/docs/", Size: -1, Modified: time.Date(2024, 3, 1, 9, 15, 0, 0, time.UTC), IsDir: true},
+				{Name: "a very long file name that nginx truncates.iso", URL: "https://example.com/pub/a%20very%20long%20file%20name%20that%20nginx%20truncates.iso", Size: 12345678, Modified: time.Date(2024, 3, 2, 10, 30, 0, 0, time.UTC)},
+			},
+		},
+		{
+			name: "lighttpd",
+			content: `<html><head><title>Index of /pub/</title></head><body>
+<div class="list"><table summary="Directory Listing">
+<thead><tr><th class="n">Name</th><th class="m">Last Modified</th><th class="s">Size</th><th class="t">Type</th></tr></thead>
+<tbody>
+<tr class="d"><td class="n"><a href="../">Parent Directory</a>/</td><td class="m">&nbsp;</td><td class="s">- &nbsp;</td><td class="t">Directory</td></tr>
+<tr><td class="n"><a href="notes.txt">notes.txt</a></td><td class="m">2024-Mar-02 10:30:05</td><td class="s">4.0K</td><td class="t">text/plain</td></tr>
+</tbody></table></div></body></html>`,
+			expected: []DirEntry{
+				{Name: "notes.txt", URL: "https://example.com/pub/notes.txt", Size: 4096, Modified: time.Date(2024, 3, 2, 10, 30, 5, 0, time.UTC)},
+			},
+		},
+		{
+			name: "Caddy file browser",
+			content: `<html><head><title>/pub/</title></head><body>
+<div class="listing"><table>
+<thead><tr><th></th><th><a href="?sort=name&order=desc">Name</a></th><th><a href="?sort=size&order=asc">Size</a></th><th><a href="?sort=time&order=asc">Modified</a></th></tr></thead>
+<tbody>
+<tr class="file"><td></td><td><a href=".."><span class="go-up">Up</span></a></td><td>&mdash;</td><td>&mdash;</td></tr>
+<tr class="file"><td></td><td><a href="./image.png"><span class="name">image.png</span></a></td><td data-order="2048" class="size">2.0 KiB</td><td class="timestamp"><time datetime="2024-03-02T10:30:00Z">03/02/2024 10:30:00 AM +00:00</time></td></tr>
+</tbody></table></div></body></html>`,
+			expected: []DirEntry{
+				{Name: "image.png", URL: "https://example.com/pub/image.png", Size: 2048, Modified: time.Date(2024, 3, 2, 10, 30, 0, 0, time.UTC)},
+			},
+		},
+	}
+
+	pageURL, _ := url.Parse("https://example.com/pub/")
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := html.Parse(strings.NewReader(tc.content))
+			if err != nil {
+				t.Fatalf("Failed to parse HTML: %v", err)
+			}
+
+			if !isDirectoryIndex(doc) {
+				t.Fatal("Expected page to be recognized as a directory index")
+			}
+
+			entries := parseDirectoryIndex(doc, pageURL)
+			if len(entries) != len(tc.expected) {
+				t.Fatalf("Expected %d entries, got %d: %+v", len(tc.expected), len(entries), entries)
+			}
+
+			for i, entry := range entries {
+				expected := tc.expected[i]
+				if entry.Name != expected.Name || entry.URL != expected.URL || entry.Size != expected.Size ||
+					!entry.Modified.Equal(expected.Modified) || entry.IsDir != expected.IsDir {
+					t.Errorf("Expected %+v at position %d, got %+v", expected, i, entry)
+				}
+			}
+		})
+	}
+}
+
+func TestIsDirectoryIndex(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<html><head><title>Home</title></head><body><a href="/a">A</a></body></html>`))
+	if err != nil {
+		t.Fatalf("Failed to parse HTML: %v", err)
+	}
+
+	if isDirectoryIndex(doc) {
+		t.Error("Expected ordinary page not to be recognized as a directory index")
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]int64{
+		"1234":     1234,
+		"4.0K":     4096,
+		"1.5M":     1572864,
+		"2 KiB":    2048,
+		"1G":       1 << 30,
+		"10 bytes": 10,
+	}
+
+	for input, expected := range tests {
+		size, err := ParseSize(input)
+		if err != nil {
+			t.Errorf("ParseSize(%q) failed: %v", input, err)
+			continue
+		}
+		if size != expected {
+			t.Errorf("ParseSize(%q) = %d, expected %d", input, size, expected)
+		}
+	}
+
+	if _, err := ParseSize("huge"); err == nil {
+		t.Error("Expected error for invalid size, got nil")
+	}
+}
@@ -0,0 +1,459 @@
+package parser
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/net/html"
+
+	"github.com/hemzaz/lsweb/pkg/common"
+)
+
+// documentExtensions maps the extensions of documents lsweb reads links from to their format
+var documentExtensions = map[string]string{
+	".pdf":  "pdf",
+	".docx": "docx",
+	".xlsx": "xlsx",
+	".pptx": "pptx",
+	".epub": "epub",
+}
+
+// ooxmlRelationshipsNamespace is the namespace of r:id attributes that refer to relationships
+const ooxmlRelationshipsNamespace = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+
+// ooxmlRelationships is a .rels part of an Office Open XML package
+type ooxmlRelationships struct {
+	Relationships []struct {
+		ID         string `xml:"Id,attr"`
+		Type       string `xml:"Type,attr"`
+		Target     string `xml:"Target,attr"`
+		TargetMode string `xml:"TargetMode,attr"`
+	} `xml:"Relationship"`
+}
+
+// Helper function to identify a PDF, Office Open XML or EPUB document by its content.
+// Returns "pdf", "docx", "xlsx", "pptx", "epub", or "" for other content.
+func documentFormat(body []byte) string {
+	if bytes.HasPrefix(body, []byte("%PDF-")) {
+		return "pdf"
+	}
+	if !bytes.HasPrefix(body, []byte("PK\x03\x04")) {
+		return ""
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return ""
+	}
+
+	format := ""
+	for _, file := range archive.File {
+		switch {
+		case file.Name == "mimetype":
+			if content, err := readZipFile(file, 64); err == nil &&
+				strings.TrimSpace(string(content)) == "application/epub+zip" {
+				return "epub"
+			}
+		case strings.HasPrefix(file.Name, "word/"):
+			format = "docx"
+		case strings.HasPrefix(file.Name, "xl/"):
+			format = "xlsx"
+		case strings.HasPrefix(file.Name, "ppt/"):
+			format = "pptx"
+		}
+	}
+	return format
+}
+
+// Helper function to report whether a Content-Type header value denotes a PDF, Office Open XML or EPUB document
+func isDocumentContentType(contentType string) bool {
+	return strings.Contains(contentType, "application/pdf") ||
+		strings.Contains(contentType, "application/vnd.openxmlformats-officedocument.") ||
+		strings.Contains(contentType, "application/epub+zip")
+}
+
+// Helper function to report whether a response typed as a generic binary or zip file
+// is named like a document, as servers often send Office files as application/octet-stream
+func isDocumentResponse(contentType, urlPath string) bool {
+	if !strings.Contains(contentType, "application/octet-stream") &&
+		!strings.Contains(contentType, "application/zip") {
+		return false
+	}
+	_, ok := documentExtensions[strings.ToLower(path.Ext(urlPath))]
+	return ok
+}
+
+// Helper function to extract links from a document in the given format.
+// PDFs contribute their URI link annotations, Office files their external relationship
+// targets such as hyperlinks, and EPUBs the absolute links in their XHTML content.
+func collectDocumentLinks(body []byte, format string, baseURL *url.URL) ([]common.Link, error) {
+	if format == "pdf" {
+		return collectPDFLinks(body, baseURL)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s document: %w", format, err)
+	}
+	if format == "epub" {
+		return collectEPUBLinks(archive)
+	}
+	return collectOOXMLLinks(archive, format, baseURL)
+}
+
+// Helper function to read a file from a zip archive, failing if it is larger than limit bytes
+func readZipFile(file *zip.File, limit int64) ([]byte, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %w", file.Name, err)
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", file.Name, err)
+	}
+	if int64(len(content)) > limit {
+		return nil, fmt.Errorf("%s exceeds the decompressed size limit of %d bytes", file.Name, limit)
+	}
+	return content, nil
+}
+
+// Helper function to extract the external relationship targets of an Office Open XML package.
+// Hyperlinks take their text from the part that refers to them when it can be found.
+func collectOOXMLLinks(archive *zip.Reader, format string, baseURL *url.URL) ([]common.Link, error) {
+	var links []common.Link
+	visited := make(map[string]bool)
+	remaining := maxDecompressedSize
+
+	files := make(map[string]*zip.File)
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	for _, file := range archive.File {
+		dir, name := path.Split(file.Name)
+		if path.Base(dir) != "_rels" || !strings.HasSuffix(name, ".rels") {
+			continue
+		}
+
+		content, err := readZipFile(file, remaining)
+		if err != nil {
+			return nil, err
+		}
+		remaining -= int64(len(content))
+
+		var rels ooxmlRelationships
+		if err := xml.Unmarshal(content, &rels); err != nil {
+			continue
+		}
+
+		// The part these relationships belong to, such as word/document.xml
+		var texts map[string]string
+		if part := files[path.Join(path.Dir(path.Clean(dir)), strings.TrimSuffix(name, ".rels"))]; part != nil {
+			if partContent, err := readZipFile(part, remaining); err == nil {
+				remaining -= int64(len(partContent))
+				texts = ooxmlHyperlinkTexts(partContent)
+			}
+		}
+
+		for _, rel := range rels.Relationships {
+			if !strings.EqualFold(rel.TargetMode, "External") {
+				continue
+			}
+			urlStr, err := resolveLink(rel.Target, baseURL)
+			if err != nil || urlStr == "" || visited[urlStr] {
+				continue
+			}
+			visited[urlStr] = true
+			links = append(links, common.Link{
+				URL:       urlStr,
+				Text:      strings.Join(strings.Fields(texts[rel.ID]), " "),
+				Element:   format,
+				Attribute: path.Base(rel.Type),
+			})
+		}
+	}
+
+	return links, nil
+}
+
+// Helper function to map relationship IDs to the text of the hyperlinks that use them:
+// the runs inside <w:hyperlink> in Word, the run holding <a:hlinkClick> in PowerPoint,
+// and the display attribute of <hyperlink> in Excel worksheets.
+func ooxmlHyperlinkTexts(content []byte) map[string]string {
+	texts := make(map[string]string)
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	decoder.Strict = false
+
+	relationshipID := func(start xml.StartElement) string {
+		for _, attr := range start.Attr {
+			if attr.Name.Local == "id" && attr.Name.Space == ooxmlRelationshipsNamespace {
+				return attr.Value
+			}
+		}
+		return ""
+	}
+
+	hyperlinkID := ""
+	runID := ""
+	inText := false
+	var hyperlinkText, runText strings.Builder
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return texts
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "hyperlink":
+				id := relationshipID(t)
+				for _, attr := range t.Attr {
+					if attr.Name.Local == "display" && id != "" {
+						texts[id] = attr.Value
+						id = ""
+					}
+				}
+				hyperlinkID = id
+				hyperlinkText.Reset()
+			case "hlinkClick":
+				runID = relationshipID(t)
+			case "r":
+				runID = ""
+				runText.Reset()
+			case "t":
+				inText = true
+			}
+		case xml.CharData:
+			if inText {
+				hyperlinkText.Write(t)
+				runText.Write(t)
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "hyperlink":
+				if hyperlinkID != "" {
+					texts[hyperlinkID] += hyperlinkText.String()
+				}
+				hyperlinkID = ""
+			case "r":
+				if runID != "" {
+					texts[runID] += runText.String()
+				}
+				runID = ""
+			}
+		}
+	}
+}
+
+// Helper function to extract the absolute links from the XHTML content documents of an EPUB.
+// Links between the book's own documents are skipped.
+func collectEPUBLinks(archive *zip.Reader) ([]common.Link, error) {
+	var links []common.Link
+	visited := make(map[string]bool)
+	remaining := maxDecompressedSize
+
+	for _, file := range archive.File {
+		switch strings.ToLower(path.Ext(file.Name)) {
+		case ".xhtml", ".html", ".htm":
+		default:
+			continue
+		}
+
+		content, err := readZipFile(file, remaining)
+		if err != nil {
+			return nil, err
+		}
+		remaining -= int64(len(content))
+
+		doc, err := html.Parse(bytes.NewReader(content))
+		if err != nil {
+			continue
+		}
+
+		// Resolve against the document's place in the book to tell internal links apart
+		documentURL := &url.URL{Scheme: "epub", Path: "/" + file.Name}
+		documentLinks, _ := collectHTMLLinks(doc, documentURL)
+		for _, link := range documentLinks {
+			if strings.HasPrefix(link.URL, "epub:") || visited[link.URL] {
+				continue
+			}
+			visited[link.URL] = true
+			links = append(links, link)
+		}
+	}
+
+	return links, nil
+}
+
+// pdfURIPattern matches the /URI key of a URI action and the start of its string value
+var pdfURIPattern = regexp.MustCompile(`/URI\s*[(<]`)
+
+// pdfStreamPattern matches the keyword that starts a stream's data
+var pdfStreamPattern = regexp.MustCompile(`stream\r?\n`)
+
+// Helper function to extract the targets of URI actions, used by link annotations, from a PDF.
+// Objects in Flate-compressed object streams are searched too, up to the decompressed size limit.
+// Encrypted PDFs are not supported.
+func collectPDFLinks(body []byte, baseURL *url.URL) ([]common.Link, error) {
+	var links []common.Link
+	visited := make(map[string]bool)
+
+	addURIs := func(data []byte) {
+		for _, match := range pdfURIPattern.FindAllIndex(data, -1) {
+			uri, ok := parsePDFString(data[match[1]-1:])
+			if !ok {
+				continue
+			}
+			urlStr, err := resolveLink(uri, baseURL)
+			if err != nil || urlStr == "" || visited[urlStr] {
+				continue
+			}
+			visited[urlStr] = true
+			links = append(links, common.Link{URL: urlStr, Element: "pdf", Attribute: "URI"})
+		}
+	}
+
+	addURIs(body)
+
+	remaining := maxDecompressedSize
+	for _, match := range pdfStreamPattern.FindAllIndex(body, -1) {
+		if remaining <= 0 {
+			break
+		}
+		start := match[1]
+		end := bytes.Index(body[start:], []byte("endstream"))
+		if end < 0 {
+			continue
+		}
+
+		// Streams that are not Flate-compressed fail to inflate and are skipped
+		reader, err := zlib.NewReader(bytes.NewReader(body[start : start+end]))
+		if err != nil {
+			continue
+		}
+		data, _ := io.ReadAll(io.LimitReader(reader, remaining))
+		reader.Close()
+		remaining -= int64(len(data))
+
+		addURIs(data)
+	}
+
+	return links, nil
+}
+
+// Helper function to parse the PDF string object at the start of data, either a literal
+// string in parentheses or a hex string in angle brackets. UTF-16BE strings with a byte
+// order mark are decoded. Returns false if data does not start with a complete string.
+func parsePDFString(data []byte) (string, bool) {
+	if len(data) == 0 {
+		return "", false
+	}
+
+	var raw []byte
+	switch data[0] {
+	case '(':
+		depth := 0
+		complete := false
+	literal:
+		for i := 0; i < len(data); i++ {
+			c := data[i]
+			switch c {
+			case '(':
+				depth++
+				if depth == 1 {
+					continue
+				}
+			case ')':
+				depth--
+				if depth == 0 {
+					complete = true
+					break literal
+				}
+			case '\\':
+				i++
+				if i >= len(data) {
+					break literal
+				}
+				switch e := data[i]; e {
+				case 'n':
+					c = '\n'
+				case 'r':
+					c = '\r'
+				case 't':
+					c = '\t'
+				case 'b':
+					c = '\b'
+				case 'f':
+					c = '\f'
+				case '\r', '\n':
+					// Line continuation
+					if e == '\r' && i+1 < len(data) && data[i+1] == '\n' {
+						i++
+					}
+					continue
+				default:
+					if e >= '0' && e <= '7' {
+						value := 0
+						for n := 0; n < 3 && i < len(data) && data[i] >= '0' && data[i] <= '7'; n++ {
+							value = value*8 + int(data[i]-'0')
+							i++
+						}
+						i--
+						c = byte(value)
+					} else {
+						c = e
+					}
+				}
+			}
+			raw = append(raw, c)
+		}
+		if !complete {
+			return "", false
+		}
+
+	case '<':
+		end := bytes.IndexByte(data, '>')
+		if end < 0 {
+			return "", false
+		}
+		// Whitespace is ignored and a missing final digit is taken to be 0
+		digits := strings.Join(strings.Fields(string(data[1:end])), "")
+		if len(digits)%2 == 1 {
+			digits += "0"
+		}
+		decoded, err := hex.DecodeString(digits)
+		if err != nil {
+			return "", false
+		}
+		raw = decoded
+
+	default:
+		return "", false
+	}
+
+	// UTF-16BE text strings start with a byte order mark
+	if len(raw) >= 2 && raw[0] == 0xfe && raw[1] == 0xff {
+		units := make([]uint16, 0, len(raw)/2)
+		for i := 2; i+1 < len(raw); i += 2 {
+			units = append(units, uint16(raw[i])<<8|uint16(raw[i+1]))
+		}
+		return string(utf16.Decode(units)), true
+	}
+	return string(raw), true
+}
@@ -0,0 +1,289 @@
+package parser
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hemzaz/lsweb/pkg/common"
+)
+
+// zipForTest builds a zip archive from name and content pairs, in order
+func zipForTest(t *testing.T, files ...string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for i := 0; i+1 < len(files); i += 2 {
+		file, err := writer.Create(files[i])
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if _, err := file.Write([]byte(files[i+1])); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// pdfForTest builds a minimal PDF with one plain link annotation and two more
+// inside a Flate-compressed object stream
+func pdfForTest(t *testing.T) []byte {
+	t.Helper()
+
+	var objects bytes.Buffer
+	writer := zlib.NewWriter(&objects)
+	writer.Write([]byte(`<< /Type /Annot /Subtype /Link /A << /S /URI /URI <68747470733A2F2F6578616D706C652E636F6D2F686578> >> >>
+<< /Type /Annot /Subtype /Link /A << /S /URI /URI (https://example.com/a\(b\)\137c) >> >>`))
+	writer.Close()
+
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.7\n")
+	pdf.WriteString("1 0 obj\n<< /Type /Annot /Subtype /Link /Rect [0 0 10 10] /A << /S /URI /URI (https://example.com/files/setup.exe) >> >>\nendobj\n")
+	pdf.WriteString("2 0 obj\n<< /Type /Annot /A << /S /URI /URI (docs/guide.html) >> >>\nendobj\n")
+	pdf.WriteString("3 0 obj\n<< /Type /ObjStm /Filter /FlateDecode >>\nstream\n")
+	pdf.Write(objects.Bytes())
+	pdf.WriteString("\nendstream\nendobj\n")
+	pdf.WriteString("4 0 obj\n<< /Length 5 >>\nstream\nBT ET\nendstream\nendobj\n")
+	pdf.WriteString("%%EOF\n")
+	return pdf.Bytes()
+}
+
+func TestDocumentFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     []byte
+		expected string
+	}{
+		{"PDF", []byte("%PDF-1.4\n"), "pdf"},
+		{"Word", zipForTest(t, "[Content_Types].xml", "<Types/>", "word/document.xml", "<document/>"), "docx"},
+		{"Excel", zipForTest(t, "[Content_Types].xml", "<Types/>", "xl/workbook.xml", "<workbook/>"), "xlsx"},
+		{"PowerPoint", zipForTest(t, "[Content_Types].xml", "<Types/>", "ppt/presentation.xml", "<presentation/>"), "pptx"},
+		{"EPUB", zipForTest(t, "mimetype", "application/epub+zip", "OEBPS/content.opf", "<package/>"), "epub"},
+		{"Other zip", zipForTest(t, "readme.txt", "hello"), ""},
+		{"HTML", []byte("<html></html>"), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if format := documentFormat(tt.body); format != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, format)
+			}
+		})
+	}
+}
+
+func TestParsePDFString(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected string
+		ok       bool
+	}{
+		{"Literal", "(https://example.com/) >>", "https://example.com/", true},
+		{"Nested parentheses", "(https://example.com/a(b)) >>", "https://example.com/a(b)", true},
+		{"Escapes", `(https://example.com/\(x\)\137\\) >>`, `https://example.com/(x)_\`, true},
+		{"Line continuation", "(https://example.com/long\\\n/path)", "https://example.com/long/path", true},
+		{"Hex", "<68 74 74 70 3A2F2F782E6F72672F>", "http://x.org/", true},
+		{"Hex with odd digits", "<41424>", "AB@", true},
+		{"UTF-16", "<FEFF0068007400740070003A002F002F0078002E006F00720067002F00E9>", "http://x.org/é", true},
+		{"Unterminated", "(https://example.com/", "", false},
+		{"Not a string", "/Name", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, ok := parsePDFString([]byte(tt.data))
+			if ok != tt.ok || result != tt.expected {
+				t.Errorf("Expected %q (%v), got %q (%v)", tt.expected, tt.ok, result, ok)
+			}
+		})
+	}
+}
+
+func TestCollectPDFLinks(t *testing.T) {
+	baseURL, _ := url.Parse("https://vendor.example.com/downloads/list.pdf")
+
+	links, err := collectDocumentLinks(pdfForTest(t), "pdf", baseURL)
+	if err != nil {
+		t.Fatalf("collectDocumentLinks failed: %v", err)
+	}
+
+	expected := []string{
+		"https://example.com/files/setup.exe",
+		"https://vendor.example.com/downloads/docs/guide.html",
+		"https://example.com/hex",
+		"https://example.com/a(b)_c",
+	}
+	if len(links) != len(expected) {
+		t.Fatalf("Expected %d links, got %d: %+v", len(expected), len(links), links)
+	}
+	for i, link := range links {
+		if link.URL != expected[i] || link.Element != "pdf" || link.Attribute != "URI" {
+			t.Errorf("Expected %s at position %d, got %+v", expected[i], i, link)
+		}
+	}
+}
+
+func TestCollectOOXMLLinks(t *testing.T) {
+	rels := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+  <Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+  <Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink" Target="https://example.com/release.zip" TargetMode="External"/>
+  <Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/image" Target="https://cdn.example.com/logo.png" TargetMode="External"/>
+</Relationships>`
+
+	tests := []struct {
+		name     string
+		archive  []byte
+		format   string
+		expected []common.Link
+	}{
+		{
+			name: "Word",
+			archive: zipForTest(t,
+				"[Content_Types].xml", "<Types/>",
+				"word/document.xml", `<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<w:body><w:p><w:hyperlink r:id="rId2"><w:r><w:t>Download</w:t></w:r><w:r><w:t xml:space="preserve"> release</w:t></w:r></w:hyperlink></w:p></w:body></w:document>`,
+				"word/_rels/document.xml.rels", rels),
+			format: "docx",
+			expected: []common.Link{
+				{URL: "https://example.com/release.zip", Text: "Download release", Element: "docx", Attribute: "hyperlink"},
+				{URL: "https://cdn.example.com/logo.png", Element: "docx", Attribute: "image"},
+			},
+		},
+		{
+			name: "PowerPoint",
+			archive: zipForTest(t,
+				"ppt/slides/slide1.xml", `<p:sld xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main" xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<a:p><a:r><a:rPr><a:hlinkClick r:id="rId2"/></a:rPr><a:t>Get it</a:t></a:r><a:r><a:t>plain</a:t></a:r></a:p></p:sld>`,
+				"ppt/slides/_rels/slide1.xml.rels", rels),
+			format: "pptx",
+			expected: []common.Link{
+				{URL: "https://example.com/release.zip", Text: "Get it", Element: "pptx", Attribute: "hyperlink"},
+				{URL: "https://cdn.example.com/logo.png", Element: "pptx", Attribute: "image"},
+			},
+		},
+		{
+			name: "Excel",
+			archive: zipForTest(t,
+				"xl/worksheets/sheet1.xml", `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<hyperlinks><hyperlink ref="A2" r:id="rId2" display="Latest build"/></hyperlinks></worksheet>`,
+				"xl/worksheets/_rels/sheet1.xml.rels", rels),
+			format: "xlsx",
+			expected: []common.Link{
+				{URL: "https://example.com/release.zip", Text: "Latest build", Element: "xlsx", Attribute: "hyperlink"},
+				{URL: "https://cdn.example.com/logo.png", Element: "xlsx", Attribute: "image"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			links, err := collectDocumentLinks(tt.archive, tt.format, nil)
+			if err != nil {
+				t.Fatalf("collectDocumentLinks failed: %v", err)
+			}
+			if len(links) != len(tt.expected) {
+				t.Fatalf("Expected %d links, got %d: %+v", len(tt.expected), len(links), links)
+			}
+			for i, link := range links {
+				if link != tt.expected[i] {
+					t.Errorf("Expected %+v at position %d, got %+v", tt.expected[i], i, link)
+				}
+			}
+		})
+	}
+}
+
+func TestCollectEPUBLinks(t *testing.T) {
+	archive := zipForTest(t,
+		"mimetype", "application/epub+zip",
+		"OEBPS/chapter1.xhtml", `<html xmlns="http://www.w3.org/1999/xhtml"><body>
+<p><a href="chapter2.xhtml">Next</a> <a href="https://example.com/errata">Errata</a></p>
+<p><a href="#note1">1</a> <a href="https://example.com/errata">again</a></p></body></html>`,
+		"OEBPS/chapter2.xhtml", `<html xmlns="http://www.w3.org/1999/xhtml"><body>
+<a href="../images/cover.jpg">Cover</a> <a href="http://example.org/source.tar.gz">Source</a></body></html>`)
+
+	links, err := collectDocumentLinks(archive, "epub", nil)
+	if err != nil {
+		t.Fatalf("collectDocumentLinks failed: %v", err)
+	}
+
+	expected := []string{"https://example.com/errata", "http://example.org/source.tar.gz"}
+	urls := common.URLs(links)
+	if len(urls) != len(expected) || urls[0] != expected[0] || urls[1] != expected[1] {
+		t.Errorf("Expected %v, got %v", expected, urls)
+	}
+	if len(links) > 0 && links[0].Text != "Errata" {
+		t.Errorf("Expected text %q, got %q", "Errata", links[0].Text)
+	}
+}
+
+func TestReadLinksDocuments(t *testing.T) {
+	dir := t.TempDir()
+	pdfPath := filepath.Join(dir, "downloads.pdf")
+	if err := os.WriteFile(pdfPath, pdfForTest(t), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	links, err := ExtractLinksFromFile(pdfPath)
+	if err != nil {
+		t.Fatalf("ExtractLinksFromFile failed: %v", err)
+	}
+	if len(links) != 4 || links[0] != "https://example.com/files/setup.exe" {
+		t.Errorf("Unexpected links: %v", links)
+	}
+}
+
+func TestExtractLinksFromURLDocuments(t *testing.T) {
+	docx := zipForTest(t,
+		"[Content_Types].xml", "<Types/>",
+		"word/_rels/document.xml.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId4" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink" Target="https://example.com/driver.msi" TargetMode="External"/></Relationships>`)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/list.pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+			w.Write(pdfForTest(t))
+		case "/list.docx":
+			// Servers often send Office files as generic binaries
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write(docx)
+		case "/blob":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write(docx)
+		}
+	}))
+	defer server.Close()
+
+	links, err := ExtractLinksFromURL(server.URL+"/list.pdf", false)
+	if err != nil {
+		t.Fatalf("ExtractLinksFromURL failed: %v", err)
+	}
+	if len(links) != 4 || links[1] != server.URL+"/docs/guide.html" {
+		t.Errorf("Unexpected PDF links: %v", links)
+	}
+
+	links, err = ExtractLinksFromURL(server.URL+"/list.docx", false)
+	if err != nil {
+		t.Fatalf("ExtractLinksFromURL failed: %v", err)
+	}
+	if len(links) != 1 || links[0] != "https://example.com/driver.msi" {
+		t.Errorf("Unexpected DOCX links: %v", links)
+	}
+
+	if _, err := ExtractLinksFromURL(server.URL+"/blob", false); err == nil {
+		t.Error("Expected an error for an unnamed binary response")
+	}
+}
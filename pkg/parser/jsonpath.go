@@ -0,0 +1,549 @@
+package parser
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hemzaz/lsweb/pkg/common"
+)
+
+// jsonPathSelector selects which values of a JSON document are links; nil scans every string
+var jsonPathSelector *jsonPath
+
+// SetJSONPath sets a JSONPath expression, such as $.items[*].download_url, that selects
+// the values of JSON documents to treat as links. Relative values resolve against the
+// document's URL. jq-style paths like .items[].download_url are accepted too.
+// An empty expression restores the default of scanning every string for URLs.
+func SetJSONPath(expr string) error {
+	if strings.TrimSpace(expr) == "" {
+		jsonPathSelector = nil
+		return nil
+	}
+
+	path, err := compileJSONPath(expr)
+	if err != nil {
+		return err
+	}
+	jsonPathSelector = path
+	return nil
+}
+
+// jsonPath is a compiled JSONPath expression
+type jsonPath struct {
+	segments []jsonPathSegment
+}
+
+// jsonPathSegment is one step of a JSONPath: a selector applied to the children
+// of the current values, or to all their descendants if recursive
+type jsonPathSegment struct {
+	recursive bool
+	wildcard  bool
+	names     []string
+	indices   []int
+	slice     *jsonPathSlice
+	filter    *jsonPathFilter
+}
+
+// jsonPathSlice is an array slice selector, [start:end:step]
+type jsonPathSlice struct {
+	start, end *int
+	step       int
+}
+
+// jsonPathFilter is a filter selector, [?(@.key op value)] or [?(@.key)]
+type jsonPathFilter struct {
+	path     *jsonPath
+	operator string
+	value    interface{}
+	pattern  *regexp.Regexp
+}
+
+// jsonPathNode is a selected value with the object key or parent key it was found under
+type jsonPathNode struct {
+	value interface{}
+	key   string
+}
+
+// jsonPathFilterPattern matches the body of a filter: a relative path, and an optional comparison
+var jsonPathFilterPattern = regexp.MustCompile(`^@((?:\.[^.\s=!<>~\[]+|\[[^\]]*\])*)\s*(?:(==|!=|=~|<=|>=|<|>)\s*(.+))?$`)
+
+// Helper function to compile a JSONPath expression. Supported are member names (.name or
+// ['name']), wildcards (.* or [*]), indices and unions ([0], [-1], [0,2], ['a','b']), slices
+// ([1:3]), recursive descent (..name) and filters comparing a member with a string, number,
+// boolean or null, or matching it against a quoted regular expression with =~.
+func compileJSONPath(expr string) (*jsonPath, error) {
+	expr = strings.TrimSpace(expr)
+	switch {
+	case strings.HasPrefix(expr, "$"):
+		expr = expr[1:]
+	case strings.HasPrefix(expr, ".") || strings.HasPrefix(expr, "["):
+		// jq-style paths start at the root implicitly
+	default:
+		expr = "." + expr
+	}
+
+	path := &jsonPath{}
+	for i := 0; i < len(expr); {
+		var segment jsonPathSegment
+
+		switch {
+		case strings.HasPrefix(expr[i:], ".."):
+			segment.recursive = true
+			i += 2
+		case expr[i] == '.':
+			i++
+		case expr[i] != '[':
+			return nil, fmt.Errorf("invalid JSONPath %q: unexpected %q at offset %d", expr, expr[i], i)
+		}
+
+		if i < len(expr) && expr[i] == '[' {
+			end := closingJSONPathBracket(expr, i)
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSONPath %q: unclosed bracket", expr)
+			}
+			if err := segment.parseBracket(expr[i+1 : end]); err != nil {
+				return nil, fmt.Errorf("invalid JSONPath %q: %w", expr, err)
+			}
+			i = end + 1
+		} else {
+			end := i
+			for end < len(expr) && expr[end] != '.' && expr[end] != '[' {
+				end++
+			}
+			name := expr[i:end]
+			switch name {
+			case "":
+				// A trailing dot, as in jq's identity ".", selects the current value
+				if end < len(expr) || segment.recursive {
+					return nil, fmt.Errorf("invalid JSONPath %q: missing member name at offset %d", expr, i)
+				}
+				return path, nil
+			case "*":
+				segment.wildcard = true
+			default:
+				segment.names = []string{name}
+			}
+			i = end
+		}
+
+		path.segments = append(path.segments, segment)
+	}
+
+	return path, nil
+}
+
+// Helper function to find the bracket closing the one at start, skipping quoted strings
+func closingJSONPathBracket(expr string, start int) int {
+	depth := 0
+	var quote byte
+	for i := start; i < len(expr); i++ {
+		c := expr[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Helper function to parse the contents of a bracket selector into the segment
+func (s *jsonPathSegment) parseBracket(content string) error {
+	content = strings.TrimSpace(content)
+
+	switch {
+	case content == "" || content == "*":
+		// jq writes [] for every element
+		s.wildcard = true
+		return nil
+	case strings.HasPrefix(content, "?"):
+		filter, err := parseJSONPathFilter(content[1:])
+		if err != nil {
+			return err
+		}
+		s.filter = filter
+		return nil
+	}
+
+	for _, item := range splitJSONPathUnion(content) {
+		item = strings.TrimSpace(item)
+		switch {
+		case strings.HasPrefix(item, "'") || strings.HasPrefix(item, "\""):
+			name, err := unquoteJSONPathString(item)
+			if err != nil {
+				return err
+			}
+			s.names = append(s.names, name)
+		case strings.Contains(item, ":"):
+			slice, err := parseJSONPathSlice(item)
+			if err != nil {
+				return err
+			}
+			s.slice = slice
+		default:
+			index, err := strconv.Atoi(item)
+			if err != nil {
+				return fmt.Errorf("invalid index %q", item)
+			}
+			s.indices = append(s.indices, index)
+		}
+	}
+	return nil
+}
+
+// Helper function to split a union selector on commas outside quoted strings
+func splitJSONPathUnion(content string) []string {
+	var items []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ',':
+			items = append(items, content[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, content[start:])
+}
+
+// Helper function to decode a single- or double-quoted JSONPath string
+func unquoteJSONPathString(item string) (string, error) {
+	if len(item) < 2 || item[len(item)-1] != item[0] {
+		return "", fmt.Errorf("unterminated string %s", item)
+	}
+	if item[0] == '\'' {
+		return strings.NewReplacer(`\'`, `'`, `\\`, `\`).Replace(item[1 : len(item)-1]), nil
+	}
+	unquoted, err := strconv.Unquote(item)
+	if err != nil {
+		return "", fmt.Errorf("invalid string %s", item)
+	}
+	return unquoted, nil
+}
+
+// Helper function to parse an array slice such as 1:3, :2, -2: or ::2
+func parseJSONPathSlice(item string) (*jsonPathSlice, error) {
+	parts := strings.Split(item, ":")
+	if len(parts) > 3 {
+		return nil, fmt.Errorf("invalid slice %q", item)
+	}
+
+	slice := &jsonPathSlice{step: 1}
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		value, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid slice %q", item)
+		}
+		switch i {
+		case 0:
+			slice.start = &value
+		case 1:
+			slice.end = &value
+		case 2:
+			if value <= 0 {
+				return nil, fmt.Errorf("invalid slice step in %q", item)
+			}
+			slice.step = value
+		}
+	}
+	return slice, nil
+}
+
+// Helper function to parse a filter expression such as (@.type == 'file')
+func parseJSONPathFilter(expr string) (*jsonPathFilter, error) {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "(") && strings.HasSuffix(expr, ")") {
+		expr = strings.TrimSpace(expr[1 : len(expr)-1])
+	}
+
+	match := jsonPathFilterPattern.FindStringSubmatch(expr)
+	if match == nil {
+		return nil, fmt.Errorf("unsupported filter %q", expr)
+	}
+
+	path, err := compileJSONPath("$" + match[1])
+	if err != nil {
+		return nil, err
+	}
+	filter := &jsonPathFilter{path: path, operator: match[2]}
+	if filter.operator == "" {
+		return filter, nil
+	}
+
+	literal := strings.TrimSpace(match[3])
+	switch {
+	case strings.HasPrefix(literal, "'") || strings.HasPrefix(literal, "\""):
+		value, err := unquoteJSONPathString(literal)
+		if err != nil {
+			return nil, err
+		}
+		filter.value = value
+	case literal == "true" || literal == "false":
+		filter.value = literal == "true"
+	case literal == "null":
+		filter.value = nil
+	default:
+		number, err := strconv.ParseFloat(literal, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q in filter", literal)
+		}
+		filter.value = number
+	}
+
+	if filter.operator == "=~" {
+		pattern, ok := filter.value.(string)
+		if !ok {
+			return nil, fmt.Errorf("=~ needs a quoted regular expression in filter %q", expr)
+		}
+		filter.pattern, err = regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression in filter: %w", err)
+		}
+	}
+	return filter, nil
+}
+
+// Helper function to report whether a value passes the filter
+func (f *jsonPathFilter) matches(value interface{}) bool {
+	selected := f.path.evaluate(value)
+	if len(selected) == 0 {
+		return false
+	}
+	actual := selected[0].value
+	if f.operator == "" {
+		return true
+	}
+
+	switch f.operator {
+	case "==":
+		return actual == f.value
+	case "!=":
+		return actual != f.value
+	case "=~":
+		text, ok := actual.(string)
+		return ok && f.pattern.MatchString(text)
+	}
+
+	// Ordering comparisons need two numbers or two strings
+	var cmp int
+	switch a := actual.(type) {
+	case float64:
+		b, ok := f.value.(float64)
+		if !ok {
+			return false
+		}
+		switch {
+		case a < b:
+			cmp = -1
+		case a > b:
+			cmp = 1
+		}
+	case string:
+		b, ok := f.value.(string)
+		if !ok {
+			return false
+		}
+		cmp = strings.Compare(a, b)
+	default:
+		return false
+	}
+
+	switch f.operator {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+// Helper function to apply a JSONPath to a decoded JSON document, returning the
+// selected values in document order; object members are visited in key order
+func (p *jsonPath) evaluate(data interface{}) []jsonPathNode {
+	nodes := []jsonPathNode{{value: data}}
+	for _, segment := range p.segments {
+		var next []jsonPathNode
+		for _, node := range nodes {
+			if segment.recursive {
+				for _, descendant := range jsonPathDescendants(node) {
+					next = append(next, segment.apply(descendant)...)
+				}
+			} else {
+				next = append(next, segment.apply(node)...)
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+// Helper function to list a node and all the values nested in it, depth first
+func jsonPathDescendants(node jsonPathNode) []jsonPathNode {
+	nodes := []jsonPathNode{node}
+	for _, child := range jsonPathChildren(node) {
+		nodes = append(nodes, jsonPathDescendants(child)...)
+	}
+	return nodes
+}
+
+// Helper function to list the members of an object, in key order, or the elements of an array.
+// Array elements carry the key their array was found under.
+func jsonPathChildren(node jsonPathNode) []jsonPathNode {
+	var children []jsonPathNode
+	switch value := node.value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			children = append(children, jsonPathNode{value: value[key], key: key})
+		}
+	case []interface{}:
+		for _, item := range value {
+			children = append(children, jsonPathNode{value: item, key: node.key})
+		}
+	}
+	return children
+}
+
+// Helper function to apply a segment's selector to one value
+func (s *jsonPathSegment) apply(node jsonPathNode) []jsonPathNode {
+	if s.wildcard {
+		return jsonPathChildren(node)
+	}
+	if s.filter != nil {
+		var selected []jsonPathNode
+		for _, child := range jsonPathChildren(node) {
+			if s.filter.matches(child.value) {
+				selected = append(selected, child)
+			}
+		}
+		return selected
+	}
+
+	var selected []jsonPathNode
+	switch value := node.value.(type) {
+	case map[string]interface{}:
+		for _, name := range s.names {
+			if member, ok := value[name]; ok {
+				selected = append(selected, jsonPathNode{value: member, key: name})
+			}
+		}
+	case []interface{}:
+		for _, index := range s.indices {
+			if index < 0 {
+				index += len(value)
+			}
+			if index >= 0 && index < len(value) {
+				selected = append(selected, jsonPathNode{value: value[index], key: node.key})
+			}
+		}
+		if s.slice != nil {
+			start, end := 0, len(value)
+			if s.slice.start != nil {
+				start = clampJSONPathIndex(*s.slice.start, len(value))
+			}
+			if s.slice.end != nil {
+				end = clampJSONPathIndex(*s.slice.end, len(value))
+			}
+			for i := start; i < end; i += s.slice.step {
+				selected = append(selected, jsonPathNode{value: value[i], key: node.key})
+			}
+		}
+	}
+	return selected
+}
+
+// Helper function to turn a possibly negative slice bound into an index within [0, length]
+func clampJSONPathIndex(index, length int) int {
+	if index < 0 {
+		index += length
+	}
+	if index < 0 {
+		return 0
+	}
+	if index > length {
+		return length
+	}
+	return index
+}
+
+// Helper function to extract the links a JSONPath selects from a JSON document.
+// Selected strings, and strings directly inside selected arrays, are resolved against
+// baseURL; other values are ignored. Each link's attribute is the key it was found under.
+func collectJSONPathLinks(data interface{}, path *jsonPath, baseURL *url.URL) []common.Link {
+	var links []common.Link
+	visited := make(map[string]bool)
+
+	add := func(raw, key string) {
+		raw = strings.TrimSpace(raw)
+		if raw == "" || strings.HasPrefix(raw, "#") {
+			return
+		}
+		urlStr, err := resolveLink(raw, baseURL)
+		if err != nil || urlStr == "" || visited[urlStr] {
+			return
+		}
+		visited[urlStr] = true
+		links = append(links, common.Link{URL: urlStr, Element: "json", Attribute: key})
+	}
+
+	for _, node := range path.evaluate(data) {
+		switch value := node.value.(type) {
+		case string:
+			add(value, node.key)
+		case []interface{}:
+			for _, item := range value {
+				if text, ok := item.(string); ok {
+					add(text, node.key)
+				}
+			}
+		}
+	}
+
+	return links
+}
+
+// Helper function to extract links from a JSON document with the configured JSONPath,
+// or by scanning every string for URLs if none is set
+func selectJSONLinks(data interface{}, baseURL *url.URL) []common.Link {
+	if jsonPathSelector != nil {
+		return collectJSONPathLinks(data, jsonPathSelector, baseURL)
+	}
+	return collectJSONLinks(data)
+}
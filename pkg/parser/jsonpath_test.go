@@ -0,0 +1,106 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/hemzaz/lsweb/pkg/common"
+)
+
+const jsonPathTestDocument = `{
+	"self": "https://api.example.com/releases/7",
+	"owner": {"avatar_url": "https://avatars.example.com/u/1"},
+	"items": [
+		{"name": "app.tar.gz", "type": "file", "size": 2048, "download_url": "/dl/app.tar.gz"},
+		{"name": "docs", "type": "dir", "size": 0, "download_url": "/dl/docs/"},
+		{"name": "app.zip", "type": "file", "size": 4096, "download_url": "https://cdn.example.com/app.zip"}
+	],
+	"mirrors": ["https://m1.example.com/app.zip", "https://m2.example.com/app.zip"]
+}`
+
+func TestCollectJSONPathLinks(t *testing.T) {
+	var data interface{}
+	if err := json.Unmarshal([]byte(jsonPathTestDocument), &data); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	baseURL, _ := url.Parse("https://api.example.com/releases/7")
+
+	tests := []struct {
+		name     string
+		expr     string
+		expected []string
+	}{
+		{"Wildcard", "$.items[*].download_url", []string{"https://api.example.com/dl/app.tar.gz", "https://api.example.com/dl/docs/", "https://cdn.example.com/app.zip"}},
+		{"jq style", ".items[].download_url", []string{"https://api.example.com/dl/app.tar.gz", "https://api.example.com/dl/docs/", "https://cdn.example.com/app.zip"}},
+		{"Index", "$.items[0].download_url", []string{"https://api.example.com/dl/app.tar.gz"}},
+		{"Negative index", "$['items'][-1]['download_url']", []string{"https://cdn.example.com/app.zip"}},
+		{"Union", "$.items[0,2].download_url", []string{"https://api.example.com/dl/app.tar.gz", "https://cdn.example.com/app.zip"}},
+		{"Slice", "$.items[1:].download_url", []string{"https://api.example.com/dl/docs/", "https://cdn.example.com/app.zip"}},
+		{"Filter equality", "$.items[?(@.type == 'file')].download_url", []string{"https://api.example.com/dl/app.tar.gz", "https://cdn.example.com/app.zip"}},
+		{"Filter comparison", "$.items[?(@.size > 3000)].download_url", []string{"https://cdn.example.com/app.zip"}},
+		{"Filter regex", `$.items[?(@.name =~ "\\.tar\\.gz$")].download_url`, []string{"https://api.example.com/dl/app.tar.gz"}},
+		{"Recursive descent", "$..avatar_url", []string{"https://avatars.example.com/u/1"}},
+		{"Array of strings", "$.mirrors", []string{"https://m1.example.com/app.zip", "https://m2.example.com/app.zip"}},
+		{"Missing member", "$.assets[*].url", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := compileJSONPath(tt.expr)
+			if err != nil {
+				t.Fatalf("compileJSONPath(%q) failed: %v", tt.expr, err)
+			}
+			links := common.URLs(collectJSONPathLinks(data, path, baseURL))
+			if fmt.Sprint(links) != fmt.Sprint(tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, links)
+			}
+		})
+	}
+}
+
+func TestCompileJSONPathErrors(t *testing.T) {
+	for _, expr := range []string{
+		"$.items[",
+		"$.items[abc]",
+		"$.items[?(@.size ~ 3)]",
+		"$.items[?(@.name =~ 3)]",
+		"$.items[1:2:0]",
+		"$items",
+	} {
+		t.Run(expr, func(t *testing.T) {
+			if _, err := compileJSONPath(expr); err == nil {
+				t.Errorf("Expected an error for %q", expr)
+			}
+		})
+	}
+}
+
+func TestExtractLinksFromURLWithJSONPath(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, jsonPathTestDocument)
+	}))
+	defer server.Close()
+
+	if err := SetJSONPath("$.items[?(@.type == 'file')].download_url"); err != nil {
+		t.Fatalf("SetJSONPath failed: %v", err)
+	}
+	defer SetJSONPath("")
+
+	links, err := FetchLinks(server.URL+"/releases/7", false)
+	if err != nil {
+		t.Fatalf("FetchLinks failed: %v", err)
+	}
+
+	expected := []string{server.URL + "/dl/app.tar.gz", "https://cdn.example.com/app.zip"}
+	if fmt.Sprint(common.URLs(links)) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, common.URLs(links))
+	}
+	if len(links) > 0 && (links[0].Element != "json" || links[0].Attribute != "download_url") {
+		t.Errorf("Unexpected metadata: %+v", links[0])
+	}
+}
@@ -0,0 +1,540 @@
+package parser
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hemzaz/lsweb/pkg/common"
+)
+
+// fileBaseURL is the URL relative links in files resolve against, or nil to use the file's own URL
+var fileBaseURL *url.URL
+
+// SetBaseURL sets the URL that relative links in files read by ReadLinks are resolved
+// against, such as the published location of a documentation page.
+// An empty string restores the default of resolving against the file's own file:// URL.
+// Returns an error if rawURL is not an absolute URL.
+func SetBaseURL(rawURL string) error {
+	if rawURL == "" {
+		fileBaseURL = nil
+		return nil
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid base URL: %w", err)
+	}
+	if !parsed.IsAbs() {
+		return fmt.Errorf("base URL must be absolute: %s", rawURL)
+	}
+	fileBaseURL = parsed
+	return nil
+}
+
+// Helper function to return the URL relative links in a file resolve against
+func fileURL(filePath string) *url.URL {
+	if fileBaseURL != nil {
+		return fileBaseURL
+	}
+
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		absPath = filePath
+	}
+	return &url.URL{Scheme: "file", Path: filepath.ToSlash(absPath)}
+}
+
+// markupFormats maps documentation file extensions to their markup format
+var markupFormats = map[string]string{
+	".md":       "markdown",
+	".markdown": "markdown",
+	".mdown":    "markdown",
+	".mkd":      "markdown",
+	".rst":      "rst",
+	".rest":     "rst",
+	".adoc":     "asciidoc",
+	".asciidoc": "asciidoc",
+	".asc":      "asciidoc",
+}
+
+// bareURLPattern matches URLs written out in running text
+var bareURLPattern = regexp.MustCompile(`https?://[^\s<>"'\x60]+`)
+
+// Helper function to trim the punctuation that ends a sentence or encloses a bare URL,
+// such as a trailing period or the closing parenthesis in "(see https://example.com)".
+// Closing brackets are kept when they balance an opening one inside the URL.
+func trimBareURL(rawURL string) string {
+	for rawURL != "" {
+		last := rawURL[len(rawURL)-1]
+		switch last {
+		case '.', ',', ':', ';', '!', '?', '*', '_', '~':
+			rawURL = rawURL[:len(rawURL)-1]
+			continue
+		case ')', ']', '}':
+			open := map[byte]string{')': "(", ']': "[", '}': "{"}[last]
+			if strings.Count(rawURL, open) < strings.Count(rawURL, string(last)) {
+				rawURL = rawURL[:len(rawURL)-1]
+				continue
+			}
+		}
+		return rawURL
+	}
+	return rawURL
+}
+
+// markupCollector gathers the links of one markup document, resolving and
+// deduplicating them as they are added
+type markupCollector struct {
+	baseURL *url.URL
+	element string
+	links   []common.Link
+	visited map[string]bool
+}
+
+// Helper function to create a collector for links found in the given markup format
+func newMarkupCollector(baseURL *url.URL, element string) *markupCollector {
+	return &markupCollector{baseURL: baseURL, element: element, visited: make(map[string]bool)}
+}
+
+// add resolves a link of the given kind and records it unless it was already seen.
+// Fragment-only, mailto: and malformed links are skipped.
+func (c *markupCollector) add(rawURL, kind, text, title string) {
+	rawURL = strings.TrimSpace(rawURL)
+	if rawURL == "" || strings.HasPrefix(rawURL, "#") {
+		return
+	}
+
+	urlStr, err := resolveLink(rawURL, c.baseURL)
+	if err != nil || urlStr == "" || c.visited[urlStr] {
+		return
+	}
+	c.visited[urlStr] = true
+
+	c.links = append(c.links, common.Link{
+		URL:       urlStr,
+		Text:      strings.Join(strings.Fields(text), " "),
+		Title:     title,
+		Element:   c.element,
+		Attribute: kind,
+	})
+}
+
+// addBareURLs records the URLs written out in text
+func (c *markupCollector) addBareURLs(text string) {
+	for _, match := range bareURLPattern.FindAllString(text, -1) {
+		c.add(trimBareURL(match), "url", "", "")
+	}
+}
+
+// Helper function to extract links from a markup document in the given format
+func collectMarkupLinks(content, format string, baseURL *url.URL) []common.Link {
+	switch format {
+	case "markdown":
+		return collectMarkdownLinks(content, baseURL)
+	case "rst":
+		return collectRSTLinks(content, baseURL)
+	case "asciidoc":
+		return collectAsciiDocLinks(content, baseURL)
+	}
+	return nil
+}
+
+// markdownFencePattern matches the opening or closing line of a fenced code block
+var markdownFencePattern = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+
+// markdownDefinitionPattern matches a link reference definition: [label]: url "title"
+var markdownDefinitionPattern = regexp.MustCompile(`^ {0,3}\[([^\]]+)\]:\s*(<[^>]*>|\S+)(?:\s+(?:"([^"]*)"|'([^']*)'|\(([^)]*)\)))?\s*$`)
+
+// markdownAutolinkPattern matches an autolink in angle brackets, such as <https://example.com>,
+// at the start of the input
+var markdownAutolinkPattern = regexp.MustCompile(`^<([a-zA-Z][a-zA-Z0-9+.-]{1,31}:[^\s<>]*)>`)
+
+// markdownHTMLPattern matches the href or src attribute of a raw HTML tag in Markdown
+// at the start of the input
+var markdownHTMLPattern = regexp.MustCompile(`(?i)^<(?:a|img|source|video|audio|iframe)\b[^>]*?\s(?:href|src)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+
+// Helper function to extract links from Markdown: inline links and images with
+// balanced parentheses, reference-style links and their definitions, autolinks,
+// raw HTML anchors and images, and bare URLs. Fenced code blocks and code spans are skipped.
+func collectMarkdownLinks(content string, baseURL *url.URL) []common.Link {
+	collector := newMarkupCollector(baseURL, "markdown")
+
+	// Collect reference definitions first so references can be resolved in order
+	type definition struct{ label, url, title string }
+	definitions := make(map[string]definition)
+	var definitionOrder []string
+	var text strings.Builder
+	fence := ""
+
+	for _, line := range strings.Split(content, "\n") {
+		if match := markdownFencePattern.FindStringSubmatch(line); match != nil {
+			marker := match[1]
+			if fence == "" {
+				fence = marker
+			} else if marker[0] == fence[0] && len(marker) >= len(fence) {
+				fence = ""
+			}
+			text.WriteString("\n")
+			continue
+		}
+		if fence != "" {
+			text.WriteString("\n")
+			continue
+		}
+
+		if match := markdownDefinitionPattern.FindStringSubmatch(line); match != nil {
+			label := normalizeMarkdownLabel(match[1])
+			if _, exists := definitions[label]; !exists {
+				definitions[label] = definition{
+					label: match[1],
+					url:   strings.TrimSuffix(strings.TrimPrefix(match[2], "<"), ">"),
+					title: match[3] + match[4] + match[5],
+				}
+				definitionOrder = append(definitionOrder, label)
+			}
+			text.WriteString("\n")
+			continue
+		}
+
+		text.WriteString(blankCodeSpans(line))
+		text.WriteString("\n")
+	}
+
+	body := []byte(text.String())
+
+	// Links found in the text are blanked out so bare URL matching skips them
+	blank := func(start, end int) {
+		for i := start; i < end; i++ {
+			if body[i] != '\n' {
+				body[i] = ' '
+			}
+		}
+	}
+
+	for i := 0; i < len(body); i++ {
+		switch body[i] {
+		case '<':
+			if match := markdownAutolinkPattern.FindSubmatchIndex(body[i:]); match != nil {
+				collector.add(string(body[i+match[2]:i+match[3]]), "autolink", "", "")
+				blank(i, i+match[1])
+				i += match[1] - 1
+			} else if match := markdownHTMLPattern.FindSubmatchIndex(body[i:]); match != nil {
+				value := match[2:4]
+				if value[0] < 0 {
+					value = match[4:6]
+				}
+				collector.add(string(body[i+value[0]:i+value[1]]), "html", "", "")
+				blank(i, i+match[1])
+				i += match[1] - 1
+			}
+
+		case '[':
+			closeBracket := matchingBracket(body, i)
+			if closeBracket < 0 {
+				continue
+			}
+			label := string(body[i+1 : closeBracket])
+			image := i > 0 && body[i-1] == '!'
+			kind := "link"
+			if image {
+				kind = "image"
+			}
+
+			// Inline link: [text](destination "title")
+			if closeBracket+1 < len(body) && body[closeBracket+1] == '(' {
+				if destination, title, end, ok := parseMarkdownDestination(body, closeBracket+2); ok {
+					collector.add(destination, kind, label, title)
+					blank(closeBracket+1, end)
+					continue
+				}
+			}
+
+			// Full, collapsed or shortcut reference: [text][label], [text][] or [text]
+			reference := label
+			end := closeBracket + 1
+			if closeBracket+1 < len(body) && body[closeBracket+1] == '[' {
+				if closeRef := matchingBracket(body, closeBracket+1); closeRef >= 0 {
+					if ref := string(body[closeBracket+2 : closeRef]); ref != "" {
+						reference = ref
+					}
+					end = closeRef + 1
+				}
+			}
+			if def, ok := definitions[normalizeMarkdownLabel(reference)]; ok {
+				collector.add(def.url, kind, label, def.title)
+				blank(i, end)
+			}
+		}
+	}
+
+	collector.addBareURLs(string(body))
+
+	// Definitions that were never referenced are still links in the document
+	for _, label := range definitionOrder {
+		def := definitions[label]
+		collector.add(def.url, "reference", def.label, def.title)
+	}
+
+	return collector.links
+}
+
+// Helper function to replace the Markdown code spans in a line with spaces.
+// A code span runs from a run of backticks to the next run of the same length.
+func blankCodeSpans(line string) string {
+	result := []byte(line)
+	for i := 0; i < len(result); {
+		if result[i] != '`' {
+			i++
+			continue
+		}
+
+		run := 1
+		for i+run < len(result) && result[i+run] == '`' {
+			run++
+		}
+
+		// Find a closing run of exactly the same length
+		closing := -1
+		for j := i + run; j < len(result); {
+			if result[j] != '`' {
+				j++
+				continue
+			}
+			length := 1
+			for j+length < len(result) && result[j+length] == '`' {
+				length++
+			}
+			if length == run {
+				closing = j + length
+				break
+			}
+			j += length
+		}
+
+		if closing < 0 {
+			i += run
+			continue
+		}
+		for k := i; k < closing; k++ {
+			result[k] = ' '
+		}
+		i = closing
+	}
+	return string(result)
+}
+
+// Helper function to normalize a Markdown reference label for matching:
+// case-insensitive with runs of whitespace collapsed
+func normalizeMarkdownLabel(label string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " "))
+}
+
+// Helper function to find the ] matching the [ at open, allowing nested and escaped brackets.
+// Returns -1 if the bracket is not closed on the same paragraph.
+func matchingBracket(body []byte, open int) int {
+	depth := 0
+	for i := open; i < len(body); i++ {
+		switch body[i] {
+		case '\\':
+			i++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		case '\n':
+			if i+1 < len(body) && body[i+1] == '\n' {
+				return -1
+			}
+		}
+	}
+	return -1
+}
+
+// Helper function to parse an inline link destination and optional title starting at start,
+// just after the opening parenthesis. Destinations may be wrapped in <> or contain balanced
+// parentheses. Returns the destination, the title, the index after the closing parenthesis
+// and whether a well-formed destination was found.
+func parseMarkdownDestination(body []byte, start int) (string, string, int, bool) {
+	i := start
+	skipSpace := func() {
+		for i < len(body) && (body[i] == ' ' || body[i] == '\t' || body[i] == '\n') {
+			i++
+		}
+	}
+	skipSpace()
+
+	var destination string
+	if i < len(body) && body[i] == '<' {
+		end := strings.IndexAny(string(body[i+1:]), ">\n")
+		if end < 0 || body[i+1+end] != '>' {
+			return "", "", 0, false
+		}
+		destination = string(body[i+1 : i+1+end])
+		i += end + 2
+	} else {
+		depth := 0
+		begin := i
+	scan:
+		for ; i < len(body); i++ {
+			switch body[i] {
+			case '\\':
+				i++
+			case '(':
+				depth++
+			case ')':
+				if depth == 0 {
+					break scan
+				}
+				depth--
+			case ' ', '\t', '\n':
+				break scan
+			}
+		}
+		if i > len(body) {
+			i = len(body)
+		}
+		destination = string(body[begin:i])
+	}
+
+	skipSpace()
+	title := ""
+	if i < len(body) && (body[i] == '"' || body[i] == '\'' || body[i] == '(') {
+		closer := body[i]
+		if closer == '(' {
+			closer = ')'
+		}
+		end := strings.IndexByte(string(body[i+1:]), closer)
+		if end < 0 {
+			return "", "", 0, false
+		}
+		title = string(body[i+1 : i+1+end])
+		i += end + 2
+		skipSpace()
+	}
+
+	if i >= len(body) || body[i] != ')' {
+		return "", "", 0, false
+	}
+	return destination, title, i + 1, true
+}
+
+// rstInlineLinkPattern matches embedded URIs in inline hyperlinks: `text <url>`_ or `text <url>`__
+var rstInlineLinkPattern = regexp.MustCompile("`([^`<]*?)\\s*<([^`>]+)>`__?")
+
+// rstTargetPattern matches hyperlink targets: .. _label: url
+var rstTargetPattern = regexp.MustCompile(`^\s*\.\.\s+_([^:]+|` + "`[^`]+`" + `):\s*(\S+)\s*$`)
+
+// rstDirectivePattern matches directives whose argument is a link: .. image:: path
+var rstDirectivePattern = regexp.MustCompile(`^\s*\.\.\s+(image|figure|include|literalinclude|video|raw)::\s*(\S+)\s*$`)
+
+// rstOptionPattern matches directive options that hold a link, such as :target: url
+var rstOptionPattern = regexp.MustCompile(`^\s+:(target|url|file):\s*(\S+)\s*$`)
+
+// rstLiteralPattern matches inline literals, which contain no links
+var rstLiteralPattern = regexp.MustCompile("``[^`]+``")
+
+// Helper function to extract links from reStructuredText: embedded URIs in inline
+// hyperlinks, hyperlink targets, image, figure and include directives, :target:
+// options, and standalone URIs. Inline literals are skipped.
+func collectRSTLinks(content string, baseURL *url.URL) []common.Link {
+	collector := newMarkupCollector(baseURL, "rst")
+	var text strings.Builder
+
+	for _, line := range strings.Split(content, "\n") {
+		if match := rstTargetPattern.FindStringSubmatch(line); match != nil {
+			// A target ending in _ refers to another target rather than a URL
+			if !strings.HasSuffix(match[2], "_") {
+				collector.add(match[2], "target", strings.Trim(match[1], "`"), "")
+			}
+			continue
+		}
+		if match := rstDirectivePattern.FindStringSubmatch(line); match != nil {
+			if match[1] != "raw" {
+				collector.add(match[2], match[1], "", "")
+			}
+			continue
+		}
+		if match := rstOptionPattern.FindStringSubmatch(line); match != nil {
+			collector.add(match[2], match[1], "", "")
+			continue
+		}
+
+		line = rstLiteralPattern.ReplaceAllString(line, "")
+		text.WriteString(line)
+		text.WriteString("\n")
+	}
+
+	body := text.String()
+	for _, match := range rstInlineLinkPattern.FindAllStringSubmatch(body, -1) {
+		collector.add(match[2], "link", match[1], "")
+	}
+	collector.addBareURLs(rstInlineLinkPattern.ReplaceAllString(body, ""))
+
+	return collector.links
+}
+
+// asciidocMacroPattern matches link, image, include, video, audio and xref macros with
+// their attribute list, such as image::diagram.png[Diagram] or link:guide.html[Guide]
+var asciidocMacroPattern = regexp.MustCompile(`\b(link|image|include|video|audio|xref)::?([^\s\[\]]+)\[([^\]]*)\]`)
+
+// asciidocURLMacroPattern matches URLs followed by link text: https://example.com[Example]
+var asciidocURLMacroPattern = regexp.MustCompile(`\b(https?://[^\s\[\]]+)\[([^\]]*)\]`)
+
+// asciidocDelimiterPattern matches the delimiters of listing, literal, passthrough and comment blocks
+var asciidocDelimiterPattern = regexp.MustCompile(`^(-{4,}|\.{4,}|\+{4,}|/{4,})\s*$`)
+
+// Helper function to extract links from AsciiDoc: link, image, include, video, audio
+// and xref macros, URLs with link text, and bare URLs. Listing, literal, passthrough
+// and comment blocks and line comments are skipped.
+func collectAsciiDocLinks(content string, baseURL *url.URL) []common.Link {
+	collector := newMarkupCollector(baseURL, "asciidoc")
+	var text strings.Builder
+	block := ""
+
+	for _, line := range strings.Split(content, "\n") {
+		if match := asciidocDelimiterPattern.FindStringSubmatch(line); match != nil {
+			if block == "" {
+				block = match[1]
+			} else if match[1] == block {
+				block = ""
+			}
+			continue
+		}
+		if block != "" || (strings.HasPrefix(line, "//") && !strings.HasPrefix(line, "///")) {
+			continue
+		}
+		text.WriteString(line)
+		text.WriteString("\n")
+	}
+
+	body := text.String()
+	for _, match := range asciidocMacroPattern.FindAllStringSubmatch(body, -1) {
+		target := match[2]
+		if match[1] == "xref" && !strings.Contains(target, ".") {
+			// Cross references to an ID in the same document
+			continue
+		}
+		if match[1] == "xref" || match[1] == "include" {
+			target = strings.SplitN(target, "#", 2)[0]
+		}
+		text := match[3]
+		if i := strings.Index(text, ","); i >= 0 && match[1] != "link" {
+			text = text[:i]
+		}
+		collector.add(target, match[1], strings.Trim(text, `"`), "")
+	}
+	body = asciidocMacroPattern.ReplaceAllString(body, "")
+
+	for _, match := range asciidocURLMacroPattern.FindAllStringSubmatch(body, -1) {
+		collector.add(match[1], "link", strings.Trim(match[2], `"`), "")
+	}
+	collector.addBareURLs(asciidocURLMacroPattern.ReplaceAllString(body, ""))
+
+	return collector.links
+}
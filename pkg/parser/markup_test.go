@@ -0,0 +1,182 @@
+package parser
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hemzaz/lsweb/pkg/common"
+)
+
+func TestCollectMarkdownLinks(t *testing.T) {
+	baseURL, _ := url.Parse("https://docs.example.com/guide/intro.html")
+
+	content := "# Guide\n" +
+		"\n" +
+		"See [the setup page](setup.md \"Setup\") and ![diagram](img/arch.png).\n" +
+		"Wikipedia has [an article](https://en.wikipedia.org/wiki/Foo_(bar)) on it.\n" +
+		"Read the [reference docs][ref] or just [FAQ][].\n" +
+		"Visit <https://example.com/autolink> or (https://example.com/bare).\n" +
+		"Inline code `[not](a-link.md)` is ignored.\n" +
+		"<a href=\"/raw.html\">raw</a>\n" +
+		"\n" +
+		"```\n" +
+		"[fenced](fenced.md) https://example.com/fenced\n" +
+		"```\n" +
+		"\n" +
+		"[ref]: ../api/index.html 'API'\n" +
+		"[faq]: <faq page.md>\n" +
+		"[unused]: https://example.com/unused\n"
+
+	expected := []common.Link{
+		{URL: "https://docs.example.com/guide/setup.md", Text: "the setup page", Title: "Setup", Element: "markdown", Attribute: "link"},
+		{URL: "https://docs.example.com/guide/img/arch.png", Text: "diagram", Element: "markdown", Attribute: "image"},
+		{URL: "https://en.wikipedia.org/wiki/Foo_(bar)", Text: "an article", Element: "markdown", Attribute: "link"},
+		{URL: "https://docs.example.com/api/index.html", Text: "reference docs", Title: "API", Element: "markdown", Attribute: "link"},
+		{URL: "https://docs.example.com/guide/faq%20page.md", Text: "FAQ", Element: "markdown", Attribute: "link"},
+		{URL: "https://example.com/autolink", Element: "markdown", Attribute: "autolink"},
+		{URL: "https://docs.example.com/raw.html", Element: "markdown", Attribute: "html"},
+		{URL: "https://example.com/bare", Element: "markdown", Attribute: "url"},
+		{URL: "https://example.com/unused", Text: "unused", Element: "markdown", Attribute: "reference"},
+	}
+
+	links := collectMarkdownLinks(content, baseURL)
+	if len(links) != len(expected) {
+		t.Fatalf("Expected %d links, got %d: %+v", len(expected), len(links), links)
+	}
+	for i, link := range links {
+		if link != expected[i] {
+			t.Errorf("Expected %+v at position %d, got %+v", expected[i], i, link)
+		}
+	}
+}
+
+func TestCollectRSTLinks(t *testing.T) {
+	baseURL, _ := url.Parse("https://docs.example.com/guide/")
+
+	content := "Guide\n" +
+		"=====\n" +
+		"\n" +
+		"See `the setup page <setup.html>`_ and `Python <https://www.python.org/>`__.\n" +
+		"Standalone https://example.com/standalone. Literal ``https://example.com/literal`` is skipped.\n" +
+		"Read the docs_.\n" +
+		"\n" +
+		".. image:: img/logo.png\n" +
+		"   :target: https://example.com/home\n" +
+		"\n" +
+		".. include:: ../shared/footer.rst\n" +
+		"\n" +
+		".. _docs: https://docs.example.com/full/\n" +
+		".. _alias: docs_\n"
+
+	expected := []string{
+		"https://docs.example.com/guide/img/logo.png",
+		"https://example.com/home",
+		"https://docs.example.com/shared/footer.rst",
+		"https://docs.example.com/full/",
+		"https://docs.example.com/guide/setup.html",
+		"https://www.python.org/",
+		"https://example.com/standalone",
+	}
+
+	links := common.URLs(collectRSTLinks(content, baseURL))
+	if len(links) != len(expected) {
+		t.Fatalf("Expected %d links, got %d: %v", len(expected), len(links), links)
+	}
+	for i, link := range links {
+		if link != expected[i] {
+			t.Errorf("Expected %s at position %d, got %s", expected[i], i, link)
+		}
+	}
+}
+
+func TestCollectAsciiDocLinks(t *testing.T) {
+	baseURL, _ := url.Parse("https://docs.example.com/guide/")
+
+	content := "= Guide\n" +
+		"\n" +
+		"See link:setup.html[the setup page] and https://asciidoctor.org[Asciidoctor].\n" +
+		"image::diagrams/arch.svg[Architecture,300,200]\n" +
+		"Jump to <<internal,a section>> or xref:other.adoc#part[another page].\n" +
+		"Bare https://example.com/bare, in a sentence.\n" +
+		"include::partials/footer.adoc[]\n" +
+		"// https://example.com/comment\n" +
+		"\n" +
+		"----\n" +
+		"https://example.com/listing\n" +
+		"----\n"
+
+	expected := []common.Link{
+		{URL: "https://docs.example.com/guide/setup.html", Text: "the setup page", Element: "asciidoc", Attribute: "link"},
+		{URL: "https://docs.example.com/guide/diagrams/arch.svg", Text: "Architecture", Element: "asciidoc", Attribute: "image"},
+		{URL: "https://docs.example.com/guide/other.adoc", Text: "another page", Element: "asciidoc", Attribute: "xref"},
+		{URL: "https://docs.example.com/guide/partials/footer.adoc", Element: "asciidoc", Attribute: "include"},
+		{URL: "https://asciidoctor.org", Text: "Asciidoctor", Element: "asciidoc", Attribute: "link"},
+		{URL: "https://example.com/bare", Element: "asciidoc", Attribute: "url"},
+	}
+
+	links := collectAsciiDocLinks(content, baseURL)
+	if len(links) != len(expected) {
+		t.Fatalf("Expected %d links, got %d: %+v", len(expected), len(links), links)
+	}
+	for i, link := range links {
+		if link != expected[i] {
+			t.Errorf("Expected %+v at position %d, got %+v", expected[i], i, link)
+		}
+	}
+}
+
+func TestTrimBareURL(t *testing.T) {
+	tests := map[string]string{
+		"https://example.com/page.":        "https://example.com/page",
+		"https://example.com/page)":        "https://example.com/page",
+		"https://example.com/a_(b)":        "https://example.com/a_(b)",
+		"https://example.com/a_(b)).":      "https://example.com/a_(b)",
+		"https://example.com/?q=1&r=2,":    "https://example.com/?q=1&r=2",
+		"https://example.com/path/file.md": "https://example.com/path/file.md",
+	}
+
+	for input, expected := range tests {
+		if result := trimBareURL(input); result != expected {
+			t.Errorf("trimBareURL(%q) = %q, expected %q", input, result, expected)
+		}
+	}
+}
+
+func TestReadLinksMarkupWithBaseURL(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "README.md")
+	if err := os.WriteFile(filePath, []byte("See [install](docs/install.md) and (https://example.com/x).\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	// Without a base URL, relative links resolve against the file itself
+	links, err := ExtractLinksFromFile(filePath)
+	if err != nil {
+		t.Fatalf("ExtractLinksFromFile failed: %v", err)
+	}
+	expectedFile := (&url.URL{Scheme: "file", Path: filepath.ToSlash(filepath.Join(dir, "docs", "install.md"))}).String()
+	if len(links) != 2 || links[0] != expectedFile || links[1] != "https://example.com/x" {
+		t.Errorf("Unexpected links without base URL: %v", links)
+	}
+
+	defer func() {
+		_ = SetBaseURL("")
+	}()
+	if err := SetBaseURL("https://github.com/owner/repo/blob/main/"); err != nil {
+		t.Fatalf("SetBaseURL failed: %v", err)
+	}
+
+	links, err = ExtractLinksFromFile(filePath)
+	if err != nil {
+		t.Fatalf("ExtractLinksFromFile failed: %v", err)
+	}
+	if len(links) != 2 || links[0] != "https://github.com/owner/repo/blob/main/docs/install.md" {
+		t.Errorf("Unexpected links with base URL: %v", links)
+	}
+
+	if err := SetBaseURL("relative/path"); err == nil {
+		t.Error("Expected error for relative base URL, got nil")
+	}
+}
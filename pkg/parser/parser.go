@@ -6,24 +6,66 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
 	"regexp"
+	"sort"
 	"strings"
+	"time"
 
 	"golang.org/x/net/html"
 
 	"github.com/hemzaz/lsweb/pkg/common"
 )
 
+// maxRefreshRedirects limits how many meta-refresh redirects are followed for one URL
+const maxRefreshRedirects = 5
+
+// ErrUnsupportedContentType is returned when a URL serves content links cannot be extracted from,
+// such as a binary file
+var ErrUnsupportedContentType = errors.New("unsupported content type")
+
+// followRefresh controls whether ExtractLinksFromURL follows meta-refresh redirects
+var followRefresh = false
+
+// SetFollowRefresh sets whether ExtractLinksFromURL follows <meta http-equiv="refresh">
+// redirects and extracts links from the target page instead.
+func SetFollowRefresh(follow bool) {
+	followRefresh = follow
+}
+
 // ExtractLinksFromURL fetches a URL and extracts all links from its content.
-// Supports HTML, JSON, XML content types.
-// The ignoreCert parameter can be used to skip TLS certificate validation.
+// It is a convenience wrapper around FetchLinks that returns only the URLs.
 // Returns a slice of unique links found in the content or an error if the fetch or parsing fails.
 func ExtractLinksFromURL(targetURL string, ignoreCert bool) ([]string, error) {
+	links, err := FetchLinks(targetURL, ignoreCert)
+	if err != nil {
+		return nil, err
+	}
+	return common.URLs(links), nil
+}
+
+// FetchLinks fetches a URL and extracts all links from its content together with
+// their metadata: anchor text, title, rel, source element and attribute, the page
+// they came from and their position, plus size and dates for listings.
+// Supports HTML, JSON, XML and CSS content types, and PDF, DOCX, XLSX, PPTX and EPUB
+// documents, whose hyperlink annotations and relationships are extracted. HTML is transcoded to UTF-8 from the charset
+// given by its Content-Type header, <meta charset> tag or byte order mark.
+// The ignoreCert parameter can be used to skip TLS certificate validation.
+// If meta-refresh following is enabled, HTML pages that redirect via
+// <meta http-equiv="refresh"> are followed up to maxRefreshRedirects times.
+// Returns the unique links found in the content or an error if the fetch or parsing fails.
+func FetchLinks(targetURL string, ignoreCert bool) ([]common.Link, error) {
+	return fetchLinks(targetURL, ignoreCert, 0)
+}
+
+// fetchLinks implements FetchLinks, tracking the number of
+// meta-refresh redirects followed so far.
+func fetchLinks(targetURL string, ignoreCert bool, redirects int) ([]common.Link, error) {
 	// Set up a client with timeout
 	client := &http.Client{
 		Timeout: common.DefaultTimeout,
@@ -46,6 +88,7 @@ func ExtractLinksFromURL(targetURL string, ignoreCert bool) ([]string, error) {
 
 	// Add a user-agent to be polite
 	req.Header.Set("User-Agent", common.UserAgent)
+	req.Header.Set("Accept-Encoding", acceptEncoding)
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -53,7 +96,7 @@ func ExtractLinksFromURL(targetURL string, ignoreCert bool) ([]string, error) {
 	}
 	defer func() {
 		if closeErr := resp.Body.Close(); closeErr != nil {
-			fmt.Printf("Error closing response body: %v\n", closeErr)
+			fmt.Fprintf(os.Stderr, "Error closing response body: %v\n", closeErr)
 		}
 	}()
 
@@ -63,30 +106,111 @@ func ExtractLinksFromURL(targetURL string, ignoreCert bool) ([]string, error) {
 
 	// Check content type - only process recognized types
 	contentType := resp.Header.Get("Content-Type")
-	if !strings.Contains(contentType, "text/html") &&
-		!strings.Contains(contentType, "application/json") &&
-		!strings.Contains(contentType, "application/xml") &&
-		!strings.Contains(contentType, "text/xml") {
-		return nil, fmt.Errorf("unsupported content type: %s", contentType)
+	if !isSupportedContentType(contentType) && !isDocumentResponse(contentType, resp.Request.URL.Path) {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedContentType, contentType)
 	}
 
-	// Limit body size for safety
-	bodyBytes, err := io.ReadAll(io.LimitReader(resp.Body, common.MaxContentSize))
+	// Limit body size for safety and decode any Content-Encoding
+	bodyBytes, err := readResponseBody(resp)
 	if err != nil {
-		return nil, fmt.Errorf("error reading response body: %w", err)
+		return nil, err
+	}
+
+	return extractLinksFromBody(bodyBytes, contentType, resp.Request.URL, ignoreCert, redirects)
+}
+
+// Helper function to report whether links can be extracted from a content type
+func isSupportedContentType(contentType string) bool {
+	return isHTMLContentType(contentType) ||
+		strings.Contains(contentType, "application/json") ||
+		isXMLContentType(contentType) ||
+		isCSSContentType(contentType) ||
+		isCompressedContentType(contentType) ||
+		isDocumentContentType(contentType)
+}
+
+// Helper function to report whether a content type is HTML or XHTML
+func isHTMLContentType(contentType string) bool {
+	return strings.Contains(contentType, "text/html") ||
+		strings.Contains(contentType, "application/xhtml+xml")
+}
+
+// Helper function to extract the links from a fetched document body according to
+// its content type. pageURL is the final URL of the document after redirects;
+// redirects counts the meta-refresh redirects followed so far.
+func extractLinksFromBody(bodyBytes []byte, contentType string, pageURL *url.URL, ignoreCert bool, redirects int) ([]common.Link, error) {
+	var err error
+
+	// The declared charset applies to HTML unless the body was compressed
+	charsetHint := contentType
+
+	// Compressed files such as .xml.gz sitemaps are typed by their decompressed content
+	if isCompressedContentType(contentType) {
+		bodyBytes, err = decompressIfCompressed(bodyBytes, pageURL.Path)
+		if err != nil {
+			return nil, err
+		}
+		contentType = http.DetectContentType(bodyBytes)
+		charsetHint = ""
+
+		// Stylesheets are sniffed as plain text, so recognize them by extension
+		if strings.EqualFold(fileExtension(pageURL.Path), ".css") {
+			contentType = "text/css"
+		}
 	}
 
 	// Different handling based on content type
-	var links []string
+	var links []common.Link
 
-	if strings.Contains(contentType, "application/json") {
+	if format := documentFormat(bodyBytes); format != "" {
+		// PDF, Office and EPUB documents are recognized by their content
+		links, err = collectDocumentLinks(bodyBytes, format, pageURL)
+		if err != nil {
+			return nil, err
+		}
+	} else if strings.Contains(contentType, "application/json") {
 		// For JSON content, try to extract URLs from JSON structure
 		var jsonData interface{}
 		if err := json.Unmarshal(bodyBytes, &jsonData); err != nil {
 			return nil, fmt.Errorf("error parsing JSON: %w", err)
 		}
-		links = extractLinksFromJSON(jsonData)
+		links = selectJSONLinks(jsonData, pageURL)
+	} else if isXMLContentType(contentType) && isSitemap(bodyBytes) {
+		// Expand sitemaps and sitemap indexes into the pages they list
+		entries, err := expandSitemap(bodyBytes, pageURL, ignoreCert)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			links = append(links, entry.Link())
+		}
+	} else if isXMLContentType(contentType) && isS3Listing(bodyBytes) {
+		// Page through S3-compatible bucket listings
+		objects, err := expandS3Listing(bodyBytes, pageURL, ignoreCert)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range objects {
+			links = append(links, object.Link())
+		}
+	} else if isXMLContentType(contentType) {
+		// Parse RSS, Atom or generic XML for links
+		links, err = collectXMLLinks(bytes.NewReader(bodyBytes), pageURL)
+		if err != nil {
+			return nil, err
+		}
+	} else if isCSSContentType(contentType) {
+		// Resolve url() and @import references against the stylesheet's own URL
+		links = collectCSSLinks(string(bodyBytes), pageURL, "css")
+	} else if isCompressedContentType(contentType) {
+		return nil, fmt.Errorf("unsupported compressed content type: %s", contentType)
 	} else {
+		// Transcode to UTF-8 so non-ASCII links survive
+		bodyBytes, err = decodeHTML(bodyBytes, charsetHint)
+		if err != nil {
+			return nil, err
+		}
+
 		// Create a new reader from the bytes
 		bodyReader := bytes.NewReader(bodyBytes)
 
@@ -96,58 +220,171 @@ func ExtractLinksFromURL(targetURL string, ignoreCert bool) ([]string, error) {
 			return nil, fmt.Errorf("error parsing HTML: %w", err)
 		}
 
-		// Extract links from HTML
-		var malformedURLs []string
-		links, malformedURLs = extractLinksFromHTML(doc, resp.Request.URL)
+		// Follow a meta-refresh redirect if requested
+		if followRefresh {
+			if target, ok := findMetaRefresh(doc, pageURL); ok && target != pageURL.String() {
+				if redirects >= maxRefreshRedirects {
+					return nil, fmt.Errorf("too many meta-refresh redirects (%d)", redirects)
+				}
+				return fetchLinks(target, ignoreCert, redirects+1)
+			}
+		}
+
+		if isDirectoryIndex(doc) {
+			// Directory listings only contribute their entries, not parent or sort links
+			for _, entry := range parseDirectoryIndex(doc, pageURL) {
+				links = append(links, entry.Link())
+			}
+		} else {
+			// Extract links from HTML
+			var malformedURLs []string
+			links, malformedURLs = collectHTMLLinks(doc, pageURL)
 
-		if len(malformedURLs) > 0 {
-			// Continue with the links we found, but warn about malformed ones
-			fmt.Printf("Warning: %d malformed URLs detected\n", len(malformedURLs))
+			if len(malformedURLs) > 0 {
+				// Continue with the links we found, but warn about malformed ones
+				fmt.Fprintf(os.Stderr, "Warning: %d malformed URLs detected\n", len(malformedURLs))
+			}
 		}
 	}
 
-	// Remove duplicates
-	links = removeDuplicateLinks(links)
+	// Remove duplicates and record where each link came from
+	return numberLinks(removeDuplicateLinkDetails(links), pageURL.String()), nil
+}
 
-	return links, nil
+// linkAttributes maps each supported HTML element to the attributes that carry a URL.
+// srcset is not listed here because it holds a candidate list rather than a single URL.
+var linkAttributes = map[string][]string{
+	"a":      {"href"},
+	"area":   {"href"},
+	"link":   {"href"},
+	"img":    {"src"},
+	"script": {"src"},
+	"iframe": {"src"},
+	"frame":  {"src"},
+	"embed":  {"src"},
+	"object": {"data"},
+	"source": {"src"},
+	"video":  {"src", "poster"},
+	"audio":  {"src"},
+	"track":  {"src"},
+}
+
+// srcsetElements lists the elements whose srcset attribute is read when the
+// "srcset" tag is enabled.
+var srcsetElements = map[string]bool{
+	"img":    true,
+	"source": true,
+}
+
+// enabledTags holds the element names links are extracted from.
+// The pseudo-tag "srcset" enables srcset candidates on img and source elements, and
+// "style" enables url() and @import references in <style> blocks and style attributes.
+var enabledTags = map[string]bool{"a": true}
+
+// SetTags sets which HTML elements links are extracted from.
+// Valid names are the supported element names (a, area, link, img, script, iframe,
+// frame, embed, object, source, video, audio, track), "srcset", "style" and "all".
+// An empty slice restores the default of extracting only anchors.
+// Returns an error if any tag name is not recognized.
+func SetTags(tags []string) error {
+	selected := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		switch {
+		case tag == "":
+			continue
+		case tag == "all":
+			for name := range linkAttributes {
+				selected[name] = true
+			}
+			selected["srcset"] = true
+			selected["style"] = true
+		case tag == "srcset" || tag == "style" || linkAttributes[tag] != nil:
+			selected[tag] = true
+		default:
+			return fmt.Errorf("unsupported tag: %s", tag)
+		}
+	}
+
+	if len(selected) == 0 {
+		selected["a"] = true
+	}
+	enabledTags = selected
+	return nil
 }
 
 // Helper function to extract links from HTML
 func extractLinksFromHTML(doc *html.Node, baseURL *url.URL) ([]string, []string) {
-	var links []string
+	links, malformedURLs := collectHTMLLinks(doc, baseURL)
+	return common.URLs(links), malformedURLs
+}
+
+// Helper function to extract links with their metadata from HTML.
+// Relative links are resolved against the document's <base href> when present,
+// otherwise against baseURL. Meta-refresh targets are included as links.
+// When a CSS selector or XPath scope is set, only the matching subtrees are walked.
+func collectHTMLLinks(doc *html.Node, baseURL *url.URL) ([]common.Link, []string) {
+	var links []common.Link
 	var malformedURLs []string
 
+	baseURL = findBaseURL(doc, baseURL)
+
 	// Use a map to track visited URLs for deduplication
 	visited := make(map[string]bool)
 
+	addLink := func(rawURL string, n *html.Node, attr string) {
+		urlStr, err := resolveLink(rawURL, baseURL)
+		if err != nil {
+			malformedURLs = append(malformedURLs, rawURL)
+			return
+		}
+
+		// Add to links if not already visited
+		if urlStr != "" && !visited[urlStr] {
+			visited[urlStr] = true
+			links = append(links, htmlLink(urlStr, n, attr))
+		}
+	}
+
+	// addCSSLinks adds the url() and @import references of a stylesheet
+	addCSSLinks := func(css string, element, attr string) {
+		for _, link := range collectCSSLinks(css, baseURL, element) {
+			if attr != "" {
+				link.Attribute = attr
+			}
+			if !visited[link.URL] {
+				visited[link.URL] = true
+				links = append(links, link)
+			}
+		}
+	}
+
 	// Use a function to traverse the DOM
 	var traverse func(*html.Node)
 	traverse = func(n *html.Node) {
-		if n.Type == html.ElementNode && n.Data == "a" {
-			for _, a := range n.Attr {
-				if a.Key == "href" {
-					// Convert relative URLs to absolute URLs
-					absoluteURL, err := url.Parse(a.Val)
-					if err != nil {
-						malformedURLs = append(malformedURLs, a.Val)
-						continue
-					}
-
-					absoluteURL = baseURL.ResolveReference(absoluteURL)
-					urlStr := absoluteURL.String()
+		if n.Type == html.ElementNode {
+			if target, ok := metaRefreshTarget(n); ok {
+				addLink(target, n, "content")
+			}
 
-					// Skip javascript: and mailto: links
-					if strings.HasPrefix(urlStr, "javascript:") ||
-						strings.HasPrefix(urlStr, "mailto:") ||
-						strings.HasPrefix(urlStr, "#") {
-						continue
-					}
+			if enabledTags["style"] {
+				if n.Data == "style" {
+					addCSSLinks(nodeText(n), "style", "")
+				}
+				if hasAttribute(n, "style") {
+					addCSSLinks(getAttribute(n, "style"), n.Data, "style")
+				}
+			}
 
-					// Add to links if not already visited
-					if !visited[urlStr] {
-						visited[urlStr] = true
-						links = append(links, urlStr)
+			for _, a := range n.Attr {
+				if a.Key == "srcset" && enabledTags["srcset"] && srcsetElements[n.Data] {
+					for _, candidate := range parseSrcset(a.Val) {
+						addLink(candidate, n, a.Key)
 					}
+					continue
+				}
+				if enabledTags[n.Data] && isLinkAttribute(n.Data, a.Key) {
+					addLink(a.Val, n, a.Key)
 				}
 			}
 		}
@@ -158,14 +395,240 @@ func extractLinksFromHTML(doc *html.Node, baseURL *url.URL) ([]string, []string)
 		}
 	}
 
-	traverse(doc)
+	for _, root := range scopeRoots(doc) {
+		if isAttributeMatch(root) {
+			// An XPath attribute match holds a link as its text
+			urlStr, err := resolveLink(nodeText(root), baseURL)
+			if err != nil {
+				malformedURLs = append(malformedURLs, nodeText(root))
+			} else if urlStr != "" && !visited[urlStr] {
+				visited[urlStr] = true
+				links = append(links, common.Link{URL: urlStr, Attribute: root.Data})
+			}
+			continue
+		}
+		traverse(root)
+	}
 	return links, malformedURLs
 }
 
+// resolveLink converts a link found in HTML to an absolute URL.
+// javascript:, mailto:, inline data: and fragment-only links resolve to "".
+func resolveLink(rawURL string, baseURL *url.URL) (string, error) {
+	absoluteURL, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", err
+	}
+
+	urlStr := baseURL.ResolveReference(absoluteURL).String()
+
+	// Skip javascript:, mailto: and inline data: links
+	if strings.HasPrefix(urlStr, "javascript:") ||
+		strings.HasPrefix(urlStr, "mailto:") ||
+		strings.HasPrefix(urlStr, "data:") ||
+		strings.HasPrefix(urlStr, "#") {
+		return "", nil
+	}
+	return urlStr, nil
+}
+
+// htmlLink builds a link found in the given attribute of an HTML element,
+// using the anchor text, or the alt text for images, as its text.
+func htmlLink(urlStr string, n *html.Node, attr string) common.Link {
+	text := strings.Join(strings.Fields(nodeText(n)), " ")
+	if text == "" {
+		text = getAttribute(n, "alt")
+	}
+
+	return common.Link{
+		URL:       urlStr,
+		Text:      text,
+		Title:     getAttribute(n, "title"),
+		Rel:       getAttribute(n, "rel"),
+		Element:   n.Data,
+		Attribute: attr,
+	}
+}
+
+// findBaseURL returns the URL relative links in doc resolve against.
+// The first <base> element with an href wins, as in browsers; the href itself
+// is resolved against baseURL. If there is no usable <base>, baseURL is returned.
+func findBaseURL(doc *html.Node, baseURL *url.URL) *url.URL {
+	base := findElement(doc, func(n *html.Node) bool {
+		return n.Data == "base" && hasAttribute(n, "href")
+	})
+	if base == nil {
+		return baseURL
+	}
+
+	href, err := url.Parse(strings.TrimSpace(getAttribute(base, "href")))
+	if err != nil {
+		return baseURL
+	}
+	return baseURL.ResolveReference(href)
+}
+
+// findMetaRefresh returns the absolute target of the first meta-refresh element in doc.
+// The second return value is false if the document has no meta refresh with a URL.
+func findMetaRefresh(doc *html.Node, baseURL *url.URL) (string, bool) {
+	var target string
+	findElement(doc, func(n *html.Node) bool {
+		var ok bool
+		target, ok = metaRefreshTarget(n)
+		return ok
+	})
+	if target == "" {
+		return "", false
+	}
+
+	ref, err := url.Parse(target)
+	if err != nil {
+		return "", false
+	}
+	return findBaseURL(doc, baseURL).ResolveReference(ref).String(), true
+}
+
+// metaRefreshTarget returns the URL of a <meta http-equiv="refresh"> element.
+// The content attribute has the form "5; url=target", where the url= prefix
+// and quotes around the target are optional.
+func metaRefreshTarget(n *html.Node) (string, bool) {
+	if n.Type != html.ElementNode || n.Data != "meta" ||
+		!strings.EqualFold(getAttribute(n, "http-equiv"), "refresh") {
+		return "", false
+	}
+
+	// Skip the delay and its separator
+	content := strings.TrimLeft(getAttribute(n, "content"), " \t\n\r\f0123456789.")
+	if content == "" || (content[0] != ';' && content[0] != ',') {
+		return "", false
+	}
+	content = strings.TrimSpace(content[1:])
+
+	// Strip the optional url= prefix
+	if len(content) >= 3 && strings.EqualFold(content[:3], "url") {
+		if rest := strings.TrimSpace(content[3:]); strings.HasPrefix(rest, "=") {
+			content = strings.TrimSpace(rest[1:])
+		}
+	}
+
+	// Strip optional quotes
+	if content != "" && (content[0] == '\'' || content[0] == '"') {
+		quote := content[0]
+		content = content[1:]
+		if i := strings.IndexByte(content, quote); i >= 0 {
+			content = content[:i]
+		}
+	}
+
+	content = strings.TrimSpace(content)
+	return content, content != ""
+}
+
+// findElement returns the first element node in document order for which match returns true.
+func findElement(n *html.Node, match func(*html.Node) bool) *html.Node {
+	if n.Type == html.ElementNode && match(n) {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, match); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// getAttribute returns the value of the named attribute, or "" if it is absent.
+func getAttribute(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// hasAttribute reports whether the node has the named attribute.
+func hasAttribute(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// isLinkAttribute reports whether attr holds a URL on the given element.
+func isLinkAttribute(tag, attr string) bool {
+	for _, candidate := range linkAttributes[tag] {
+		if candidate == attr {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSrcset splits a srcset attribute value into its candidate URLs,
+// dropping the width and density descriptors.
+// Commas inside a URL are kept; a candidate ends at whitespace or at a
+// trailing comma, following the HTML image candidate string rules.
+func parseSrcset(srcset string) []string {
+	var candidates []string
+	rest := srcset
+
+	for {
+		// Skip leading whitespace and separating commas
+		rest = strings.TrimLeft(rest, " \t\n\r\f,")
+		if rest == "" {
+			return candidates
+		}
+
+		// The URL runs until the next whitespace
+		end := strings.IndexAny(rest, " \t\n\r\f")
+		if end == -1 {
+			end = len(rest)
+		}
+		candidate := rest[:end]
+		rest = rest[end:]
+
+		// A URL ending in commas has no descriptors
+		if trimmed := strings.TrimRight(candidate, ","); trimmed != candidate {
+			if trimmed != "" {
+				candidates = append(candidates, trimmed)
+			}
+			continue
+		}
+		candidates = append(candidates, candidate)
+
+		// Skip descriptors up to the next comma outside parentheses
+		depth := 0
+		end = len(rest)
+		for i := 0; i < len(rest) && end == len(rest); i++ {
+			switch rest[i] {
+			case '(':
+				depth++
+			case ')':
+				if depth > 0 {
+					depth--
+				}
+			case ',':
+				if depth == 0 {
+					end = i
+				}
+			}
+		}
+		rest = rest[end:]
+	}
+}
+
 // Helper function to extract links from JSON
 func extractLinksFromJSON(data interface{}) []string {
-	var links []string
-	var extract func(interface{})
+	return common.URLs(collectJSONLinks(data))
+}
+
+// Helper function to extract links from JSON, recording the key each URL was found under
+func collectJSONLinks(data interface{}) []common.Link {
+	var links []common.Link
+	var extract func(interface{}, string)
 
 	// Use a map to track visited URLs for deduplication
 	visited := make(map[string]bool)
@@ -173,15 +636,15 @@ func extractLinksFromJSON(data interface{}) []string {
 	// Define URL regex pattern
 	urlPattern := regexp.MustCompile(`https?://[^\s"']+`)
 
-	extract = func(v interface{}) {
+	extract = func(v interface{}, key string) {
 		switch val := v.(type) {
 		case map[string]interface{}:
-			for _, value := range val {
-				extract(value)
+			for k, value := range val {
+				extract(value, k)
 			}
 		case []interface{}:
 			for _, item := range val {
-				extract(item)
+				extract(item, key)
 			}
 		case string:
 			// Check if string is a URL
@@ -190,14 +653,14 @@ func extractLinksFromJSON(data interface{}) []string {
 				for _, match := range matches {
 					if !visited[match] {
 						visited[match] = true
-						links = append(links, match)
+						links = append(links, common.Link{URL: match, Element: "json", Attribute: key})
 					}
 				}
 			}
 		}
 	}
 
-	extract(data)
+	extract(data, "")
 	return links
 }
 
@@ -216,11 +679,52 @@ func removeDuplicateLinks(links []string) []string {
 	return result
 }
 
+// Helper function to remove links with duplicate URLs, keeping the first occurrence
+func removeDuplicateLinkDetails(links []common.Link) []common.Link {
+	seen := make(map[string]bool)
+	result := []common.Link{}
+
+	for _, link := range links {
+		if !seen[link.URL] {
+			seen[link.URL] = true
+			result = append(result, link)
+		}
+	}
+
+	return result
+}
+
+// Helper function to set the source and 1-based position of each link
+func numberLinks(links []common.Link, source string) []common.Link {
+	for i := range links {
+		links[i].Source = source
+		links[i].Position = i + 1
+	}
+	return links
+}
+
 // ExtractLinksFromFile reads a file and extracts all links from its content.
-// Supports HTML, JSON, and plain text files.
-// The file size is limited to 10MB for safety.
+// It is a convenience wrapper around ReadLinks that returns only the URLs.
 // Returns a slice of unique links found in the file or an error if reading or parsing fails.
 func ExtractLinksFromFile(filePath string) ([]string, error) {
+	links, err := ReadLinks(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return common.URLs(links), nil
+}
+
+// ReadLinks reads a file and extracts all links from its content together with their metadata.
+// Supports HTML, JSON, XML (including RSS and Atom feeds), CSS, Markdown, reStructuredText,
+// AsciiDoc, PDF, DOCX, XLSX, PPTX, EPUB, and plain text files; markup formats are chosen
+// by file extension and documents by their content.
+// Relative links resolve against the URL set with SetBaseURL, or the file's own file:// URL.
+// HTML files are transcoded to UTF-8 from the charset given by their <meta charset>
+// tag or byte order mark. Gzip, brotli and zstd compressed files are decompressed first,
+// up to the decompressed size limit.
+// The file size is limited to 10MB for safety.
+// Returns the unique links found in the file or an error if reading or parsing fails.
+func ReadLinks(filePath string) ([]common.Link, error) {
 	// Check file size before opening to prevent loading large files
 	fileInfo, err := os.Stat(filePath)
 	if err != nil {
@@ -238,47 +742,94 @@ func ExtractLinksFromFile(filePath string) ([]string, error) {
 	}
 	defer func() {
 		if closeErr := file.Close(); closeErr != nil {
-			fmt.Printf("Error closing file: %v\n", closeErr)
+			fmt.Fprintf(os.Stderr, "Error closing file: %v\n", closeErr)
 		}
 	}()
 
-	// Read the first few bytes to detect file type
-	header := make([]byte, 512)
-	_, err = file.Read(header)
-	if err != nil && err != io.EOF {
-		return nil, fmt.Errorf("error reading file header: %w", err)
+	// Read the entire file
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file content: %w", err)
 	}
 
-	// Reset file position
-	_, err = file.Seek(0, 0)
+	// Relative links resolve against the configured base URL or the file itself
+	return extractLinksFromContent(content, filePath, fileURL(filePath))
+}
+
+// ReadLinksFromReader reads a document from r, such as standard input, and extracts
+// its links as ReadLinks does. name is used as each link's Source and its extension,
+// if any, to recognize markup formats. Relative links resolve against the URL set with
+// SetBaseURL and are left relative otherwise.
+// The content is limited to 10MB for safety.
+func ReadLinksFromReader(r io.Reader, name string) ([]common.Link, error) {
+	content, err := io.ReadAll(io.LimitReader(r, common.MaxContentSize+1))
 	if err != nil {
-		return nil, fmt.Errorf("error resetting file position: %w", err)
+		return nil, fmt.Errorf("error reading %s: %w", name, err)
+	}
+	if int64(len(content)) > common.MaxContentSize {
+		return nil, fmt.Errorf("%s is too large. Maximum size is 10MB", name)
 	}
 
-	// Detect content type
-	contentType := http.DetectContentType(header)
+	baseURL := fileBaseURL
+	if baseURL == nil {
+		baseURL = &url.URL{}
+	}
+	return extractLinksFromContent(content, name, baseURL)
+}
 
-	// Read the entire file
-	content, err := io.ReadAll(file)
+// Helper function to report whether content is a JSON object or array
+func isJSONDocument(content []byte) bool {
+	trimmed := bytes.TrimSpace(content)
+	return (bytes.HasPrefix(trimmed, []byte("{")) || bytes.HasPrefix(trimmed, []byte("["))) &&
+		json.Valid(trimmed)
+}
+
+// Helper function to extract the links from the content of a file or stream,
+// choosing the parser by the extension of name and the detected content type
+func extractLinksFromContent(content []byte, name string, baseURL *url.URL) ([]common.Link, error) {
+	// Decompress .gz, .br and .zst files, such as gzipped pages and sitemaps
+	content, err := decompressIfCompressed(content, name)
 	if err != nil {
-		return nil, fmt.Errorf("error reading file content: %w", err)
+		return nil, err
 	}
 
-	var links []string
+	// Detect content type
+	contentType := http.DetectContentType(content)
+
+	// JSON is sniffed as plain text, so recognize objects and arrays that parse
+	if strings.HasPrefix(contentType, "text/plain") && isJSONDocument(content) {
+		contentType = "application/json"
+	}
+
+	var links []common.Link
 
 	// Process based on content type
-	if strings.Contains(contentType, "text/html") {
+	if format := documentFormat(content); format != "" {
+		// PDF, Office and EPUB documents are recognized by their content
+		links, err = collectDocumentLinks(content, format, baseURL)
+		if err != nil {
+			return nil, err
+		}
+
+	} else if format, ok := markupFormats[strings.ToLower(fileExtension(name))]; ok {
+		// Documentation markup is sniffed as plain text, so recognize it by extension
+		links = collectMarkupLinks(string(content), format, baseURL)
+
+	} else if strings.Contains(contentType, "text/html") {
+		// Transcode to UTF-8 using the BOM or <meta charset> of the file
+		content, err = decodeHTML(content, "")
+		if err != nil {
+			return nil, err
+		}
+
 		// Parse HTML
 		doc, err := html.Parse(bytes.NewReader(content))
 		if err != nil {
 			return nil, fmt.Errorf("error parsing HTML: %w", err)
 		}
 
-		// Create a base URL for resolving relative links
-		baseURL, _ := url.Parse("file://" + filePath)
-
 		// Extract links
-		links, _ = extractLinksFromHTML(doc, baseURL)
+		links, _ = collectHTMLLinks(doc, baseURL)
 
 	} else if strings.Contains(contentType, "application/json") {
 		// Parse JSON
@@ -288,26 +839,155 @@ func ExtractLinksFromFile(filePath string) ([]string, error) {
 		}
 
 		// Extract links from JSON
-		links = extractLinksFromJSON(jsonData)
+		links = selectJSONLinks(jsonData, baseURL)
+
+	} else if strings.Contains(contentType, "text/xml") {
+		// Parse RSS, Atom or generic XML
+		links, err = collectXMLLinks(bytes.NewReader(content), baseURL)
+		if err != nil {
+			return nil, err
+		}
+
+	} else if strings.EqualFold(fileExtension(name), ".css") {
+		// Stylesheets are sniffed as plain text, so recognize them by extension
+		links = collectCSSLinks(string(content), baseURL, "css")
 
 	} else if strings.Contains(contentType, "text/plain") {
 		// For plain text, look for URLs using regex
-		urlPattern := regexp.MustCompile(`https?://[^\s"']+`)
-		matches := urlPattern.FindAllString(string(content), -1)
+		matches := bareURLPattern.FindAllString(string(content), -1)
 
-		// Remove duplicates
+		// Remove duplicates and trailing punctuation
 		seen := make(map[string]bool)
 		for _, match := range matches {
+			match = trimBareURL(match)
 			if !seen[match] {
 				seen[match] = true
-				links = append(links, match)
+				links = append(links, common.Link{URL: match, Element: "text"})
 			}
 		}
 	} else {
 		return nil, fmt.Errorf("unsupported file type: %s", contentType)
 	}
 
-	return links, nil
+	return numberLinks(links, name), nil
+}
+
+// FilterLinks filters links using a regular expression pattern matched against their URLs.
+// Only links that match the pattern are returned, with their metadata intact.
+// Returns an error if the regex pattern is invalid.
+func FilterLinks(links []common.Link, regex string) ([]common.Link, error) {
+	re, err := regexp.Compile(regex)
+	if err != nil {
+		return nil, err
+	}
+
+	var filteredLinks []common.Link
+	for _, link := range links {
+		if re.MatchString(link.URL) {
+			filteredLinks = append(filteredLinks, link)
+		}
+	}
+
+	return filteredLinks, nil
+}
+
+// FilterLinksByText filters links using a regular expression pattern matched against
+// their anchor text and title. Only links where either matches are returned.
+// Returns an error if the regex pattern is invalid.
+func FilterLinksByText(links []common.Link, regex string) ([]common.Link, error) {
+	re, err := regexp.Compile(regex)
+	if err != nil {
+		return nil, err
+	}
+
+	var filteredLinks []common.Link
+	for _, link := range links {
+		if re.MatchString(link.Text) || re.MatchString(link.Title) {
+			filteredLinks = append(filteredLinks, link)
+		}
+	}
+
+	return filteredLinks, nil
+}
+
+// FilterLinksBySize returns the links whose listed size lies within [minSize, maxSize].
+// A zero or negative bound disables that check; directories and links with an
+// unknown size are excluded whenever a bound is set.
+func FilterLinksBySize(links []common.Link, minSize, maxSize int64) []common.Link {
+	if minSize <= 0 && maxSize <= 0 {
+		return links
+	}
+
+	var filteredLinks []common.Link
+	for _, link := range links {
+		if link.IsDir || link.Size <= 0 ||
+			(minSize > 0 && link.Size < minSize) ||
+			(maxSize > 0 && link.Size > maxSize) {
+			continue
+		}
+		filteredLinks = append(filteredLinks, link)
+	}
+
+	return filteredLinks
+}
+
+// FilterLinksByDate returns the links whose modification time lies within [since, until].
+// A zero time disables that check; links with an unknown time are excluded
+// whenever a bound is set.
+func FilterLinksByDate(links []common.Link, since, until time.Time) []common.Link {
+	if since.IsZero() && until.IsZero() {
+		return links
+	}
+
+	var filteredLinks []common.Link
+	for _, link := range links {
+		if link.Modified.IsZero() ||
+			(!since.IsZero() && link.Modified.Before(since)) ||
+			(!until.IsZero() && link.Modified.After(until)) {
+			continue
+		}
+		filteredLinks = append(filteredLinks, link)
+	}
+
+	return filteredLinks
+}
+
+// SortLinks sorts links in place by "name", "size" or "date".
+// Names are the link text, falling back to the URL; directories sort before
+// files when sorting by name, as in ls.
+// Returns an error if the sort key is not recognized.
+func SortLinks(links []common.Link, by string, reverse bool) error {
+	name := func(link common.Link) string {
+		if link.Text != "" {
+			return link.Text
+		}
+		return link.URL
+	}
+
+	var less func(a, b common.Link) bool
+	switch strings.ToLower(by) {
+	case "name":
+		less = func(a, b common.Link) bool {
+			if a.IsDir != b.IsDir {
+				return a.IsDir
+			}
+			return name(a) < name(b)
+		}
+	case "size":
+		less = func(a, b common.Link) bool { return a.Size < b.Size }
+	case "date":
+		less = func(a, b common.Link) bool { return a.Modified.Before(b.Modified) }
+	default:
+		return fmt.Errorf("invalid sort key: %s (valid keys: name, size, date)", by)
+	}
+
+	sort.SliceStable(links, func(i, j int) bool {
+		if reverse {
+			return less(links[j], links[i])
+		}
+		return less(links[i], links[j])
+	})
+	return nil
 }
 
 // FilterLinksByRegex filters a slice of links using a regular expression pattern.
@@ -340,6 +1020,41 @@ func PrintLinksAsJSON(links []string) {
 	fmt.Println(string(data))
 }
 
+// PrintLinkDetailsAsJSON prints the links with their metadata as a JSON array of objects to stdout.
+// If JSON marshaling fails, an error message is printed.
+func PrintLinkDetailsAsJSON(links []common.Link) {
+	data, err := json.Marshal(links)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Println(string(data))
+}
+
+// PrintLinksAsLong prints the links in an `ls -l`-like format to stdout.
+// Each line shows the type, size, modification time and URL of a link;
+// unknown values are shown as "-".
+func PrintLinksAsLong(links []common.Link) {
+	for _, link := range links {
+		kind := "-"
+		if link.IsDir {
+			kind = "d"
+		}
+
+		size := "-"
+		if link.Size > 0 {
+			size = fmt.Sprintf("%d", link.Size)
+		}
+
+		modified := "-"
+		if !link.Modified.IsZero() {
+			modified = link.Modified.Format("2006-01-02 15:04")
+		}
+
+		fmt.Printf("%s %12s %16s %s\n", kind, size, modified, link.URL)
+	}
+}
+
 // PrintLinksAsNumbered prints the links as a numbered list to stdout.
 // Each link is prefixed with its position number in the list.
 func PrintLinksAsNumbered(links []string) {
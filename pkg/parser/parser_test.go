@@ -2,13 +2,19 @@ package parser
 
 import (
 	"bytes"
+	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"golang.org/x/net/html"
+
+	"github.com/hemzaz/lsweb/pkg/common"
 )
 
 func TestRemoveDuplicateLinks(t *testing.T) {
@@ -313,3 +319,392 @@ func TestExtractLinksFromFile(t *testing.T) {
 		t.Errorf("Expected error for non-existent file, got nil")
 	}
 }
+
+func TestParseSrcset(t *testing.T) {
+	tests := []struct {
+		name     string
+		srcset   string
+		expected []string
+	}{
+		{
+			name:     "Single candidate",
+			srcset:   "image.png",
+			expected: []string{"image.png"},
+		},
+		{
+			name:     "Width descriptors",
+			srcset:   "small.jpg 480w, large.jpg 1080w",
+			expected: []string{"small.jpg", "large.jpg"},
+		},
+		{
+			name:     "Density descriptors without spaces after commas",
+			srcset:   "a.png 1x,b.png 2x",
+			expected: []string{"a.png", "b.png"},
+		},
+		{
+			name:     "Comma inside URL",
+			srcset:   "https://cdn.example.com/img/w_100,h_100/a.jpg 1x, https://cdn.example.com/img/w_200,h_200/a.jpg 2x",
+			expected: []string{"https://cdn.example.com/img/w_100,h_100/a.jpg", "https://cdn.example.com/img/w_200,h_200/a.jpg"},
+		},
+		{
+			name:     "Trailing comma without descriptor",
+			srcset:   "a.png, b.png 2x",
+			expected: []string{"a.png", "b.png"},
+		},
+		{
+			name:     "Empty",
+			srcset:   "  ",
+			expected: nil,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result := parseSrcset(tc.srcset)
+
+			if len(result) != len(tc.expected) {
+				t.Fatalf("Expected %d candidates, got %d: %v", len(tc.expected), len(result), result)
+			}
+
+			for i, candidate := range result {
+				if candidate != tc.expected[i] {
+					t.Errorf("Expected %s at position %d, got %s", tc.expected[i], i, candidate)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractLinksFromHTMLWithTags(t *testing.T) {
+	htmlContent := `
+<html>
+<head>
+    <link rel="stylesheet" href="/style.css">
+    <script src="app.js"></script>
+</head>
+<body>
+    <a href="/page">Page</a>
+    <img src="logo.png" srcset="logo-1x.png 1x, logo-2x.png 2x">
+    <img src="data:image/png;base64,AAAA">
+    <video src="movie.mp4" poster="poster.jpg"></video>
+    <object data="doc.pdf"></object>
+</body>
+</html>
+`
+	baseURL, _ := url.Parse("https://example.com/dir/")
+
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		t.Fatalf("Failed to parse HTML: %v", err)
+	}
+
+	// Restore the default tag set after the test
+	defer func() {
+		_ = SetTags(nil)
+	}()
+
+	if err := SetTags([]string{"img", "srcset"}); err != nil {
+		t.Fatalf("SetTags failed: %v", err)
+	}
+
+	links, _ := extractLinksFromHTML(doc, baseURL)
+	expected := []string{
+		"https://example.com/dir/logo.png",
+		"https://example.com/dir/logo-1x.png",
+		"https://example.com/dir/logo-2x.png",
+	}
+	if len(links) != len(expected) {
+		t.Fatalf("Expected %d links, got %d: %v", len(expected), len(links), links)
+	}
+	for i, link := range links {
+		if link != expected[i] {
+			t.Errorf("Expected %s at position %d, got %s", expected[i], i, link)
+		}
+	}
+
+	if err := SetTags([]string{"all"}); err != nil {
+		t.Fatalf("SetTags failed: %v", err)
+	}
+
+	links, _ = extractLinksFromHTML(doc, baseURL)
+	if len(links) != 9 {
+		t.Errorf("Expected 9 links with all tags, got %d: %v", len(links), links)
+	}
+
+	if err := SetTags([]string{"blink"}); err == nil {
+		t.Error("Expected error for unsupported tag, got nil")
+	}
+}
+
+func TestExtractLinksFromHTMLWithBase(t *testing.T) {
+	htmlContent := `
+<html>
+<head>
+    <base href="/files/">
+    <meta http-equiv="Refresh" content="0; URL='next.html'">
+</head>
+<body>
+    <a href="a.zip">A</a>
+    <a href="../b.zip">B</a>
+    <a href="https://other.example.com/c.zip">C</a>
+</body>
+</html>
+`
+	baseURL, _ := url.Parse("https://example.com/page/index.html")
+
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		t.Fatalf("Failed to parse HTML: %v", err)
+	}
+
+	links, _ := extractLinksFromHTML(doc, baseURL)
+	expected := []string{
+		"https://example.com/files/next.html",
+		"https://example.com/files/a.zip",
+		"https://example.com/b.zip",
+		"https://other.example.com/c.zip",
+	}
+	if len(links) != len(expected) {
+		t.Fatalf("Expected %d links, got %d: %v", len(expected), len(links), links)
+	}
+	for i, link := range links {
+		if link != expected[i] {
+			t.Errorf("Expected %s at position %d, got %s", expected[i], i, link)
+		}
+	}
+}
+
+func TestMetaRefreshTarget(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected string
+		ok       bool
+	}{
+		{content: "0; url=https://example.com/", expected: "https://example.com/", ok: true},
+		{content: "5;URL=next.html", expected: "next.html", ok: true},
+		{content: `0; url="quoted.html"`, expected: "quoted.html", ok: true},
+		{content: "3, other.html", expected: "other.html", ok: true},
+		{content: "10", expected: "", ok: false},
+		{content: "", expected: "", ok: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.content, func(t *testing.T) {
+			node := &html.Node{
+				Type: html.ElementNode,
+				Data: "meta",
+				Attr: []html.Attribute{
+					{Key: "http-equiv", Val: "refresh"},
+					{Key: "content", Val: tc.content},
+				},
+			}
+
+			target, ok := metaRefreshTarget(node)
+			if ok != tc.ok || target != tc.expected {
+				t.Errorf("Expected (%q, %v), got (%q, %v)", tc.expected, tc.ok, target, ok)
+			}
+		})
+	}
+}
+
+func TestExtractLinksFromURLFollowRefresh(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<html><head><meta http-equiv="refresh" content="0; url=/real/"></head></html>`)
+		case "/real/":
+			fmt.Fprint(w, `<html><body><a href="file.tar.gz">file</a></body></html>`)
+		case "/ping":
+			fmt.Fprint(w, `<html><head><meta http-equiv="refresh" content="0; url=/pong"></head></html>`)
+		case "/pong":
+			fmt.Fprint(w, `<html><head><meta http-equiv="refresh" content="0; url=/ping"></head></html>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	// Without following, the refresh target is reported as a link
+	links, err := ExtractLinksFromURL(server.URL+"/", false)
+	if err != nil {
+		t.Fatalf("ExtractLinksFromURL failed: %v", err)
+	}
+	if len(links) != 1 || links[0] != server.URL+"/real/" {
+		t.Errorf("Expected refresh target as only link, got %v", links)
+	}
+
+	SetFollowRefresh(true)
+	defer SetFollowRefresh(false)
+
+	links, err = ExtractLinksFromURL(server.URL+"/", false)
+	if err != nil {
+		t.Fatalf("ExtractLinksFromURL failed: %v", err)
+	}
+	if len(links) != 1 || links[0] != server.URL+"/real/file.tar.gz" {
+		t.Errorf("Expected links from refresh target, got %v", links)
+	}
+
+	if _, err := ExtractLinksFromURL(server.URL+"/ping", false); err == nil {
+		t.Error("Expected error for refresh loop, got nil")
+	}
+}
+
+func TestFetchLinksMetadata(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body>
+<a href="/a.zip" title="Archive" rel="nofollow">Download <b>A</b></a>
+<img src="/logo.png" alt="Logo">
+<a href="/a.zip">Duplicate</a>
+</body></html>`)
+	}))
+	defer server.Close()
+
+	defer func() {
+		_ = SetTags(nil)
+	}()
+	if err := SetTags([]string{"a", "img"}); err != nil {
+		t.Fatalf("SetTags failed: %v", err)
+	}
+
+	links, err := FetchLinks(server.URL+"/page", false)
+	if err != nil {
+		t.Fatalf("FetchLinks failed: %v", err)
+	}
+
+	expected := []common.Link{
+		{URL: server.URL + "/a.zip", Text: "Download A", Title: "Archive", Rel: "nofollow", Element: "a", Attribute: "href", Source: server.URL + "/page", Position: 1},
+		{URL: server.URL + "/logo.png", Text: "Logo", Element: "img", Attribute: "src", Source: server.URL + "/page", Position: 2},
+	}
+	if len(links) != len(expected) {
+		t.Fatalf("Expected %d links, got %d: %+v", len(expected), len(links), links)
+	}
+	for i, link := range links {
+		if link != expected[i] {
+			t.Errorf("Expected %+v at position %d, got %+v", expected[i], i, link)
+		}
+	}
+
+	filtered, err := FilterLinksByText(links, "^Logo$")
+	if err != nil {
+		t.Fatalf("FilterLinksByText failed: %v", err)
+	}
+	if len(filtered) != 1 || filtered[0].Element != "img" {
+		t.Errorf("Expected only the image link, got %+v", filtered)
+	}
+}
+
+func TestSortAndFilterLinks(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+	links := []common.Link{
+		{URL: "https://example.com/b.iso", Text: "b.iso", Size: 300, Modified: day(3)},
+		{URL: "https://example.com/a.iso", Text: "a.iso", Size: 100, Modified: day(1)},
+		{URL: "https://example.com/sub/", Text: "sub", Modified: day(2), IsDir: true},
+		{URL: "https://example.com/c.iso", Text: "c.iso", Size: 200, Modified: day(2)},
+	}
+
+	if err := SortLinks(links, "name", false); err != nil {
+		t.Fatalf("SortLinks failed: %v", err)
+	}
+	names := []string{"sub", "a.iso", "b.iso", "c.iso"}
+	for i, link := range links {
+		if link.Text != names[i] {
+			t.Errorf("Sort by name: expected %s at position %d, got %s", names[i], i, link.Text)
+		}
+	}
+
+	if err := SortLinks(links, "size", true); err != nil {
+		t.Fatalf("SortLinks failed: %v", err)
+	}
+	if links[0].Text != "b.iso" || links[len(links)-1].Text != "sub" {
+		t.Errorf("Sort by size reversed: unexpected order %+v", links)
+	}
+
+	if err := SortLinks(links, "color", false); err == nil {
+		t.Error("Expected error for invalid sort key, got nil")
+	}
+
+	if filtered := FilterLinksBySize(links, 150, 0); len(filtered) != 2 {
+		t.Errorf("Expected 2 links of at least 150 bytes, got %d", len(filtered))
+	}
+
+	if filtered := FilterLinksByDate(links, day(2), day(2)); len(filtered) != 2 {
+		t.Errorf("Expected 2 links modified on day 2, got %d", len(filtered))
+	}
+
+	if filtered := FilterLinksByDate(links, time.Time{}, time.Time{}); len(filtered) != len(links) {
+		t.Errorf("Expected no filtering without bounds, got %d links", len(filtered))
+	}
+}
+
+func TestReadLinksFromReader(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		base     string
+		expected []string
+	}{
+		{
+			name:     "HTML",
+			content:  `<html><body><a href="https://example.com/a">A</a><a href="/b">B</a></body></html>`,
+			expected: []string{"https://example.com/a", "/b"},
+		},
+		{
+			name:     "HTML with base URL",
+			content:  `<html><body><a href="https://example.com/a">A</a><a href="/b">B</a></body></html>`,
+			base:     "https://mirror.example.org/pub/",
+			expected: []string{"https://example.com/a", "https://mirror.example.org/b"},
+		},
+		{
+			name:     "JSON",
+			content:  `{"assets": [{"url": "https://example.com/app.zip"}], "note": "see https://example.com/docs"}`,
+			expected: []string{"https://example.com/app.zip", "https://example.com/docs"},
+		},
+		{
+			name:     "Plain text",
+			content:  "Mirror list:\nhttps://m1.example.com/pub/\nhttps://m2.example.com/pub/\n",
+			expected: []string{"https://m1.example.com/pub/", "https://m2.example.com/pub/"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := SetBaseURL(tt.base); err != nil {
+				t.Fatalf("SetBaseURL failed: %v", err)
+			}
+			defer SetBaseURL("")
+
+			links, err := ReadLinksFromReader(strings.NewReader(tt.content), "stdin")
+			if err != nil {
+				t.Fatalf("ReadLinksFromReader failed: %v", err)
+			}
+
+			urls := common.URLs(links)
+			if len(urls) != len(tt.expected) {
+				t.Fatalf("Expected %v, got %v", tt.expected, urls)
+			}
+			for _, expected := range tt.expected {
+				found := false
+				for _, u := range urls {
+					found = found || u == expected
+				}
+				if !found {
+					t.Errorf("Expected %s in %v", expected, urls)
+				}
+			}
+			for _, link := range links {
+				if link.Source != "stdin" {
+					t.Errorf("Expected source stdin, got %q", link.Source)
+				}
+			}
+		})
+	}
+}
+
+func TestReadLinksFromReaderTooLarge(t *testing.T) {
+	content := strings.NewReader(strings.Repeat("a", common.MaxContentSize+1))
+	if _, err := ReadLinksFromReader(content, "stdin"); err == nil {
+		t.Error("Expected an error for content over the size limit")
+	}
+}
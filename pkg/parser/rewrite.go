@@ -0,0 +1,151 @@
+package parser
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// RewriteHTMLLinks returns body with every link in it passed through rewrite: the
+// URL attributes of the supported elements, srcset candidates, and url() and @import
+// references in <style> blocks and style attributes, whatever the tag settings.
+// rewrite receives each link resolved against the document's <base href> or baseURL
+// and returns the value to store in its place. <base href> elements are removed,
+// since the rewritten links no longer depend on them. javascript:, mailto:, data: and
+// fragment-only links are left as they are, and so is everything outside the
+// rewritten tags, byte for byte.
+func RewriteHTMLLinks(body []byte, baseURL *url.URL, rewrite func(string) string) []byte {
+	var out bytes.Buffer
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	inStyle := false
+	baseSeen := false
+
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			// Reading from memory only stops at the end of the document
+			out.Write(tokenizer.Raw())
+			return out.Bytes()
+		}
+
+		// Token unescapes attributes in place, so the raw bytes are copied first
+		raw := append([]byte(nil), tokenizer.Raw()...)
+
+		switch tokenType {
+		case html.TextToken:
+			if inStyle {
+				out.WriteString(RewriteCSSLinks(string(raw), baseURL, rewrite))
+				continue
+			}
+
+		case html.EndTagToken:
+			if name, _ := tokenizer.TagName(); string(name) == "style" {
+				inStyle = false
+			}
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			inStyle = token.Data == "style" && tokenType == html.StartTagToken
+
+			// The first <base href> applies to the links that follow it
+			n := &html.Node{Type: html.ElementNode, Data: token.Data, Attr: token.Attr}
+			if n.Data == "base" && hasAttribute(n, "href") {
+				if !baseSeen {
+					baseSeen = true
+					if href, err := url.Parse(strings.TrimSpace(getAttribute(n, "href"))); err == nil {
+						baseURL = baseURL.ResolveReference(href)
+					}
+				}
+				continue
+			}
+
+			changed := false
+			for i, a := range token.Attr {
+				value := a.Val
+				switch {
+				case a.Key == "srcset" && srcsetElements[token.Data]:
+					value = rewriteSrcset(a.Val, baseURL, rewrite)
+				case a.Key == "style":
+					value = RewriteCSSLinks(a.Val, baseURL, rewrite)
+				case isLinkAttribute(token.Data, a.Key):
+					value = rewriteLink(a.Val, baseURL, rewrite)
+				}
+				if value != a.Val {
+					token.Attr[i].Val = value
+					changed = true
+				}
+			}
+			if changed {
+				out.WriteString(token.String())
+				continue
+			}
+		}
+
+		out.Write(raw)
+	}
+}
+
+// RewriteCSSLinks returns a stylesheet with its url() and @import references passed
+// through rewrite, resolved against baseURL, which should be the stylesheet's own URL.
+// data: and fragment-only references are left as they are.
+func RewriteCSSLinks(css string, baseURL *url.URL, rewrite func(string) string) string {
+	return cssReferencePattern.ReplaceAllStringFunc(css, func(reference string) string {
+		match := cssReferencePattern.FindStringSubmatch(reference)
+
+		raw := ""
+		for _, value := range match[2:] {
+			if value != "" {
+				raw = value
+				break
+			}
+		}
+		value := strings.TrimSpace(unescapeCSS(raw))
+		if value == "" {
+			return reference
+		}
+
+		rewritten := rewriteLink(value, baseURL, rewrite)
+		if rewritten == value {
+			return reference
+		}
+		quoted := `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(rewritten) + `"`
+		if match[5] != "" || match[6] != "" {
+			return "@import " + quoted
+		}
+		return match[1] + "url(" + quoted + ")"
+	})
+}
+
+// Helper function to pass one link through rewrite, returning it unchanged if it
+// is malformed or not a link to a resource
+func rewriteLink(rawURL string, baseURL *url.URL, rewrite func(string) string) string {
+	// Fragment-only links stay within the document wherever it is saved
+	if strings.HasPrefix(strings.TrimSpace(rawURL), "#") {
+		return rawURL
+	}
+	urlStr, err := resolveLink(rawURL, baseURL)
+	if err != nil || urlStr == "" {
+		return rawURL
+	}
+	return rewrite(urlStr)
+}
+
+// Helper function to pass the candidate URLs of a srcset attribute through rewrite,
+// keeping their descriptors and separators
+func rewriteSrcset(srcset string, baseURL *url.URL, rewrite func(string) string) string {
+	var out strings.Builder
+	rest := srcset
+	for _, candidate := range parseSrcset(srcset) {
+		i := strings.Index(rest, candidate)
+		if i < 0 {
+			break
+		}
+		out.WriteString(rest[:i])
+		out.WriteString(rewriteLink(candidate, baseURL, rewrite))
+		rest = rest[i+len(candidate):]
+	}
+	out.WriteString(rest)
+	return out.String()
+}
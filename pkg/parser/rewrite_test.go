@@ -0,0 +1,95 @@
+package parser
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// rewriteForTest maps the links of example.com to local-style names and leaves
+// other links absolute
+func rewriteForTest(link string) string {
+	if rest, ok := strings.CutPrefix(link, "https://example.com/"); ok {
+		return "local/" + rest
+	}
+	return link
+}
+
+func TestRewriteHTMLLinks(t *testing.T) {
+	baseURL, _ := url.Parse("https://example.com/docs/page.html")
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Anchor and image",
+			input:    `<p class=x><a href="guide.html">Guide</a><img src="/logo.png" alt="Logo"></p>`,
+			expected: `<p class=x><a href="local/docs/guide.html">Guide</a><img src="local/logo.png" alt="Logo"></p>`,
+		},
+		{
+			name:     "Other hosts become absolute",
+			input:    `<a href="//cdn.example.org/a.js">CDN</a>`,
+			expected: `<a href="https://cdn.example.org/a.js">CDN</a>`,
+		},
+		{
+			name:     "Special links are kept",
+			input:    `<a href="#top">Top</a><a href="mailto:me@example.com">Mail</a><a href="javascript:void(0)">JS</a>`,
+			expected: `<a href="#top">Top</a><a href="mailto:me@example.com">Mail</a><a href="javascript:void(0)">JS</a>`,
+		},
+		{
+			name:     "Srcset keeps descriptors",
+			input:    `<img srcset="a.png 1x, /b.png 2x">`,
+			expected: `<img srcset="local/docs/a.png 1x, local/b.png 2x">`,
+		},
+		{
+			name:     "Style block and attribute",
+			input:    `<style>body { background: url('bg.png') }</style><div style="background: url(/x.png)"></div>`,
+			expected: `<style>body { background: url("local/docs/bg.png") }</style><div style="background: url(&#34;local/x.png&#34;)"></div>`,
+		},
+		{
+			name:     "Base element is removed",
+			input:    `<head><base href="/files/"></head><a href="app.zip">App</a>`,
+			expected: `<head></head><a href="local/files/app.zip">App</a>`,
+		},
+		{
+			name:     "Untouched markup is kept byte for byte",
+			input:    "<!DOCTYPE html>\n<!-- comment --><DIV  id='x'>Text &amp; more</DIV>",
+			expected: "<!DOCTYPE html>\n<!-- comment --><DIV  id='x'>Text &amp; more</DIV>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := string(RewriteHTMLLinks([]byte(tt.input), baseURL, rewriteForTest))
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestRewriteCSSLinks(t *testing.T) {
+	baseURL, _ := url.Parse("https://example.com/css/site.css")
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"Unquoted url", `a { background: url(img/a.png) }`, `a { background: url("local/css/img/a.png") }`},
+		{"Import string", `@import 'base.css';`, `@import "local/css/base.css";`},
+		{"Import url", `@import url("/theme.css") screen;`, `@import url("local/theme.css") screen;`},
+		{"Data URL is kept", `a { background: url(data:image/png;base64,AAAA) }`, `a { background: url(data:image/png;base64,AAAA) }`},
+		{"Fragment is kept", `a { filter: url(#blur) }`, `a { filter: url(#blur) }`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := RewriteCSSLinks(tt.input, baseURL, rewriteForTest); result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
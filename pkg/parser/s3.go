@@ -0,0 +1,217 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hemzaz/lsweb/pkg/common"
+)
+
+// maxS3Pages limits how many ListObjects pages are fetched for one bucket listing
+var maxS3Pages = 1000
+
+// SetMaxS3Pages sets the maximum number of ListObjects pages fetched for one bucket listing.
+func SetMaxS3Pages(max int) {
+	if max > 0 {
+		maxS3Pages = max
+	}
+}
+
+// S3Object is an object or common prefix in an S3-compatible bucket listing.
+// Prefixes returned when listing with a delimiter have IsDir set and a Size of -1.
+type S3Object struct {
+	Key          string    `json:"key"`
+	URL          string    `json:"url"`
+	Size         int64     `json:"size"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified time.Time `json:"last_modified"`
+	StorageClass string    `json:"storage_class,omitempty"`
+	IsDir        bool      `json:"is_dir,omitempty"`
+}
+
+// Link converts the object to a link carrying its key, size, ETag and modification time.
+func (o S3Object) Link() common.Link {
+	link := common.Link{
+		URL:      o.URL,
+		Text:     o.Key,
+		Element:  "Contents",
+		Modified: o.LastModified,
+		ETag:     o.ETag,
+		IsDir:    o.IsDir,
+	}
+	if o.IsDir {
+		link.Element = "CommonPrefixes"
+	} else {
+		link.Size = o.Size
+	}
+	return link
+}
+
+// s3ListBucketResult is a ListObjectsV2 (or V1) response page
+type s3ListBucketResult struct {
+	XMLName               xml.Name `xml:"ListBucketResult"`
+	IsTruncated           bool     `xml:"IsTruncated"`
+	NextContinuationToken string   `xml:"NextContinuationToken"`
+	NextMarker            string   `xml:"NextMarker"`
+	Contents              []struct {
+		Key          string `xml:"Key"`
+		Size         int64  `xml:"Size"`
+		ETag         string `xml:"ETag"`
+		LastModified string `xml:"LastModified"`
+		StorageClass string `xml:"StorageClass"`
+	} `xml:"Contents"`
+	CommonPrefixes []struct {
+		Prefix string `xml:"Prefix"`
+	} `xml:"CommonPrefixes"`
+}
+
+// ExtractS3Objects lists an S3-compatible bucket (AWS S3, MinIO, GCS XML API) with
+// ListObjectsV2, following continuation tokens until the listing is complete or
+// maxS3Pages pages have been fetched. Query parameters such as prefix and delimiter
+// in bucketURL are preserved. Each key is returned with its download URL, size,
+// ETag and LastModified.
+// The ignoreCert parameter can be used to skip TLS certificate validation.
+// Returns an error if a page cannot be fetched or the response is not a bucket listing.
+func ExtractS3Objects(bucketURL string, ignoreCert bool) ([]S3Object, error) {
+	listURL, err := url.Parse(bucketURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+
+	query := listURL.Query()
+	if query.Get("list-type") == "" {
+		query.Set("list-type", "2")
+		listURL.RawQuery = query.Encode()
+	}
+
+	body, _, err := fetchDocument(listURL.String(), ignoreCert)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isS3Listing(body) {
+		return nil, fmt.Errorf("%s is not an S3 bucket listing", bucketURL)
+	}
+
+	return expandS3Listing(body, listURL, ignoreCert)
+}
+
+// Helper function to report whether an XML body is a ListBucketResult document
+func isS3Listing(body []byte) bool {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.Strict = false
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return false
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local == "ListBucketResult"
+		}
+	}
+}
+
+// Helper function to collect objects from a first listing page and fetch the
+// remaining pages. ListObjectsV2 pages continue with continuation-token;
+// V1 pages continue with marker set to NextMarker or the last key.
+func expandS3Listing(body []byte, listURL *url.URL, ignoreCert bool) ([]S3Object, error) {
+	var objects []S3Object
+
+	// Object URLs are relative to the bucket root, without the listing query
+	bucketRoot := *listURL
+	bucketRoot.RawQuery = ""
+	bucketRoot.Fragment = ""
+	if !strings.HasSuffix(bucketRoot.Path, "/") {
+		bucketRoot.Path += "/"
+		bucketRoot.RawPath = ""
+	}
+
+	seenTokens := make(map[string]bool)
+
+	for page := 1; ; page++ {
+		var result s3ListBucketResult
+		if err := xml.Unmarshal(body, &result); err != nil {
+			return nil, fmt.Errorf("error parsing bucket listing: %w", err)
+		}
+
+		for _, prefix := range result.CommonPrefixes {
+			objects = append(objects, S3Object{
+				Key:   prefix.Prefix,
+				URL:   s3ObjectURL(&bucketRoot, prefix.Prefix),
+				Size:  -1,
+				IsDir: true,
+			})
+		}
+
+		lastKey := ""
+		for _, content := range result.Contents {
+			lastModified, _ := time.Parse(time.RFC3339, strings.TrimSpace(content.LastModified))
+			objects = append(objects, S3Object{
+				Key:          content.Key,
+				URL:          s3ObjectURL(&bucketRoot, content.Key),
+				Size:         content.Size,
+				ETag:         strings.Trim(content.ETag, `"`),
+				LastModified: lastModified,
+				StorageClass: content.StorageClass,
+			})
+			lastKey = content.Key
+		}
+
+		if !result.IsTruncated {
+			return objects, nil
+		}
+
+		// Build the request for the next page
+		nextURL := *listURL
+		query := nextURL.Query()
+		token := ""
+		switch {
+		case result.NextContinuationToken != "":
+			token = result.NextContinuationToken
+			query.Set("continuation-token", token)
+		case result.NextMarker != "":
+			token = result.NextMarker
+			query.Set("marker", token)
+		case lastKey != "":
+			token = lastKey
+			query.Set("marker", token)
+		default:
+			return nil, fmt.Errorf("bucket listing is truncated but has no continuation token")
+		}
+		nextURL.RawQuery = query.Encode()
+
+		if seenTokens[token] {
+			return nil, fmt.Errorf("bucket listing repeated continuation token %q", token)
+		}
+		seenTokens[token] = true
+
+		if page >= maxS3Pages {
+			fmt.Fprintf(os.Stderr, "Warning: bucket listing page limit of %d reached, results are incomplete\n", maxS3Pages)
+			return objects, nil
+		}
+
+		var err error
+		body, _, err = fetchDocument(nextURL.String(), ignoreCert)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Helper function to build the download URL of a key, escaping each path segment
+func s3ObjectURL(bucketRoot *url.URL, key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	objectURL := *bucketRoot
+	objectURL.Path = bucketRoot.Path + key
+	objectURL.RawPath = bucketRoot.EscapedPath() + strings.Join(segments, "/")
+	return objectURL.String()
+}
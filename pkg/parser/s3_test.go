@@ -0,0 +1,114 @@
+package parser
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestExtractS3Objects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/artifacts" {
+			http.NotFound(w, r)
+			return
+		}
+
+		query := r.URL.Query()
+		if query.Get("list-type") != "2" {
+			t.Errorf("Expected list-type=2, got %q", query.Get("list-type"))
+		}
+		if query.Get("prefix") != "builds/" {
+			t.Errorf("Expected prefix to be preserved, got %q", query.Get("prefix"))
+		}
+
+		w.Header().Set("Content-Type", "application/xml")
+		switch query.Get("continuation-token") {
+		case "":
+			fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?>
+<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Name>artifacts</Name>
+  <Prefix>builds/</Prefix>
+  <IsTruncated>true</IsTruncated>
+  <NextContinuationToken>page-2</NextContinuationToken>
+  <Contents>
+    <Key>builds/app 1.0.tar.gz</Key>
+    <LastModified>2024-03-02T10:30:00.000Z</LastModified>
+    <ETag>"abc123"</ETag>
+    <Size>1024</Size>
+    <StorageClass>STANDARD</StorageClass>
+  </Contents>
+</ListBucketResult>`)
+		case "page-2":
+			fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?>
+<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <IsTruncated>false</IsTruncated>
+  <Contents>
+    <Key>builds/app-2.0.tar.gz</Key>
+    <LastModified>2024-03-03T10:30:00.000Z</LastModified>
+    <ETag>"def456"</ETag>
+    <Size>2048</Size>
+  </Contents>
+</ListBucketResult>`)
+		default:
+			t.Errorf("Unexpected continuation token %q", query.Get("continuation-token"))
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	objects, err := ExtractS3Objects(server.URL+"/artifacts?prefix=builds/", false)
+	if err != nil {
+		t.Fatalf("ExtractS3Objects failed: %v", err)
+	}
+
+	expected := []S3Object{
+		{
+			Key:          "builds/app 1.0.tar.gz",
+			URL:          server.URL + "/artifacts/builds/app%201.0.tar.gz",
+			Size:         1024,
+			ETag:         "abc123",
+			LastModified: time.Date(2024, 3, 2, 10, 30, 0, 0, time.UTC),
+			StorageClass: "STANDARD",
+		},
+		{
+			Key:          "builds/app-2.0.tar.gz",
+			URL:          server.URL + "/artifacts/builds/app-2.0.tar.gz",
+			Size:         2048,
+			ETag:         "def456",
+			LastModified: time.Date(2024, 3, 3, 10, 30, 0, 0, time.UTC),
+		},
+	}
+	if len(objects) != len(expected) {
+		t.Fatalf("Expected %d objects, got %d: %+v", len(expected), len(objects), objects)
+	}
+	for i, object := range objects {
+		if object.Key != expected[i].Key || object.URL != expected[i].URL || object.Size != expected[i].Size ||
+			object.ETag != expected[i].ETag || !object.LastModified.Equal(expected[i].LastModified) ||
+			object.StorageClass != expected[i].StorageClass {
+			t.Errorf("Expected %+v at position %d, got %+v", expected[i], i, object)
+		}
+	}
+
+	// ExtractLinksFromURL pages through bucket listings automatically
+	links, err := ExtractLinksFromURL(server.URL+"/artifacts?list-type=2&prefix=builds/", false)
+	if err != nil {
+		t.Fatalf("ExtractLinksFromURL failed: %v", err)
+	}
+	if len(links) != 2 {
+		t.Errorf("Expected 2 links from bucket listing, got %d: %v", len(links), links)
+	}
+}
+
+func TestExtractS3ObjectsRejectsOtherXML(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		fmt.Fprint(w, `<?xml version="1.0"?><Error><Code>AccessDenied</Code></Error>`)
+	}))
+	defer server.Close()
+
+	if _, err := ExtractS3Objects(server.URL+"/bucket", false); err == nil {
+		t.Error("Expected error for non-listing response, got nil")
+	}
+}
@@ -0,0 +1,81 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xpath"
+	"golang.org/x/net/html"
+)
+
+// scopeSelector limits HTML link extraction to the elements matching a CSS selector
+var scopeSelector cascadia.SelectorGroup
+
+// scopeXPath limits HTML link extraction to the nodes matching an XPath expression
+var scopeXPath *xpath.Expr
+
+// SetSelector limits link extraction from HTML to the subtrees of the elements
+// matching a CSS selector, such as "table#files a" or "main .downloads".
+// An empty selector restores extraction from the whole document.
+func SetSelector(selector string) error {
+	if strings.TrimSpace(selector) == "" {
+		scopeSelector = nil
+		return nil
+	}
+
+	group, err := cascadia.ParseGroup(selector)
+	if err != nil {
+		return fmt.Errorf("invalid CSS selector %q: %w", selector, err)
+	}
+	scopeSelector = group
+	return nil
+}
+
+// SetXPath limits link extraction from HTML to the subtrees of the nodes matching
+// an XPath expression, such as //table[@id='files']. Expressions selecting attributes,
+// such as //div[@class='release']//a/@href, yield the attribute values as links.
+// An empty expression restores extraction from the whole document.
+func SetXPath(expr string) error {
+	if strings.TrimSpace(expr) == "" {
+		scopeXPath = nil
+		return nil
+	}
+
+	compiled, err := xpath.Compile(expr)
+	if err != nil {
+		return fmt.Errorf("invalid XPath %q: %w", expr, err)
+	}
+	scopeXPath = compiled
+	return nil
+}
+
+// Helper function to report whether HTML link extraction is limited by a selector or XPath
+func isHTMLScoped() bool {
+	return scopeSelector != nil || scopeXPath != nil
+}
+
+// Helper function to return the nodes whose subtrees links are extracted from: the whole
+// document, or the matches of the CSS selector followed by those of the XPath expression.
+// XPath attribute matches are returned as detached elements named after the attribute
+// and holding its value as text.
+func scopeRoots(doc *html.Node) []*html.Node {
+	if !isHTMLScoped() {
+		return []*html.Node{doc}
+	}
+
+	var roots []*html.Node
+	if scopeSelector != nil {
+		roots = append(roots, cascadia.QueryAll(doc, scopeSelector)...)
+	}
+	if scopeXPath != nil {
+		roots = append(roots, htmlquery.QuerySelectorAll(doc, scopeXPath)...)
+	}
+	return roots
+}
+
+// Helper function to report whether a scope root is an XPath attribute match rather than a document node
+func isAttributeMatch(n *html.Node) bool {
+	return n.Type == html.ElementNode && n.Parent == nil
+}
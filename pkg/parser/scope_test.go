@@ -0,0 +1,118 @@
+package parser
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+
+	"github.com/hemzaz/lsweb/pkg/common"
+)
+
+const scopeTestPage = `<html><body>
+<nav><a href="/">Home</a><a href="/about">About</a></nav>
+<table id="files">
+  <tr><td><a href="app-1.0.tar.gz">app-1.0.tar.gz</a></td><td><a href="app-1.0.tar.gz.sha256">checksum</a></td></tr>
+  <tr><td><a href="app-1.1.tar.gz">app-1.1.tar.gz</a></td><td><a href="app-1.1.tar.gz.sha256">checksum</a></td></tr>
+</table>
+<div class="ad"><a href="https://ads.example.com/click">Buy now</a></div>
+<footer><a href="/privacy">Privacy</a></footer>
+</body></html>`
+
+func TestCollectHTMLLinksScoped(t *testing.T) {
+	baseURL, _ := url.Parse("https://example.com/pub/")
+	doc, err := html.Parse(strings.NewReader(scopeTestPage))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		selector string
+		xpath    string
+		expected []string
+	}{
+		{
+			name:     "No scope",
+			expected: []string{"https://example.com/", "https://example.com/about", "https://example.com/pub/app-1.0.tar.gz", "https://example.com/pub/app-1.0.tar.gz.sha256", "https://example.com/pub/app-1.1.tar.gz", "https://example.com/pub/app-1.1.tar.gz.sha256", "https://ads.example.com/click", "https://example.com/privacy"},
+		},
+		{
+			name:     "CSS selector",
+			selector: "table#files a",
+			expected: []string{"https://example.com/pub/app-1.0.tar.gz", "https://example.com/pub/app-1.0.tar.gz.sha256", "https://example.com/pub/app-1.1.tar.gz", "https://example.com/pub/app-1.1.tar.gz.sha256"},
+		},
+		{
+			name:     "CSS selector group",
+			selector: "td:first-child, footer",
+			expected: []string{"https://example.com/pub/app-1.0.tar.gz", "https://example.com/pub/app-1.1.tar.gz", "https://example.com/privacy"},
+		},
+		{
+			name:     "XPath",
+			xpath:    "//table[@id='files']",
+			expected: []string{"https://example.com/pub/app-1.0.tar.gz", "https://example.com/pub/app-1.0.tar.gz.sha256", "https://example.com/pub/app-1.1.tar.gz", "https://example.com/pub/app-1.1.tar.gz.sha256"},
+		},
+		{
+			name:     "XPath attribute",
+			xpath:    "//table[@id='files']//td[2]/a/@href",
+			expected: []string{"https://example.com/pub/app-1.0.tar.gz.sha256", "https://example.com/pub/app-1.1.tar.gz.sha256"},
+		},
+		{
+			name:     "No match",
+			selector: "#missing",
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := SetSelector(tt.selector); err != nil {
+				t.Fatalf("SetSelector failed: %v", err)
+			}
+			if err := SetXPath(tt.xpath); err != nil {
+				t.Fatalf("SetXPath failed: %v", err)
+			}
+			defer SetSelector("")
+			defer SetXPath("")
+
+			links, _ := collectHTMLLinks(doc, baseURL)
+			if fmt.Sprint(common.URLs(links)) != fmt.Sprint(tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, common.URLs(links))
+			}
+		})
+	}
+}
+
+func TestScopeErrors(t *testing.T) {
+	if err := SetSelector("table[id="); err == nil {
+		t.Error("Expected an error for an invalid CSS selector")
+	}
+	if err := SetXPath("//table[@id="); err == nil {
+		t.Error("Expected an error for an invalid XPath")
+	}
+	if isHTMLScoped() {
+		t.Error("Expected invalid expressions to leave extraction unscoped")
+	}
+}
+
+func TestStreamLinksFromReaderScoped(t *testing.T) {
+	baseURL, _ := url.Parse("https://example.com/pub/")
+	if err := SetSelector("table#files td:first-child"); err != nil {
+		t.Fatalf("SetSelector failed: %v", err)
+	}
+	defer SetSelector("")
+
+	var links []common.Link
+	err := StreamLinksFromReader(strings.NewReader(scopeTestPage), "page.html", baseURL, func(link common.Link) bool {
+		links = append(links, link)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("StreamLinksFromReader failed: %v", err)
+	}
+
+	if len(links) != 2 || links[1].URL != "https://example.com/pub/app-1.1.tar.gz" || links[1].Position != 2 || links[1].Text != "app-1.1.tar.gz" {
+		t.Errorf("Unexpected links: %+v", links)
+	}
+}
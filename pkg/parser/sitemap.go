@@ -0,0 +1,267 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hemzaz/lsweb/pkg/common"
+)
+
+// maxSitemaps limits how many sitemap documents are fetched when expanding a sitemap index
+var maxSitemaps = 50
+
+// SetMaxSitemaps sets the maximum number of sitemap documents fetched
+// when recursively expanding a sitemap index.
+func SetMaxSitemaps(max int) {
+	if max > 0 {
+		maxSitemaps = max
+	}
+}
+
+// SitemapEntry is a page URL listed in a sitemap together with its optional metadata.
+type SitemapEntry struct {
+	Loc        string `xml:"loc" json:"loc"`
+	LastMod    string `xml:"lastmod" json:"lastmod,omitempty"`
+	ChangeFreq string `xml:"changefreq" json:"changefreq,omitempty"`
+	Priority   string `xml:"priority" json:"priority,omitempty"`
+}
+
+// Link converts the entry to a link, parsing lastmod as a W3C datetime or date.
+func (e SitemapEntry) Link() common.Link {
+	link := common.Link{
+		URL:        e.Loc,
+		Element:    "loc",
+		Priority:   e.Priority,
+		ChangeFreq: e.ChangeFreq,
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04Z07:00", "2006-01-02"} {
+		if modified, err := time.Parse(layout, e.LastMod); err == nil {
+			link.Modified = modified
+			break
+		}
+	}
+	return link
+}
+
+// sitemapDocument is either a <urlset> or a <sitemapindex> document
+type sitemapDocument struct {
+	XMLName  xml.Name
+	URLs     []SitemapEntry `xml:"url"`
+	Sitemaps []SitemapEntry `xml:"sitemap"`
+}
+
+// ExtractSitemapEntries fetches a sitemap or sitemap index (optionally gzip, brotli or zstd compressed)
+// and returns every page entry it lists. Child sitemaps of an index are expanded
+// recursively until maxSitemaps documents have been fetched.
+// The ignoreCert parameter can be used to skip TLS certificate validation.
+// Returns an error if the first document cannot be fetched or is not a sitemap.
+func ExtractSitemapEntries(sitemapURL string, ignoreCert bool) ([]SitemapEntry, error) {
+	body, finalURL, err := fetchDocument(sitemapURL, ignoreCert)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isSitemap(body) {
+		return nil, fmt.Errorf("%s is not a sitemap", sitemapURL)
+	}
+
+	return expandSitemap(body, finalURL, ignoreCert)
+}
+
+// DiscoverSitemaps fetches robots.txt for the host of siteURL and returns
+// the URLs listed on its Sitemap: lines.
+// The ignoreCert parameter can be used to skip TLS certificate validation.
+// Returns an error if robots.txt cannot be fetched.
+func DiscoverSitemaps(siteURL string, ignoreCert bool) ([]string, error) {
+	parsedURL, err := url.Parse(siteURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+
+	robotsURL := &url.URL{Scheme: parsedURL.Scheme, Host: parsedURL.Host, Path: "/robots.txt"}
+	body, finalURL, err := fetchDocument(robotsURL.String(), ignoreCert)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseRobotsSitemaps(body, finalURL), nil
+}
+
+// Helper function to collect Sitemap: lines from a robots.txt body
+func parseRobotsSitemaps(body []byte, baseURL *url.URL) []string {
+	var sitemaps []string
+	seen := make(map[string]bool)
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		key, value, found := strings.Cut(line, ":")
+		if !found || !strings.EqualFold(strings.TrimSpace(key), "sitemap") {
+			continue
+		}
+
+		ref, err := url.Parse(strings.TrimSpace(value))
+		if err != nil || ref.String() == "" {
+			continue
+		}
+
+		sitemap := baseURL.ResolveReference(ref).String()
+		if !seen[sitemap] {
+			seen[sitemap] = true
+			sitemaps = append(sitemaps, sitemap)
+		}
+	}
+
+	return sitemaps
+}
+
+// Helper function to report whether an XML body is a <urlset> or <sitemapindex> document
+func isSitemap(body []byte) bool {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.Strict = false
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return false
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local == "urlset" || start.Name.Local == "sitemapindex"
+		}
+	}
+}
+
+// Helper function to expand a sitemap body into page entries, fetching child
+// sitemaps breadth-first until maxSitemaps documents have been processed.
+// Child sitemaps that fail to load are reported and skipped.
+func expandSitemap(body []byte, baseURL *url.URL, ignoreCert bool) ([]SitemapEntry, error) {
+	var entries []SitemapEntry
+	seenEntries := make(map[string]bool)
+	seenSitemaps := map[string]bool{baseURL.String(): true}
+
+	type pendingSitemap struct {
+		body    []byte
+		baseURL *url.URL
+	}
+	queue := []pendingSitemap{{body: body, baseURL: baseURL}}
+	fetched := 1
+
+	for processed := 0; len(queue) > 0; processed++ {
+		current := queue[0]
+		queue = queue[1:]
+
+		var doc sitemapDocument
+		if err := xml.Unmarshal(current.body, &doc); err != nil {
+			if processed == 0 {
+				return nil, fmt.Errorf("error parsing sitemap: %w", err)
+			}
+			fmt.Fprintf(os.Stderr, "Warning: skipping invalid sitemap %s: %v\n", current.baseURL, err)
+			continue
+		}
+
+		for _, entry := range doc.URLs {
+			entry.Loc = resolveSitemapLoc(entry.Loc, current.baseURL)
+			if entry.Loc != "" && !seenEntries[entry.Loc] {
+				seenEntries[entry.Loc] = true
+				entries = append(entries, trimSitemapEntry(entry))
+			}
+		}
+
+		for _, child := range doc.Sitemaps {
+			childURL := resolveSitemapLoc(child.Loc, current.baseURL)
+			if childURL == "" || seenSitemaps[childURL] {
+				continue
+			}
+			seenSitemaps[childURL] = true
+
+			if fetched >= maxSitemaps {
+				fmt.Fprintf(os.Stderr, "Warning: sitemap limit of %d reached, skipping %s\n", maxSitemaps, childURL)
+				continue
+			}
+			fetched++
+
+			childBody, childBase, err := fetchDocument(childURL, ignoreCert)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: skipping sitemap %s: %v\n", childURL, err)
+				continue
+			}
+			queue = append(queue, pendingSitemap{body: childBody, baseURL: childBase})
+		}
+	}
+
+	return entries, nil
+}
+
+// Helper function to resolve a <loc> value against the sitemap URL
+func resolveSitemapLoc(loc string, baseURL *url.URL) string {
+	ref, err := url.Parse(strings.TrimSpace(loc))
+	if err != nil || ref.String() == "" {
+		return ""
+	}
+	return baseURL.ResolveReference(ref).String()
+}
+
+// Helper function to trim surrounding whitespace from sitemap metadata
+func trimSitemapEntry(entry SitemapEntry) SitemapEntry {
+	entry.LastMod = strings.TrimSpace(entry.LastMod)
+	entry.ChangeFreq = strings.TrimSpace(entry.ChangeFreq)
+	entry.Priority = strings.TrimSpace(entry.Priority)
+	return entry
+}
+
+// Helper function to fetch a document body, transparently decompressing its
+// Content-Encoding and compressed files such as .xml.gz sitemaps. Returns the body and the final URL after redirects.
+func fetchDocument(targetURL string, ignoreCert bool) ([]byte, *url.URL, error) {
+	client := &http.Client{
+		Timeout: common.DefaultTimeout,
+	}
+	if ignoreCert {
+		client.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), common.DefaultTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", targetURL, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("User-Agent", common.UserAgent)
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error fetching %s: %w", targetURL, err)
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			fmt.Fprintf(os.Stderr, "Error closing response body: %v\n", closeErr)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("server returned non-success status: %d %s", resp.StatusCode, resp.Status)
+	}
+
+	body, err := readResponseBody(resp)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	body, err = decompressIfCompressed(body, resp.Request.URL.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return body, resp.Request.URL, nil
+}
@@ -0,0 +1,124 @@
+package parser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestExtractSitemapEntries(t *testing.T) {
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	fmt.Fprint(gz, `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>https://example.com/blog/1</loc><lastmod>2024-01-02</lastmod></url>
+</urlset>`)
+	gz.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprint(w, "User-agent: *\nDisallow: /private\nSitemap: /sitemap_index.xml\n")
+		case "/sitemap_index.xml":
+			w.Header().Set("Content-Type", "application/xml")
+			fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>/pages.xml</loc></sitemap>
+  <sitemap><loc>/blog.xml.gz</loc></sitemap>
+  <sitemap><loc>/missing.xml</loc></sitemap>
+</sitemapindex>`)
+		case "/pages.xml":
+			w.Header().Set("Content-Type", "application/xml")
+			fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url>
+    <loc> https://example.com/ </loc>
+    <lastmod>2024-05-01</lastmod>
+    <changefreq>daily</changefreq>
+    <priority>1.0</priority>
+  </url>
+  <url><loc>https://example.com/about</loc><priority>0.5</priority></url>
+</urlset>`)
+		case "/blog.xml.gz":
+			w.Header().Set("Content-Type", "application/x-gzip")
+			w.Write(gzipped.Bytes())
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	entries, err := ExtractSitemapEntries(server.URL+"/sitemap_index.xml", false)
+	if err != nil {
+		t.Fatalf("ExtractSitemapEntries failed: %v", err)
+	}
+
+	expected := []SitemapEntry{
+		{Loc: "https://example.com/", LastMod: "2024-05-01", ChangeFreq: "daily", Priority: "1.0"},
+		{Loc: "https://example.com/about", Priority: "0.5"},
+		{Loc: "https://example.com/blog/1", LastMod: "2024-01-02"},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %d entries, got %d: %v", len(expected), len(entries), entries)
+	}
+	for i, entry := range entries {
+		if entry != expected[i] {
+			t.Errorf("Expected %+v at position %d, got %+v", expected[i], i, entry)
+		}
+	}
+
+	// The sitemap limit stops expansion after the index itself
+	originalMax := maxSitemaps
+	SetMaxSitemaps(1)
+	entries, err = ExtractSitemapEntries(server.URL+"/sitemap_index.xml", false)
+	maxSitemaps = originalMax
+	if err != nil {
+		t.Fatalf("ExtractSitemapEntries failed: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected no entries with a limit of 1, got %d", len(entries))
+	}
+
+	// Sitemaps are discovered from robots.txt
+	sitemaps, err := DiscoverSitemaps(server.URL+"/some/page", false)
+	if err != nil {
+		t.Fatalf("DiscoverSitemaps failed: %v", err)
+	}
+	if len(sitemaps) != 1 || sitemaps[0] != server.URL+"/sitemap_index.xml" {
+		t.Errorf("Unexpected sitemaps from robots.txt: %v", sitemaps)
+	}
+
+	// ExtractLinksFromURL expands sitemaps automatically, including gzip ones
+	links, err := ExtractLinksFromURL(server.URL+"/blog.xml.gz", false)
+	if err != nil {
+		t.Fatalf("ExtractLinksFromURL failed: %v", err)
+	}
+	if len(links) != 1 || links[0] != "https://example.com/blog/1" {
+		t.Errorf("Unexpected links from gzip sitemap: %v", links)
+	}
+
+	// A non-sitemap document is rejected
+	if _, err := ExtractSitemapEntries(server.URL+"/robots.txt", false); err == nil {
+		t.Error("Expected error for non-sitemap document, got nil")
+	}
+}
+
+func TestParseRobotsSitemaps(t *testing.T) {
+	body := []byte("# comment\nsitemap: https://example.com/a.xml\nSITEMAP:/b.xml\nSitemap: https://example.com/a.xml\nUser-agent: *\n")
+	baseURL, _ := url.Parse("https://example.com/robots.txt")
+
+	sitemaps := parseRobotsSitemaps(body, baseURL)
+	expected := []string{"https://example.com/a.xml", "https://example.com/b.xml"}
+	if len(sitemaps) != len(expected) {
+		t.Fatalf("Expected %d sitemaps, got %d: %v", len(expected), len(sitemaps), sitemaps)
+	}
+	for i, sitemap := range sitemaps {
+		if sitemap != expected[i] {
+			t.Errorf("Expected %s at position %d, got %s", expected[i], i, sitemap)
+		}
+	}
+}
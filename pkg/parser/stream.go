@@ -0,0 +1,420 @@
+package parser
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"github.com/hemzaz/lsweb/pkg/common"
+)
+
+// sniffLen is how much of a stream is looked at to recognize its content, as much as
+// http.DetectContentType considers
+const sniffLen = 512
+
+// StreamLinks fetches a URL and sends the links found in its content on the returned
+// channel as they are parsed, so callers can filter and download while the page is
+// still loading. HTML is read with a streaming tokenizer, so pages of any size are
+// handled in constant memory apart from the set of URLs already seen; other content
+// types are read whole, up to common.MaxContentSize, and extracted as FetchLinks does.
+// Directory listings are not recognized while streaming, so they yield their raw anchors.
+//
+// The links channel is closed when extraction finishes. The error channel then receives
+// the error that stopped extraction, if any, and is closed. Cancelling ctx stops the fetch
+// and reports ctx.Err(). Only the time to receive response headers is bounded by
+// common.DefaultTimeout, so slow but steady pages are not cut off.
+// The ignoreCert parameter can be used to skip TLS certificate validation.
+func StreamLinks(ctx context.Context, targetURL string, ignoreCert bool) (<-chan common.Link, <-chan error) {
+	links := make(chan common.Link)
+	errc := make(chan error, 1)
+
+	go func() {
+		defer close(errc)
+		defer close(links)
+
+		send := func(link common.Link) bool {
+			select {
+			case links <- link:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		if err := streamLinks(ctx, targetURL, ignoreCert, send); err != nil {
+			errc <- err
+		} else if ctx.Err() != nil {
+			errc <- ctx.Err()
+		}
+	}()
+
+	return links, errc
+}
+
+// StreamLinksFromReader reads a document from r and calls fn with each unique link.
+// HTML is tokenized and each link passed to fn as soon as it has been read, without
+// buffering the document. The document is transcoded to UTF-8 according to its byte
+// order mark or <meta charset>. Relative links resolve against the document's
+// <base href>, once seen, or baseURL; each link's Source is baseURL and its Position
+// counts the links passed to fn. Anchors are passed at their closing tag so that their
+// text is known; other elements are passed at their start tag.
+// Other content is recognized as ReadLinksFromReader does, by its first bytes and the
+// extension of name, and read whole, up to common.MaxContentSize, before its links are
+// passed to fn. Gzip, brotli and zstd content is decompressed as it is read.
+// Returning false from fn stops reading. Returns an error if reading r fails.
+// When a CSS selector or XPath scope is set, the whole document is parsed first
+// and only the links in the matching subtrees are passed to fn.
+func StreamLinksFromReader(r io.Reader, name string, baseURL *url.URL, fn func(common.Link) bool) error {
+	buffered := bufio.NewReader(r)
+	head, _ := buffered.Peek(sniffLen)
+
+	// Decompress .gz, .br and .zst content, and recognize what it holds
+	limit := int64(common.MaxContentSize)
+	if format := compressionFormat(head, "", name); format != "" {
+		decompressed, err := newDecompressingReader(buffered, format)
+		if err != nil {
+			return err
+		}
+		defer decompressed.Close()
+		buffered = bufio.NewReader(decompressed)
+		head, _ = buffered.Peek(sniffLen)
+		switch strings.ToLower(path.Ext(name)) {
+		case ".gz", ".br", ".zst":
+			name = strings.TrimSuffix(name, path.Ext(name))
+		}
+		limit = maxDecompressedSize
+	}
+
+	if !isStreamableHTML(head, name) {
+		// Other content is small enough to extract from a buffered body
+		content, err := io.ReadAll(io.LimitReader(buffered, limit+1))
+		if err != nil {
+			return fmt.Errorf("error reading %s: %w", name, err)
+		}
+		if int64(len(content)) > limit {
+			return fmt.Errorf("%s is too large. Maximum size is %d bytes", name, limit)
+		}
+		links, err := extractLinksFromContent(content, name, baseURL)
+		if err != nil {
+			return err
+		}
+		for _, link := range numberLinks(removeDuplicateLinkDetails(links), baseURL.String()) {
+			if !fn(link) {
+				break
+			}
+		}
+		return nil
+	}
+
+	r, err := newHTMLReader(buffered, "")
+	if err != nil {
+		return err
+	}
+
+	if isHTMLScoped() {
+		doc, err := html.Parse(r)
+		if err != nil {
+			return fmt.Errorf("error parsing HTML: %w", err)
+		}
+		links, _ := collectHTMLLinks(doc, baseURL)
+		for _, link := range numberLinks(links, baseURL.String()) {
+			if !fn(link) {
+				break
+			}
+		}
+		return nil
+	}
+
+	_, err = streamHTMLLinks(r, baseURL, make(map[string]bool), false, fn)
+	return err
+}
+
+// Helper function to report whether a document that starts with head is HTML, which
+// is streamed, rather than markup or a stylesheet recognized by the extension of name
+func isStreamableHTML(head []byte, name string) bool {
+	ext := strings.ToLower(fileExtension(name))
+	if _, ok := markupFormats[ext]; ok || ext == ".css" {
+		return false
+	}
+	return strings.Contains(http.DetectContentType(head), "text/html")
+}
+
+// streamLinks implements StreamLinks, passing each link to send and following
+// meta-refresh redirects when enabled. Links already sent are not repeated
+// on the pages redirected to.
+func streamLinks(ctx context.Context, targetURL string, ignoreCert bool, send func(common.Link) bool) error {
+	visited := make(map[string]bool)
+
+	for redirects := 0; ; redirects++ {
+		target, err := streamPage(ctx, targetURL, ignoreCert, visited, send)
+		if err != nil || target == "" {
+			return err
+		}
+
+		if redirects >= maxRefreshRedirects {
+			return fmt.Errorf("too many meta-refresh redirects (%d)", redirects)
+		}
+		targetURL = target
+	}
+}
+
+// streamPage fetches one page and passes its links to send. If meta-refresh
+// following is enabled and the page redirects elsewhere, the target is returned
+// and the rest of the page is skipped.
+func streamPage(ctx context.Context, targetURL string, ignoreCert bool, visited map[string]bool, send func(common.Link) bool) (string, error) {
+	// Bound the wait for headers rather than the whole transfer
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = common.DefaultTimeout
+	if ignoreCert {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	client := &http.Client{Transport: transport}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", targetURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("User-Agent", common.UserAgent)
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error fetching webpage: %w", err)
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			fmt.Fprintf(os.Stderr, "Error closing response body: %v\n", closeErr)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("server returned non-success status: %d %s", resp.StatusCode, resp.Status)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if !isSupportedContentType(contentType) && !isDocumentResponse(contentType, resp.Request.URL.Path) {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedContentType, contentType)
+	}
+
+	if isHTMLContentType(contentType) && !isHTMLScoped() {
+		// Decode the Content-Encoding as the page streams in; memory stays constant,
+		// so no decompressed size limit is needed
+		var body io.Reader = resp.Body
+		if contentEncoding := resp.Header.Get("Content-Encoding"); contentEncoding != "" && !strings.EqualFold(contentEncoding, "identity") {
+			format := compressionFormat(nil, contentEncoding, "")
+			if format == "" {
+				return "", fmt.Errorf("unsupported content encoding: %s", contentEncoding)
+			}
+			decompressed, err := newDecompressingReader(resp.Body, format)
+			if err != nil {
+				return "", err
+			}
+			defer decompressed.Close()
+			body = decompressed
+		}
+
+		body, err = newHTMLReader(body, contentType)
+		if err != nil {
+			return "", err
+		}
+		target, err := streamHTMLLinks(body, resp.Request.URL, visited, followRefresh, send)
+		if err != nil && ctx.Err() == nil {
+			return "", fmt.Errorf("error reading HTML: %w", err)
+		}
+		return target, nil
+	}
+
+	// Other content is small enough to extract from a buffered body, and scoping
+	// HTML with a selector or XPath needs the whole document
+	bodyBytes, err := readResponseBody(resp)
+	if err != nil {
+		return "", err
+	}
+	links, err := extractLinksFromBody(bodyBytes, contentType, resp.Request.URL, ignoreCert, 0)
+	if err != nil {
+		return "", err
+	}
+	for _, link := range links {
+		if !visited[link.URL] {
+			visited[link.URL] = true
+			if !send(link) {
+				break
+			}
+		}
+	}
+	return "", nil
+}
+
+// streamHTMLLinks tokenizes HTML from r and passes each link not yet in visited to emit.
+// If stopAtRefresh is set, reading stops at the first meta-refresh element pointing
+// to another page, and its absolute target is returned instead of being emitted as a link.
+// Reading also stops when emit returns false.
+func streamHTMLLinks(r io.Reader, baseURL *url.URL, visited map[string]bool, stopAtRefresh bool, emit func(common.Link) bool) (string, error) {
+	tokenizer := html.NewTokenizer(r)
+	source := baseURL.String()
+	position := 0
+	baseSeen := false
+	malformed := 0
+
+	// The open anchor whose text is still being read
+	var anchor *common.Link
+	var anchorText strings.Builder
+
+	send := func(link common.Link) bool {
+		position++
+		link.Source = source
+		link.Position = position
+		return emit(link)
+	}
+
+	flushAnchor := func() bool {
+		if anchor == nil {
+			return true
+		}
+		link := *anchor
+		anchor = nil
+		if text := strings.Join(strings.Fields(anchorText.String()), " "); text != "" {
+			link.Text = text
+		}
+		anchorText.Reset()
+		return send(link)
+	}
+
+	// inStyle is set while the raw text of a <style> element is being read
+	inStyle := false
+
+	// addCSSLinks emits the url() and @import references of a stylesheet
+	addCSSLinks := func(css string, element, attr string) bool {
+		for _, link := range collectCSSLinks(css, baseURL, element) {
+			if attr != "" {
+				link.Attribute = attr
+			}
+			if visited[link.URL] {
+				continue
+			}
+			visited[link.URL] = true
+			if !send(link) {
+				return false
+			}
+		}
+		return true
+	}
+
+	// addLink emits a link immediately, or holds an anchor until its text is read
+	addLink := func(rawURL string, n *html.Node, attr string, hold bool) bool {
+		urlStr, err := resolveLink(rawURL, baseURL)
+		if err != nil {
+			malformed++
+			return true
+		}
+		if urlStr == "" || visited[urlStr] {
+			return true
+		}
+		visited[urlStr] = true
+
+		link := htmlLink(urlStr, n, attr)
+		if hold {
+			anchor = &link
+			return true
+		}
+		return send(link)
+	}
+
+	for {
+		tokenType := tokenizer.Next()
+		switch tokenType {
+		case html.ErrorToken:
+			if !flushAnchor() {
+				return "", nil
+			}
+			if malformed > 0 {
+				fmt.Fprintf(os.Stderr, "Warning: %d malformed URLs detected\n", malformed)
+			}
+			if err := tokenizer.Err(); err != io.EOF {
+				return "", err
+			}
+			return "", nil
+
+		case html.TextToken:
+			if inStyle {
+				if !addCSSLinks(string(tokenizer.Text()), "style", "") {
+					return "", nil
+				}
+			} else if anchor != nil {
+				anchorText.Write(tokenizer.Text())
+			}
+
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			if string(name) == "style" {
+				inStyle = false
+			}
+			if string(name) == "a" && !flushAnchor() {
+				return "", nil
+			}
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			n := &html.Node{Type: html.ElementNode, Data: token.Data, Attr: token.Attr}
+
+			// An anchor that was never closed ends where the next one starts
+			if n.Data == "a" && !flushAnchor() {
+				return "", nil
+			}
+
+			// The first <base href> applies to the links that follow it
+			if n.Data == "base" && !baseSeen && hasAttribute(n, "href") {
+				baseSeen = true
+				if href, err := url.Parse(strings.TrimSpace(getAttribute(n, "href"))); err == nil {
+					baseURL = baseURL.ResolveReference(href)
+				}
+			}
+
+			if target, ok := metaRefreshTarget(n); ok {
+				if stopAtRefresh {
+					if ref, err := url.Parse(target); err == nil {
+						if resolved := baseURL.ResolveReference(ref).String(); resolved != source {
+							return resolved, nil
+						}
+					}
+				}
+				if !addLink(target, n, "content", false) {
+					return "", nil
+				}
+			}
+
+			if enabledTags["style"] {
+				inStyle = n.Data == "style" && tokenType == html.StartTagToken
+				if hasAttribute(n, "style") && !addCSSLinks(getAttribute(n, "style"), n.Data, "style") {
+					return "", nil
+				}
+			}
+
+			for _, a := range n.Attr {
+				if a.Key == "srcset" && enabledTags["srcset"] && srcsetElements[n.Data] {
+					for _, candidate := range parseSrcset(a.Val) {
+						if !addLink(candidate, n, a.Key, false) {
+							return "", nil
+						}
+					}
+					continue
+				}
+				if enabledTags[n.Data] && isLinkAttribute(n.Data, a.Key) {
+					hold := n.Data == "a" && tokenType == html.StartTagToken
+					if !addLink(a.Val, n, a.Key, hold) {
+						return "", nil
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package parser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/hemzaz/lsweb/pkg/common"
+)
+
+func TestStreamLinksFromReader(t *testing.T) {
+	defer func() {
+		_ = SetTags(nil)
+	}()
+	if err := SetTags([]string{"a", "img", "srcset"}); err != nil {
+		t.Fatalf("SetTags failed: %v", err)
+	}
+
+	page := `<html><head><base href="/docs/"></head><body>
+<a href="one.html" title="First">Page <b>one</b></a>
+<a href="two.html"><img src="logo.png" alt="Logo" srcset="small.png 1x, large.png 2x"></a>
+<a href="one.html">Again</a>
+<a href="javascript:void(0)">Script</a>
+<a href="three.html">Unclosed
+</body></html>`
+
+	baseURL, _ := url.Parse("https://example.com/index.html")
+
+	var links []common.Link
+	err := StreamLinksFromReader(strings.NewReader(page), "page.html", baseURL, func(link common.Link) bool {
+		links = append(links, link)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("StreamLinksFromReader failed: %v", err)
+	}
+
+	expected := []common.Link{
+		{URL: "https://example.com/docs/one.html", Text: "Page one", Title: "First", Element: "a", Attribute: "href"},
+		{URL: "https://example.com/docs/logo.png", Text: "Logo", Element: "img", Attribute: "src"},
+		{URL: "https://example.com/docs/small.png", Text: "Logo", Element: "img", Attribute: "srcset"},
+		{URL: "https://example.com/docs/large.png", Text: "Logo", Element: "img", Attribute: "srcset"},
+		{URL: "https://example.com/docs/two.html", Element: "a", Attribute: "href"},
+		{URL: "https://example.com/docs/three.html", Text: "Unclosed", Element: "a", Attribute: "href"},
+	}
+	if len(links) != len(expected) {
+		t.Fatalf("Expected %d links, got %d: %+v", len(expected), len(links), links)
+	}
+	for i, link := range links {
+		expected[i].Source = baseURL.String()
+		expected[i].Position = i + 1
+		if link != expected[i] {
+			t.Errorf("Expected %+v at position %d, got %+v", expected[i], i, link)
+		}
+	}
+
+	// Returning false stops reading
+	count := 0
+	err = StreamLinksFromReader(strings.NewReader(page), "page.html", baseURL, func(common.Link) bool {
+		count++
+		return count < 2
+	})
+	if err != nil {
+		t.Fatalf("StreamLinksFromReader failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected reading to stop after 2 links, got %d", count)
+	}
+}
+
+func TestStreamLinksFromReaderFormats(t *testing.T) {
+	gzipped := func(content string) string {
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		writer.Write([]byte(content))
+		writer.Close()
+		return buf.String()
+	}
+
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		expected []string
+	}{
+		{"HTML", "page.html", `<a href="a.zip">A</a>`, []string{"https://example.com/a.zip"}},
+		{"JSON", "links.json", `{"files": ["https://example.com/a.zip", "https://example.com/b.zip"]}`, []string{"https://example.com/a.zip", "https://example.com/b.zip"}},
+		{"Markdown", "doc.md", "See [the archive](a.zip).", []string{"https://example.com/a.zip"}},
+		{"CSS", "style.css", `body { background: url("bg.png") }`, []string{"https://example.com/bg.png"}},
+		{"Plain text", "list.txt", "https://example.com/a.zip\nhttps://example.com/a.zip\n", []string{"https://example.com/a.zip"}},
+		{"Gzipped HTML", "page.html.gz", gzipped(`<a href="a.zip">A</a>`), []string{"https://example.com/a.zip"}},
+		{"Gzipped JSON", "links.json.gz", gzipped(`["https://example.com/a.zip"]`), []string{"https://example.com/a.zip"}},
+	}
+
+	baseURL, _ := url.Parse("https://example.com/")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var urls []string
+			err := StreamLinksFromReader(strings.NewReader(tt.content), tt.file, baseURL, func(link common.Link) bool {
+				urls = append(urls, link.URL)
+				return true
+			})
+			if err != nil {
+				t.Fatalf("StreamLinksFromReader failed: %v", err)
+			}
+			if strings.Join(urls, " ") != strings.Join(tt.expected, " ") {
+				t.Errorf("Expected %v, got %v", tt.expected, urls)
+			}
+		})
+	}
+}
+
+func TestStreamLinksLargePage(t *testing.T) {
+	// Write more than common.MaxContentSize of anchors
+	const total = 200000
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<html><body><ul>\n")
+		for i := 0; i < total; i++ {
+			fmt.Fprintf(w, "<li><a href=\"/files/file-%06d.bin\">file-%06d.bin</a> padding padding</li>\n", i, i)
+		}
+		fmt.Fprint(w, "</ul></body></html>")
+	}))
+	defer server.Close()
+
+	links, errc := StreamLinks(context.Background(), server.URL+"/", false)
+
+	count := 0
+	var last common.Link
+	for link := range links {
+		count++
+		last = link
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("StreamLinks failed: %v", err)
+	}
+
+	if count != total {
+		t.Fatalf("Expected %d links, got %d", total, count)
+	}
+	if last.URL != fmt.Sprintf("%s/files/file-%06d.bin", server.URL, total-1) || last.Position != total {
+		t.Errorf("Unexpected last link: %+v", last)
+	}
+}
+
+func TestStreamLinksCancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		for i := 0; ; i++ {
+			if _, err := fmt.Fprintf(w, "<a href=\"/%d\">%d</a>\n", i, i); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	links, errc := StreamLinks(ctx, server.URL, false)
+
+	count := 0
+	for range links {
+		count++
+		if count == 10 {
+			cancel()
+		}
+	}
+	if err := <-errc; err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if count < 10 {
+		t.Errorf("Expected at least 10 links before cancelling, got %d", count)
+	}
+}
+
+func TestStreamLinksNonHTML(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"download": "https://example.com/a.zip", "mirror": "https://example.com/a.zip"}`)
+	}))
+	defer server.Close()
+
+	links, errc := StreamLinks(context.Background(), server.URL, false)
+
+	var urls []string
+	for link := range links {
+		urls = append(urls, link.URL)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("StreamLinks failed: %v", err)
+	}
+	if len(urls) != 1 || urls[0] != "https://example.com/a.zip" {
+		t.Errorf("Expected the single JSON link, got %v", urls)
+	}
+}
@@ -0,0 +1,166 @@
+package parser
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/hemzaz/lsweb/pkg/common"
+)
+
+// xmlURLAttributes are attribute names that hold a URL on any element,
+// such as RSS <enclosure url>, Atom <link href> and Media RSS <media:content url>.
+// Their values are resolved against the document base, so relative URLs are kept.
+var xmlURLAttributes = map[string]bool{
+	"href": true,
+	"url":  true,
+	"src":  true,
+}
+
+// xmlURLElements are elements whose text content is a URL in RSS 2.0 and Atom,
+// such as RSS <link>, <guid>, <comments> and Atom <icon>, <logo>, <uri>.
+// Their text is resolved against the document base, so relative URLs are kept.
+var xmlURLElements = map[string]bool{
+	"link":     true,
+	"guid":     true,
+	"comments": true,
+	"docs":     true,
+	"icon":     true,
+	"logo":     true,
+	"uri":      true,
+}
+
+// absoluteURLPattern matches a value that consists of a single absolute http(s) URL.
+// Any other attribute or text node in a document is treated as a link only if it matches.
+var absoluteURLPattern = regexp.MustCompile(`^https?://\S+$`)
+
+// xmlElement tracks an open element while streaming through an XML document.
+type xmlElement struct {
+	name    string
+	base    *url.URL
+	text    strings.Builder
+	urlText bool
+}
+
+// Helper function to extract links with the element and attribute they were found in
+// from RSS, Atom and generic XML documents.
+// Known URL-bearing attributes and feed elements are resolved against baseURL,
+// honoring xml:base. Any other attribute or text node that is an absolute
+// http(s) URL is also returned.
+func collectXMLLinks(r io.Reader, baseURL *url.URL) ([]common.Link, error) {
+	var links []common.Link
+
+	// Use a map to track visited URLs for deduplication
+	visited := make(map[string]bool)
+
+	addLink := func(rawURL string, base *url.URL, element, attr string) {
+		ref, err := url.Parse(strings.TrimSpace(rawURL))
+		if err != nil || rawURL == "" {
+			return
+		}
+
+		urlStr := base.ResolveReference(ref).String()
+		if !visited[urlStr] {
+			visited[urlStr] = true
+			links = append(links, common.Link{URL: urlStr, Element: element, Attribute: attr})
+		}
+	}
+
+	// Feeds in the wild are often not well-formed, so parse leniently
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+
+	var stack []*xmlElement
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error parsing XML: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			base := baseURL
+			if len(stack) > 0 {
+				base = stack[len(stack)-1].base
+			}
+
+			// xml:base changes the base for this element and its descendants
+			for _, a := range t.Attr {
+				if a.Name.Local == "base" && (a.Name.Space == "xml" || a.Name.Space == "http://www.w3.org/XML/1998/namespace") {
+					if ref, err := url.Parse(strings.TrimSpace(a.Value)); err == nil {
+						base = base.ResolveReference(ref)
+					}
+				}
+			}
+
+			name := strings.ToLower(t.Name.Local)
+			urlText := xmlURLElements[name]
+
+			for _, a := range t.Attr {
+				key := strings.ToLower(a.Name.Local)
+				switch {
+				case key == "base" && a.Name.Space != "":
+					continue
+				case a.Name.Space == "xmlns" || (a.Name.Space == "" && key == "xmlns"):
+					// Namespace declarations are identifiers, not links
+					continue
+				case xmlURLAttributes[key]:
+					addLink(a.Value, base, name, key)
+				case absoluteURLPattern.MatchString(strings.TrimSpace(a.Value)):
+					addLink(a.Value, base, name, key)
+				}
+
+				// A guid that is not a permalink is only a link if it is an absolute URL
+				if name == "guid" && key == "ispermalink" && strings.EqualFold(a.Value, "false") {
+					urlText = false
+				}
+			}
+
+			stack = append(stack, &xmlElement{name: name, base: base, urlText: urlText})
+
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text.Write(t)
+			}
+
+		case xml.EndElement:
+			if len(stack) == 0 {
+				continue
+			}
+			element := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			text := strings.TrimSpace(element.text.String())
+			if text == "" {
+				continue
+			}
+			if element.urlText && !strings.ContainsAny(text, " \t\r\n") {
+				addLink(text, element.base, element.name, "")
+			} else if absoluteURLPattern.MatchString(text) {
+				addLink(text, element.base, element.name, "")
+			}
+		}
+	}
+
+	return links, nil
+}
+
+// isXMLContentType reports whether a Content-Type header value denotes an XML document,
+// including RSS and Atom feeds served with their own +xml media types.
+// XHTML is excluded because it is handled as HTML.
+func isXMLContentType(contentType string) bool {
+	if strings.Contains(contentType, "application/xhtml+xml") {
+		return false
+	}
+	return strings.Contains(contentType, "application/xml") ||
+		strings.Contains(contentType, "text/xml") ||
+		strings.Contains(contentType, "+xml")
+}
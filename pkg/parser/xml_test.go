@@ -0,0 +1,115 @@
+package parser
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/hemzaz/lsweb/pkg/common"
+)
+
+func TestCollectXMLLinks(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected []string
+	}{
+		{
+			name: "RSS 2.0 feed",
+			content: `<?xml version="1.0"?>
+<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom">
+  <channel>
+    <link>https://example.com/</link>
+    <atom:link href="https://example.com/feed.xml" rel="self"/>
+    <item>
+      <title>Episode 1</title>
+      <link>/episodes/1</link>
+      <guid isPermaLink="false">episode-1</guid>
+      <enclosure url="https://cdn.example.com/ep1.mp3" length="1024" type="audio/mpeg"/>
+    </item>
+    <item>
+      <guid>https://example.com/episodes/2</guid>
+      <description>Not a link</description>
+    </item>
+  </channel>
+</rss>`,
+			expected: []string{
+				"https://example.com/",
+				"https://example.com/feed.xml",
+				"https://feeds.example.com/episodes/1",
+				"https://cdn.example.com/ep1.mp3",
+				"https://example.com/episodes/2",
+			},
+		},
+		{
+			name: "Atom feed with xml:base",
+			content: `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xml:base="https://example.com/releases/">
+  <id>tag:example.com,2024:releases</id>
+  <link href="https://example.com/"/>
+  <entry>
+    <link rel="enclosure" href="v1.0/app.tar.gz"/>
+    <author><uri>https://example.com/team</uri></author>
+  </entry>
+</feed>`,
+			expected: []string{
+				"https://example.com/",
+				"https://example.com/releases/v1.0/app.tar.gz",
+				"https://example.com/team",
+			},
+		},
+		{
+			name: "Generic XML",
+			content: `<?xml version="1.0"?>
+<catalog>
+  <file name="a.bin" location="https://example.com/a.bin"/>
+  <mirror>http://mirror.example.org/a.bin</mirror>
+  <note>see https://example.com for details</note>
+</catalog>`,
+			expected: []string{
+				"https://example.com/a.bin",
+				"http://mirror.example.org/a.bin",
+			},
+		},
+	}
+
+	baseURL, _ := url.Parse("https://feeds.example.com/feed.xml")
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			collected, err := collectXMLLinks(strings.NewReader(tc.content), baseURL)
+			if err != nil {
+				t.Fatalf("collectXMLLinks failed: %v", err)
+			}
+			links := common.URLs(collected)
+
+			if len(links) != len(tc.expected) {
+				t.Fatalf("Expected %d links, got %d: %v", len(tc.expected), len(links), links)
+			}
+
+			for i, link := range links {
+				if link != tc.expected[i] {
+					t.Errorf("Expected %s at position %d, got %s", tc.expected[i], i, link)
+				}
+			}
+		})
+	}
+}
+
+func TestIsXMLContentType(t *testing.T) {
+	tests := map[string]bool{
+		"application/xml":          true,
+		"text/xml; charset=utf-8":  true,
+		"application/rss+xml":      true,
+		"application/atom+xml":     true,
+		"application/xhtml+xml":    false,
+		"text/html; charset=utf-8": false,
+		"application/json":         false,
+	}
+
+	for contentType, expected := range tests {
+		if result := isXMLContentType(contentType); result != expected {
+			t.Errorf("isXMLContentType(%q) = %v, expected %v", contentType, result, expected)
+		}
+	}
+}
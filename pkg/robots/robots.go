@@ -0,0 +1,314 @@
+// Package robots implements robots.txt compliance and per-host request spacing
+// for crawling and bulk downloading.
+package robots
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	// Internal dependencies
+	"github.com/hemzaz/lsweb/pkg/common"
+)
+
+// DefaultHostDelay is the default minimum time between requests to the same host
+const DefaultHostDelay = 500 * time.Millisecond
+
+// maxRobotsSize limits how much of a robots.txt file is read, as RFC 9309 allows
+const maxRobotsSize = 500 * 1024
+
+// ErrDisallowed is returned for URLs that robots.txt does not allow lsweb to fetch
+var ErrDisallowed = errors.New("disallowed by robots.txt")
+
+// Default configuration values
+var (
+	ignoreRobots = false
+	hostDelay    = DefaultHostDelay
+)
+
+// Shared state: robots.txt rules and the time of the next allowed request, per host
+var (
+	mu          sync.Mutex
+	rulesByHost = make(map[string]*hostRules)
+	nextRequest = make(map[string]time.Time)
+)
+
+// hostRules holds the rules for one host, fetched once
+type hostRules struct {
+	once  sync.Once
+	rules *Rules
+}
+
+// SetIgnoreRobots sets whether robots.txt is ignored. Ignoring it also ignores
+// its Crawl-delay; the per-host delay still applies.
+func SetIgnoreRobots(ignore bool) {
+	ignoreRobots = ignore
+}
+
+// SetHostDelay sets the minimum time between requests to the same host.
+// A robots.txt Crawl-delay that is longer takes precedence.
+func SetHostDelay(delay time.Duration) {
+	if delay >= 0 {
+		hostDelay = delay
+	}
+}
+
+// Reset forgets the robots.txt rules and request times of every host.
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	rulesByHost = make(map[string]*hostRules)
+	nextRequest = make(map[string]time.Time)
+}
+
+// Wait prepares a request to rawURL: it returns ErrDisallowed if the host's robots.txt
+// does not allow common.UserAgent to fetch the URL, and otherwise blocks until the
+// host's request spacing allows another request. robots.txt is fetched once per host.
+// The ignoreCert parameter can be used to skip TLS certificate validation.
+func Wait(rawURL string, ignoreCert bool) error {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+
+	delay := hostDelay
+	if !ignoreRobots && (target.Scheme == "http" || target.Scheme == "https") {
+		rules := rulesFor(target, ignoreCert)
+		if !rules.Allowed(target) {
+			return fmt.Errorf("%w: %s", ErrDisallowed, rawURL)
+		}
+		if rules.CrawlDelay > delay {
+			delay = rules.CrawlDelay
+		}
+	}
+
+	// Reserve the next slot for the host and sleep until it comes
+	host := strings.ToLower(target.Host)
+	mu.Lock()
+	now := time.Now()
+	slot := nextRequest[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	nextRequest[host] = slot.Add(delay)
+	mu.Unlock()
+
+	time.Sleep(slot.Sub(now))
+	return nil
+}
+
+// Helper function to return the cached rules for a URL's host, fetching them on first use
+func rulesFor(target *url.URL, ignoreCert bool) *Rules {
+	key := strings.ToLower(target.Scheme + "://" + target.Host)
+
+	mu.Lock()
+	entry, ok := rulesByHost[key]
+	if !ok {
+		entry = &hostRules{}
+		rulesByHost[key] = entry
+	}
+	mu.Unlock()
+
+	entry.once.Do(func() {
+		entry.rules = fetchRules(key+"/robots.txt", ignoreCert)
+	})
+	return entry.rules
+}
+
+// Helper function to fetch and parse a robots.txt file. As RFC 9309 specifies, a
+// missing file (4xx) allows everything and a server error (5xx) disallows everything.
+// Network errors allow everything, since the request they guard would fail as well.
+func fetchRules(robotsURL string, ignoreCert bool) *Rules {
+	client := &http.Client{
+		Timeout: common.DefaultTimeout,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: ignoreCert},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), common.DefaultTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", robotsURL, nil)
+	if err != nil {
+		return &Rules{}
+	}
+	req.Header.Set("User-Agent", common.UserAgent)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return &Rules{}
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 500:
+		return &Rules{rules: []rule{{pattern: compilePattern("/"), length: 1}}}
+	case resp.StatusCode != http.StatusOK:
+		return &Rules{}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRobotsSize))
+	if err != nil {
+		return &Rules{}
+	}
+	return Parse(body, common.UserAgent)
+}
+
+// Rules are the robots.txt rules that apply to one user agent
+type Rules struct {
+	rules []rule
+
+	// CrawlDelay is the requested time between requests, or zero
+	CrawlDelay time.Duration
+}
+
+// rule is one Allow or Disallow line
+type rule struct {
+	pattern *regexp.Regexp
+	length  int
+	allow   bool
+}
+
+// Parse reads the rules of a robots.txt file that apply to userAgent, matched by its
+// product token (the part before any "/") case-insensitively. Groups naming the agent
+// are merged; if there are none, the "*" group applies.
+func Parse(body []byte, userAgent string) *Rules {
+	token := strings.ToLower(strings.TrimSpace(strings.SplitN(userAgent, "/", 2)[0]))
+
+	type group struct {
+		agents []string
+		rules  []rule
+		delay  time.Duration
+	}
+
+	var groups []*group
+	var current *group
+	inAgents := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// Consecutive user-agent lines start one group
+			if !inAgents {
+				current = &group{}
+				groups = append(groups, current)
+				inAgents = true
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+		case "allow", "disallow":
+			inAgents = false
+			if current == nil || value == "" {
+				continue
+			}
+			current.rules = append(current.rules, rule{
+				pattern: compilePattern(value),
+				length:  len(value),
+				allow:   key == "allow",
+			})
+		case "crawl-delay":
+			inAgents = false
+			if current == nil {
+				continue
+			}
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+				current.delay = time.Duration(seconds * float64(time.Second))
+			}
+		default:
+			// Other lines, such as Sitemap, do not end the list of agents
+		}
+	}
+
+	// Groups naming the agent apply, even if they are empty; the "*" groups only if
+	// there are none
+	rules := &Rules{}
+	for _, name := range []string{token, "*"} {
+		matched := false
+		for _, g := range groups {
+			for _, agent := range g.agents {
+				if agent == name {
+					matched = true
+					rules.rules = append(rules.rules, g.rules...)
+					if g.delay > rules.CrawlDelay {
+						rules.CrawlDelay = g.delay
+					}
+					break
+				}
+			}
+		}
+		if matched {
+			break
+		}
+	}
+
+	// The longest pattern decides; on a tie, Allow wins
+	sort.SliceStable(rules.rules, func(i, j int) bool {
+		if rules.rules[i].length != rules.rules[j].length {
+			return rules.rules[i].length > rules.rules[j].length
+		}
+		return rules.rules[i].allow && !rules.rules[j].allow
+	})
+	return rules
+}
+
+// Allowed reports whether the rules allow fetching a URL. /robots.txt is always allowed.
+func (r *Rules) Allowed(target *url.URL) bool {
+	path := target.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if path == "/robots.txt" {
+		return true
+	}
+	if target.RawQuery != "" {
+		path += "?" + target.RawQuery
+	}
+
+	for _, rule := range r.rules {
+		if rule.pattern.MatchString(path) {
+			return rule.allow
+		}
+	}
+	return true
+}
+
+// Helper function to compile a robots.txt path pattern, where * matches any
+// characters and a trailing $ anchors the end of the path
+func compilePattern(pattern string) *regexp.Regexp {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	expr := "^" + strings.Join(parts, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
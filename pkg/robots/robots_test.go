@@ -0,0 +1,171 @@
+package robots
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	const robotsTxt = `# Example robots.txt
+User-agent: *
+Disallow: /
+
+User-agent: lsweb
+User-agent: otherbot
+Disallow: /private/
+Allow: /private/public/
+Disallow: /*.tmp$
+Disallow: /search?
+Crawl-delay: 2
+
+Sitemap: https://example.com/sitemap.xml
+`
+
+	tests := []struct {
+		name      string
+		userAgent string
+		path      string
+		allowed   bool
+	}{
+		{"Own group allows", "lsweb/1.0", "/docs/", true},
+		{"Own group disallows", "lsweb/1.0", "/private/file", false},
+		{"Longer allow wins", "lsweb/1.0", "/private/public/file", true},
+		{"Wildcard and anchor", "lsweb/1.0", "/a/b.tmp", false},
+		{"Anchor does not match longer paths", "lsweb/1.0", "/a/b.tmp.gz", true},
+		{"Query is matched", "lsweb/1.0", "/search?q=go", false},
+		{"Agent is case-insensitive", "LSWeb", "/private/file", false},
+		{"Other agents use the * group", "curl/8.0", "/docs/", false},
+		{"robots.txt is always allowed", "curl/8.0", "/robots.txt", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := Parse([]byte(robotsTxt), tt.userAgent)
+			target, _ := url.Parse("https://example.com" + tt.path)
+			if allowed := rules.Allowed(target); allowed != tt.allowed {
+				t.Errorf("Expected allowed %v for %s, got %v", tt.allowed, tt.path, allowed)
+			}
+		})
+	}
+
+	if delay := Parse([]byte(robotsTxt), "lsweb").CrawlDelay; delay != 2*time.Second {
+		t.Errorf("Expected a crawl delay of 2s, got %v", delay)
+	}
+	if delay := Parse([]byte(robotsTxt), "curl").CrawlDelay; delay != 0 {
+		t.Errorf("Expected no crawl delay for the * group, got %v", delay)
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	rules := Parse([]byte("Disallow: /\nUser-agent: *\nDisallow:\n"), "lsweb")
+	target, _ := url.Parse("https://example.com/anything")
+	if !rules.Allowed(target) {
+		t.Error("Expected rules outside a group and empty Disallow lines to be ignored")
+	}
+}
+
+func TestParseEmptyOwnGroup(t *testing.T) {
+	const robotsTxt = "User-agent: *\nDisallow: /\n\nUser-agent: lsweb\nDisallow:\n"
+
+	target, _ := url.Parse("https://example.com/docs/")
+	if rules := Parse([]byte(robotsTxt), "lsweb/1.0"); !rules.Allowed(target) {
+		t.Error("Expected an empty group naming lsweb to apply instead of the * group")
+	}
+	if rules := Parse([]byte(robotsTxt), "curl/8.0"); rules.Allowed(target) {
+		t.Error("Expected other agents to use the * group")
+	}
+}
+
+func TestWait(t *testing.T) {
+	defer SetHostDelay(DefaultHostDelay)
+	defer Reset()
+	SetHostDelay(0)
+
+	var mu sync.Mutex
+	robotsFetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			mu.Lock()
+			robotsFetches++
+			mu.Unlock()
+			fmt.Fprint(w, "User-agent: *\nDisallow: /private/\n")
+		}
+	}))
+	defer server.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := Wait(server.URL+"/public/file", false); err != nil {
+				t.Errorf("Expected an allowed URL, got %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := Wait(server.URL+"/private/file", false); !errors.Is(err, ErrDisallowed) {
+		t.Errorf("Expected ErrDisallowed, got %v", err)
+	}
+	if robotsFetches != 1 {
+		t.Errorf("Expected robots.txt to be fetched once, got %d", robotsFetches)
+	}
+
+	SetIgnoreRobots(true)
+	defer SetIgnoreRobots(false)
+	if err := Wait(server.URL+"/private/file", false); err != nil {
+		t.Errorf("Expected robots.txt to be ignored, got %v", err)
+	}
+}
+
+func TestWaitServerError(t *testing.T) {
+	defer SetHostDelay(DefaultHostDelay)
+	defer Reset()
+	SetHostDelay(0)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	if err := Wait(server.URL+"/file", false); !errors.Is(err, ErrDisallowed) {
+		t.Errorf("Expected a server error to disallow everything, got %v", err)
+	}
+}
+
+func TestWaitSpacing(t *testing.T) {
+	defer SetHostDelay(DefaultHostDelay)
+	defer Reset()
+	SetHostDelay(50 * time.Millisecond)
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := Wait(server.URL+"/file", false); err != nil {
+			t.Fatalf("Wait failed: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Expected three requests to take at least 100ms, took %v", elapsed)
+	}
+
+	// Another host is not held up by the first
+	other := httptest.NewServer(http.NotFoundHandler())
+	defer other.Close()
+	start = time.Now()
+	if err := Wait(other.URL+"/file", false); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= 50*time.Millisecond {
+		t.Errorf("Expected the first request to another host not to wait, took %v", elapsed)
+	}
+}
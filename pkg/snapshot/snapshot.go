@@ -0,0 +1,410 @@
+// Package snapshot records the links of a source at a point in time and compares
+// snapshots, reporting the links added, removed and changed between them.
+package snapshot
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	// Internal dependencies
+	"github.com/hemzaz/lsweb/pkg/common"
+)
+
+// Snapshot is the set of links found in some sources at a point in time
+type Snapshot struct {
+	// Taken is when the links were listed
+	Taken time.Time `json:"taken"`
+
+	// Sources lists the URLs and files the links were listed from
+	Sources []string `json:"sources,omitempty"`
+
+	// Links holds the links found
+	Links []common.Link `json:"links"`
+}
+
+// Load reads a set of links from a file: a snapshot saved with Save, or lsweb's own
+// JSON output, either an array of link objects (-o json), an array of URLs, or one
+// link object per line (-stream -o json). Links read from lsweb's output have no
+// snapshot time or sources.
+// Returns an error satisfying os.IsNotExist if there is no file at path.
+func Load(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("error reading links from %s: %w", path, err)
+	}
+	return snapshot, nil
+}
+
+// Helper function to decode the formats Load accepts
+func decode(data []byte) (*Snapshot, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, errors.New("no links found")
+	}
+
+	// An array of link objects or of URLs
+	if data[0] == '[' {
+		var links []common.Link
+		if err := json.Unmarshal(data, &links); err == nil {
+			return &Snapshot{Links: links}, nil
+		}
+		var urls []string
+		if err := json.Unmarshal(data, &urls); err != nil {
+			return nil, errors.New("expected an array of links or URLs")
+		}
+		return &Snapshot{Links: common.LinksFromURLs(urls)}, nil
+	}
+
+	// A snapshot object, or a stream of link objects
+	snapshot := &Snapshot{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for decoder.More() {
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+
+		var probe struct {
+			Links json.RawMessage `json:"links"`
+		}
+		if err := json.Unmarshal(value, &probe); err != nil {
+			return nil, err
+		}
+
+		if probe.Links != nil {
+			var saved Snapshot
+			if err := json.Unmarshal(value, &saved); err != nil {
+				return nil, err
+			}
+			snapshot.Taken, snapshot.Sources = saved.Taken, saved.Sources
+			snapshot.Links = append(snapshot.Links, saved.Links...)
+			continue
+		}
+
+		var link common.Link
+		if err := json.Unmarshal(value, &link); err != nil {
+			return nil, err
+		}
+		snapshot.Links = append(snapshot.Links, link)
+	}
+	return snapshot, nil
+}
+
+// Save writes a snapshot to path as JSON. The file is replaced atomically, so an
+// interrupted save leaves the previous snapshot intact.
+func Save(path string, snapshot *Snapshot) error {
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding snapshot: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error saving snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error saving snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error saving snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error saving snapshot: %w", err)
+	}
+	return nil
+}
+
+// Change is a link whose metadata differs between two snapshots
+type Change struct {
+	Old common.Link `json:"old"`
+	New common.Link `json:"new"`
+
+	// Fields names the metadata that changed: "size", "etag" or "modified"
+	Fields []string `json:"fields"`
+}
+
+// unifiedContext is the number of unchanged lines shown around changes in unified format
+const unifiedContext = 3
+
+// Diff holds the differences between two sets of links, each list sorted by URL
+type Diff struct {
+	Added   []common.Link `json:"added"`
+	Removed []common.Link `json:"removed"`
+	Changed []Change      `json:"changed"`
+
+	// Unchanged holds the links found in both sets, as they are in the new set;
+	// they give context in unified format
+	Unchanged []common.Link `json:"-"`
+}
+
+// Empty reports whether the two sets of links were the same.
+func (d Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Compare returns the links of newLinks that are not in oldLinks, the links of
+// oldLinks that are no longer in newLinks, and the links in both whose size, ETag
+// or modification time changed. Links are matched by URL. Metadata that is unknown
+// on either side is not compared, so a source that does not report it never
+// produces changes.
+func Compare(oldLinks, newLinks []common.Link) Diff {
+	diff := Diff{Added: []common.Link{}, Removed: []common.Link{}, Changed: []Change{}}
+
+	oldByURL := make(map[string]common.Link, len(oldLinks))
+	for _, link := range oldLinks {
+		oldByURL[link.URL] = link
+	}
+	newByURL := make(map[string]common.Link, len(newLinks))
+	for _, link := range newLinks {
+		newByURL[link.URL] = link
+	}
+
+	for _, link := range newByURL {
+		old, ok := oldByURL[link.URL]
+		if !ok {
+			diff.Added = append(diff.Added, link)
+			continue
+		}
+		if fields := changedFields(old, link); len(fields) > 0 {
+			diff.Changed = append(diff.Changed, Change{Old: old, New: link, Fields: fields})
+		} else {
+			diff.Unchanged = append(diff.Unchanged, link)
+		}
+	}
+	for _, link := range oldByURL {
+		if _, ok := newByURL[link.URL]; !ok {
+			diff.Removed = append(diff.Removed, link)
+		}
+	}
+
+	sortLinks(diff.Added)
+	sortLinks(diff.Removed)
+	sortLinks(diff.Unchanged)
+	sort.Slice(diff.Changed, func(i, j int) bool { return diff.Changed[i].New.URL < diff.Changed[j].New.URL })
+	return diff
+}
+
+// Helper function to list the metadata fields that differ between two versions of a link
+func changedFields(old, new common.Link) []string {
+	var fields []string
+	if old.Size > 0 && new.Size > 0 && old.Size != new.Size {
+		fields = append(fields, "size")
+	}
+	if old.ETag != "" && new.ETag != "" && old.ETag != new.ETag {
+		fields = append(fields, "etag")
+	}
+	if !old.Modified.IsZero() && !new.Modified.IsZero() && !old.Modified.Equal(new.Modified) {
+		fields = append(fields, "modified")
+	}
+	return fields
+}
+
+// Helper function to sort links by URL
+func sortLinks(links []common.Link) {
+	sort.Slice(links, func(i, j int) bool { return links[i].URL < links[j].URL })
+}
+
+// PrintDiff prints a diff to stdout in the given format: "txt" for one line per link,
+// marked "+" when added, "-" when removed and "~" when changed; "json" for a JSON
+// object with added, removed and changed lists; or "unified" for a unified diff of
+// the two sets, one line per link with its metadata, labelled oldName and newName.
+// Returns an error if the format is not recognized.
+func PrintDiff(diff Diff, format, oldName, newName string) error {
+	switch format {
+	case "txt":
+		return writeDiffText(os.Stdout, diff)
+	case "json":
+		return writeDiffJSON(os.Stdout, diff)
+	case "unified":
+		return writeDiffUnified(os.Stdout, diff, oldName, newName)
+	default:
+		return fmt.Errorf("invalid diff output format: %s (valid formats: txt, json, unified)", format)
+	}
+}
+
+// Helper function to write a diff as text; changed links list their old and new metadata
+func writeDiffText(w io.Writer, diff Diff) error {
+	for _, link := range diff.Added {
+		if _, err := fmt.Fprintf(w, "+ %s\n", link.URL); err != nil {
+			return err
+		}
+	}
+	for _, link := range diff.Removed {
+		if _, err := fmt.Fprintf(w, "- %s\n", link.URL); err != nil {
+			return err
+		}
+	}
+	for _, change := range diff.Changed {
+		details := make([]string, 0, len(change.Fields))
+		for _, field := range change.Fields {
+			details = append(details, fmt.Sprintf("%s %s -> %s", field, fieldValue(change.Old, field), fieldValue(change.New, field)))
+		}
+		if _, err := fmt.Fprintf(w, "~ %s (%s)\n", change.New.URL, strings.Join(details, ", ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Helper function to format one metadata field of a link for display
+func fieldValue(link common.Link, field string) string {
+	switch field {
+	case "size":
+		return fmt.Sprintf("%d", link.Size)
+	case "etag":
+		return link.ETag
+	case "modified":
+		return link.Modified.UTC().Format(time.RFC3339)
+	}
+	return ""
+}
+
+// Helper function to write a diff as a JSON object
+func writeDiffJSON(w io.Writer, diff Diff) error {
+	data, err := json.Marshal(diff)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// diffLine is one line of a unified diff: an operation (' ', '-' or '+') and its text
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// Helper function to write a diff in unified format, with the links of both sets
+// sorted by URL so that the lines of each set are in a stable order. Nothing is
+// written when the sets are the same.
+func writeDiffUnified(w io.Writer, diff Diff, oldName, newName string) error {
+	if diff.Empty() {
+		return nil
+	}
+
+	// Interleave every link by URL; a changed link is its old line then its new one
+	type entry struct {
+		url   string
+		lines []diffLine
+	}
+	var entries []entry
+	for _, link := range diff.Unchanged {
+		entries = append(entries, entry{link.URL, []diffLine{{' ', linkLine(link)}}})
+	}
+	for _, link := range diff.Removed {
+		entries = append(entries, entry{link.URL, []diffLine{{'-', linkLine(link)}}})
+	}
+	for _, link := range diff.Added {
+		entries = append(entries, entry{link.URL, []diffLine{{'+', linkLine(link)}}})
+	}
+	for _, change := range diff.Changed {
+		entries = append(entries, entry{change.New.URL, []diffLine{{'-', linkLine(change.Old)}, {'+', linkLine(change.New)}}})
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].url < entries[j].url })
+
+	var lines []diffLine
+	for _, e := range entries {
+		lines = append(lines, e.lines...)
+	}
+
+	if _, err := fmt.Fprintf(w, "--- %s\n+++ %s\n", oldName, newName); err != nil {
+		return err
+	}
+
+	// Group changes closer than twice the context into one hunk
+	for start := 0; start < len(lines); {
+		first := start
+		for first < len(lines) && lines[first].op == ' ' {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+		last := first
+		for i := first; i < len(lines) && i-last <= 2*unifiedContext; i++ {
+			if lines[i].op != ' ' {
+				last = i
+			}
+		}
+
+		from := max(first-unifiedContext, 0)
+		to := min(last+unifiedContext+1, len(lines))
+		if err := writeHunk(w, lines, from, to); err != nil {
+			return err
+		}
+		start = to
+	}
+	return nil
+}
+
+// Helper function to write the lines from index from up to to as one hunk
+func writeHunk(w io.Writer, lines []diffLine, from, to int) error {
+	oldStart, newStart := 1, 1
+	for _, line := range lines[:from] {
+		if line.op != '+' {
+			oldStart++
+		}
+		if line.op != '-' {
+			newStart++
+		}
+	}
+	oldCount, newCount := 0, 0
+	for _, line := range lines[from:to] {
+		if line.op != '+' {
+			oldCount++
+		}
+		if line.op != '-' {
+			newCount++
+		}
+	}
+
+	// An empty range starts at the line before it
+	if oldCount == 0 {
+		oldStart--
+	}
+	if newCount == 0 {
+		newStart--
+	}
+
+	if _, err := fmt.Fprintf(w, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount); err != nil {
+		return err
+	}
+	for _, line := range lines[from:to] {
+		if _, err := fmt.Fprintf(w, "%c%s\n", line.op, line.text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Helper function to format a link and its known metadata as one line
+func linkLine(link common.Link) string {
+	line := link.URL
+	if link.Size > 0 {
+		line += fmt.Sprintf(" size=%d", link.Size)
+	}
+	if link.ETag != "" {
+		line += " etag=" + link.ETag
+	}
+	if !link.Modified.IsZero() {
+		line += " modified=" + link.Modified.UTC().Format(time.RFC3339)
+	}
+	return line
+}
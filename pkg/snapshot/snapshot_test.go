@@ -0,0 +1,176 @@
+package snapshot
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hemzaz/lsweb/pkg/common"
+)
+
+func TestCompare(t *testing.T) {
+	day := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	oldLinks := []common.Link{
+		{URL: "https://example.com/same.tar.gz", Size: 10, ETag: `"a"`, Modified: day},
+		{URL: "https://example.com/gone.tar.gz"},
+		{URL: "https://example.com/rebuilt.tar.gz", Size: 10, ETag: `"a"`, Modified: day},
+		{URL: "https://example.com/unknown.tar.gz", Size: 10},
+	}
+	newLinks := []common.Link{
+		{URL: "https://example.com/same.tar.gz", Size: 10, ETag: `"a"`, Modified: day.In(time.FixedZone("CET", 3600))},
+		{URL: "https://example.com/rebuilt.tar.gz", Size: 12, ETag: `"b"`, Modified: day.Add(time.Hour)},
+		{URL: "https://example.com/unknown.tar.gz"},
+		{URL: "https://example.com/new-2.tar.gz"},
+		{URL: "https://example.com/new-1.tar.gz"},
+	}
+
+	diff := Compare(oldLinks, newLinks)
+	if len(diff.Added) != 2 || diff.Added[0].URL != "https://example.com/new-1.tar.gz" || diff.Added[1].URL != "https://example.com/new-2.tar.gz" {
+		t.Errorf("Unexpected added links %+v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].URL != "https://example.com/gone.tar.gz" {
+		t.Errorf("Unexpected removed links %+v", diff.Removed)
+	}
+	if len(diff.Changed) != 1 || diff.Changed[0].New.URL != "https://example.com/rebuilt.tar.gz" ||
+		len(diff.Changed[0].Fields) != 3 {
+		t.Errorf("Unexpected changed links %+v", diff.Changed)
+	}
+
+	if !Compare(oldLinks, oldLinks).Empty() {
+		t.Error("Expected no differences between identical link sets")
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	if _, err := Load(path); !os.IsNotExist(err) {
+		t.Errorf("Expected a not-exist error for a missing snapshot, got %v", err)
+	}
+
+	saved := &Snapshot{
+		Taken:   time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Sources: []string{"https://example.com/"},
+		Links:   []common.Link{{URL: "https://example.com/a.zip", Size: 42, ETag: `"x"`}},
+	}
+	if err := Save(path, saved); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !loaded.Taken.Equal(saved.Taken) || len(loaded.Links) != 1 || loaded.Links[0] != saved.Links[0] {
+		t.Errorf("Expected %+v, got %+v", saved, loaded)
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("Expected no temporary files to be left behind, got %d entries", len(entries))
+	}
+}
+
+func TestWriteDiffText(t *testing.T) {
+	diff := Diff{
+		Added:   []common.Link{{URL: "https://example.com/new.zip"}},
+		Removed: []common.Link{{URL: "https://example.com/old.zip"}},
+		Changed: []Change{{
+			Old:    common.Link{URL: "https://example.com/app.zip", Size: 10},
+			New:    common.Link{URL: "https://example.com/app.zip", Size: 12},
+			Fields: []string{"size"},
+		}},
+	}
+
+	var out bytes.Buffer
+	if err := writeDiffText(&out, diff); err != nil {
+		t.Fatalf("writeDiffText failed: %v", err)
+	}
+	expected := "+ https://example.com/new.zip\n- https://example.com/old.zip\n~ https://example.com/app.zip (size 10 -> 12)\n"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+
+	if err := PrintDiff(diff, "xml", "", ""); err == nil {
+		t.Error("Expected an error for an invalid format")
+	}
+}
+
+func TestLoadFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		urls    []string
+	}{
+		{"Snapshot", `{"taken":"2026-01-02T03:04:05Z","links":[{"url":"https://example.com/a"}]}`, []string{"https://example.com/a"}},
+		{"Link objects", `[{"url":"https://example.com/a","size":1},{"url":"https://example.com/b"}]`, []string{"https://example.com/a", "https://example.com/b"}},
+		{"URLs", `["https://example.com/a", "https://example.com/b"]`, []string{"https://example.com/a", "https://example.com/b"}},
+		{"Stream", "{\"url\":\"https://example.com/a\"}\n{\"url\":\"https://example.com/b\"}\n", []string{"https://example.com/a", "https://example.com/b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "links.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			loaded, err := Load(path)
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			if urls := common.URLs(loaded.Links); strings.Join(urls, " ") != strings.Join(tt.urls, " ") {
+				t.Errorf("Expected %v, got %v", tt.urls, urls)
+			}
+		})
+	}
+
+	path := filepath.Join(t.TempDir(), "invalid.json")
+	os.WriteFile(path, []byte(`[1, 2]`), 0o644)
+	if _, err := Load(path); err == nil {
+		t.Error("Expected an error for JSON that is not a link listing")
+	}
+}
+
+func TestWriteDiffUnified(t *testing.T) {
+	var oldLinks, newLinks []common.Link
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i"} {
+		link := common.Link{URL: "https://example.com/" + name}
+		oldLinks = append(oldLinks, link)
+		newLinks = append(newLinks, link)
+	}
+	oldLinks[0].Size = 10
+	newLinks[0].Size = 12
+	newLinks = append(newLinks, common.Link{URL: "https://example.com/j"})
+
+	var out bytes.Buffer
+	if err := writeDiffUnified(&out, Compare(oldLinks, newLinks), "old.json", "new.json"); err != nil {
+		t.Fatalf("writeDiffUnified failed: %v", err)
+	}
+	expected := `--- old.json
++++ new.json
+@@ -1,4 +1,4 @@
+-https://example.com/a size=10
++https://example.com/a size=12
+ https://example.com/b
+ https://example.com/c
+ https://example.com/d
+@@ -7,3 +7,4 @@
+ https://example.com/g
+ https://example.com/h
+ https://example.com/i
++https://example.com/j
+`
+	if out.String() != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, out.String())
+	}
+
+	out.Reset()
+	writeDiffUnified(&out, Compare(oldLinks, oldLinks), "old.json", "new.json")
+	if out.Len() != 0 {
+		t.Errorf("Expected no output for identical sets, got %q", out.String())
+	}
+}